@@ -0,0 +1,439 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+const (
+	channelMax = 2047
+	frameMax   = 128 * 1024
+
+	// frameMinSize 为协议规定的最小 frame 大小 协商完成前使用
+	frameMinSize = 4096
+
+	maxMessageSize = 128 * 1024 * 1024
+
+	defaultVhost = "/"
+)
+
+type Config struct {
+	Addr     string
+	User     string
+	Password string
+
+	// Queues 为启动时在默认 vhost 中声明的 durable 队列 使得首先被动声明队列的客户端可以直接运行
+	Queues []string
+
+	// Heartbeat 为建议客户端使用的心跳间隔 最终以客户端在 connection.tune-ok 中的选择为准
+	Heartbeat time.Duration
+}
+
+// Broker 为 amqp 0-9-1 的替身 仅支持默认交换机 队列以及消息仅保存在内存中
+type Broker struct {
+	conf Config
+
+	mu     sync.Mutex
+	queues map[queueKey]*queue
+}
+
+type queueKey struct {
+	vhost string
+	name  string
+}
+
+func New(conf Config) (*Broker, error) {
+	if conf.Heartbeat < 0 || conf.Heartbeat > 65535*time.Second {
+		return nil, errors.New("heartbeat must be between 0 and 65535s")
+	}
+	b := &Broker{
+		conf:   conf,
+		queues: make(map[queueKey]*queue),
+	}
+	for _, name := range conf.Queues {
+		if name == "" || len(name) > 255 {
+			return nil, fmt.Errorf("invalid queue name %q", name)
+		}
+		key := queueKey{vhost: defaultVhost, name: name}
+		b.queues[key] = newQueue(key, queueOptions{durable: true})
+	}
+	return b, nil
+}
+
+// NewCommand 返回 `amqp broker` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var queues string
+	return &common.Command{
+		Name:  "amqp broker",
+		Usage: "serve an in-memory amqp 0-9-1 broker for the producer and consumer benchmarks",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:5672", "amqp broker address")
+			fs.StringVar(&c.User, "user", "guest", "user accepted by PLAIN authentication")
+			fs.StringVar(&c.Password, "password", "guest", "password accepted by PLAIN authentication")
+			fs.StringVar(&queues, "queues", "benchmark", "durable queues declared in the default vhost at startup, as a comma separated list")
+			fs.DurationVar(&c.Heartbeat, "heartbeat", 60*time.Second, "heartbeat interval suggested to clients, 0 disables heartbeats")
+		},
+		Run: func([]string) error {
+			if queues != "" {
+				c.Queues = strings.Split(queues, ",")
+			}
+			b, err := New(c)
+			if err != nil {
+				return err
+			}
+			return b.Serve()
+		},
+	}
+}
+
+func (b *Broker) Serve() error {
+	lis, err := net.Listen("tcp", b.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go b.handle(c)
+	}
+}
+
+type conn struct {
+	broker *Broker
+	nc     net.Conn
+	br     *bufio.Reader
+
+	writeMu  sync.Mutex
+	bw       *bufio.Writer
+	writeErr error
+
+	vhost     string
+	frameMax  int
+	heartbeat time.Duration
+
+	// channels 以及 exclusive 仅由读 goroutine 访问
+	channels  map[uint16]*channel
+	exclusive []*queue
+}
+
+func (b *Broker) handle(nc net.Conn) {
+	c := &conn{
+		broker:   b,
+		nc:       nc,
+		br:       bufio.NewReader(nc),
+		bw:       bufio.NewWriter(nc),
+		frameMax: frameMinSize,
+		channels: make(map[uint16]*channel),
+	}
+	done := make(chan struct{})
+	err := c.serve(done)
+	close(done)
+	nc.Close()
+	c.shutdown()
+
+	var ae *amqpError
+	switch {
+	case err == nil, errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+	case errors.As(err, &ae):
+		log.Printf("connection from %s closed: %v\n", nc.RemoteAddr(), err)
+	default:
+		log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+	}
+}
+
+// send 写入若干个完整的 frame 写入失败后的所有发送均直接返回错误
+func (c *conn) send(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if _, err := c.bw.Write(b); err != nil {
+		c.writeErr = err
+		return err
+	}
+	if err := c.bw.Flush(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+func (c *conn) sendMethod(channel uint16, e *encoder) error {
+	return c.send(appendFrame(nil, frameMethod, channel, e.b))
+}
+
+// sendContent 发送方法以及消息内容 body 按照协商的 frame max 拆分
+func (c *conn) sendContent(channel uint16, e *encoder, m *message) error {
+	header := &encoder{}
+	header.short(classBasic)
+	header.short(0)
+	header.longlong(uint64(len(m.body)))
+	header.b = append(header.b, m.properties...)
+
+	b := appendFrame(nil, frameMethod, channel, e.b)
+	b = appendFrame(b, frameHeader, channel, header.b)
+	for body, n := m.body, c.frameMax-frameOverhead; len(body) > 0; {
+		chunk := body[:min(n, len(body))]
+		body = body[len(chunk):]
+		b = appendFrame(b, frameBody, channel, chunk)
+	}
+	return c.send(b)
+}
+
+func (c *conn) readFrame() (frame, error) {
+	if c.heartbeat > 0 {
+		c.nc.SetReadDeadline(time.Now().Add(3 * c.heartbeat))
+	}
+	return readFrame(c.br, c.frameMax)
+}
+
+// readMethod 读取协商阶段的方法 期望的方法以外的 frame 视为异常
+func (c *conn) readMethod(expected uint32) (*decoder, error) {
+	f, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{b: f.payload}
+	if f.typ != frameMethod || f.channel != 0 {
+		return nil, connectionError(replyUnexpectedFrame, 0, "expected method frame on channel 0 during negotiation")
+	}
+	if id := d.long(); id != expected {
+		return nil, connectionError(replyCommandInvalid, id, "expected method %#x during negotiation, got %#x", expected, id)
+	}
+	return d, nil
+}
+
+// negotiate 完成 start / tune / open 三个阶段 认证仅支持 PLAIN
+func (c *conn) negotiate() error {
+	header := make([]byte, len(protocolHeader))
+	if _, err := io.ReadFull(c.br, header); err != nil {
+		return err
+	}
+	if !bytes.Equal(header, protocolHeader) {
+		c.send(protocolHeader)
+		return fmt.Errorf("unsupported protocol header %q", header)
+	}
+
+	start := newMethod(connectionStart)
+	start.octet(0)
+	start.octet(9)
+	start.table(func(e *encoder) {
+		e.fieldString("product", "packetd-bench")
+		e.fieldString("platform", "Go")
+		e.fieldTable("capabilities", func(e *encoder) {
+			e.fieldBool("publisher_confirms", true)
+			e.fieldBool("basic.nack", true)
+			e.fieldBool("consumer_cancel_notify", true)
+			e.fieldBool("per_consumer_qos", true)
+			e.fieldBool("authentication_failure_close", true)
+		})
+	})
+	start.longstr("PLAIN")
+	start.longstr("en_US")
+	if err := c.sendMethod(0, start); err != nil {
+		return err
+	}
+
+	d, err := c.readMethod(connectionStartOk)
+	if err != nil {
+		return err
+	}
+	d.table()
+	mechanism := d.shortstr()
+	response := d.longstr()
+	d.shortstr()
+	if d.err != nil {
+		return d.err
+	}
+	if mechanism != "PLAIN" || !bytes.Equal(response, []byte("\x00"+c.broker.conf.User+"\x00"+c.broker.conf.Password)) {
+		return connectionError(replyAccessRefused, connectionStartOk, "Login was refused using authentication mechanism %s.", mechanism)
+	}
+
+	tune := newMethod(connectionTune)
+	tune.short(channelMax)
+	tune.long(frameMax)
+	tune.short(uint16(c.broker.conf.Heartbeat / time.Second))
+	if err := c.sendMethod(0, tune); err != nil {
+		return err
+	}
+
+	if d, err = c.readMethod(connectionTuneOk); err != nil {
+		return err
+	}
+	d.short()
+	size := int(d.long())
+	heartbeat := time.Duration(d.short()) * time.Second
+	if d.err != nil {
+		return d.err
+	}
+	if size != 0 && (size < frameMinSize || size > frameMax) {
+		return connectionError(replyNotAllowed, connectionTuneOk, "negotiated frame_max = %d is out of range", size)
+	}
+	c.frameMax = frameMax
+	if size != 0 {
+		c.frameMax = size
+	}
+	c.heartbeat = heartbeat
+
+	if d, err = c.readMethod(connectionOpen); err != nil {
+		return err
+	}
+	c.vhost = d.shortstr()
+	if d.err != nil {
+		return d.err
+	}
+	open := newMethod(connectionOpenOk)
+	open.shortstr("")
+	return c.sendMethod(0, open)
+}
+
+func (c *conn) serve(done chan struct{}) error {
+	err := c.negotiate()
+	if err == nil {
+		if c.heartbeat > 0 {
+			go c.heartbeater(done)
+		}
+		err = c.loop()
+	}
+
+	var ae *amqpError
+	if errors.As(err, &ae) {
+		c.closeWith(ae)
+	}
+	return err
+}
+
+// heartbeater 以心跳间隔的一半发送心跳 frame
+func (c *conn) heartbeater(done chan struct{}) {
+	ticker := time.NewTicker(c.heartbeat / 2)
+	defer ticker.Stop()
+
+	heartbeat := appendFrame(nil, frameHeartbeat, 0, nil)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.send(heartbeat); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// loop 处理协商完成后的所有 frame 客户端关闭连接时返回 nil
+func (c *conn) loop() error {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+
+		switch f.typ {
+		case frameHeartbeat:
+			continue
+		case frameMethod, frameHeader, frameBody:
+		default:
+			return connectionError(replyFrameError, 0, "unknown frame type %d", f.typ)
+		}
+
+		if f.channel == 0 {
+			closed, err := c.connectionMethod(f)
+			if closed || err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.channelFrame(f); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *conn) connectionMethod(f frame) (bool, error) {
+	if f.typ != frameMethod {
+		return false, connectionError(replyUnexpectedFrame, 0, "unexpected frame type %d on channel 0", f.typ)
+	}
+	d := &decoder{b: f.payload}
+	switch id := d.long(); id {
+	case connectionClose:
+		c.shutdown()
+		return true, c.sendMethod(0, newMethod(connectionCloseOk))
+	case connectionCloseOk:
+		return true, nil
+	default:
+		return false, connectionError(replyCommandInvalid, id, "unexpected method %#x on channel 0", id)
+	}
+}
+
+// closeWith 发送 connection.close 并等待客户端的 close-ok
+func (c *conn) closeWith(ae *amqpError) {
+	c.shutdown()
+
+	e := newMethod(connectionClose)
+	e.short(ae.code)
+	e.shortstr(ae.text)
+	e.short(uint16(ae.method >> 16))
+	e.short(uint16(ae.method))
+	if err := c.sendMethod(0, e); err != nil {
+		return
+	}
+
+	c.nc.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		f, err := readFrame(c.br, max(c.frameMax, frameMax))
+		if err != nil {
+			return
+		}
+		if f.typ == frameMethod && f.channel == 0 {
+			d := &decoder{b: f.payload}
+			if id := d.long(); id == connectionClose || id == connectionCloseOk {
+				return
+			}
+		}
+	}
+}
+
+// shutdown 关闭所有的 channel 并删除连接独占的队列
+func (c *conn) shutdown() {
+	for id, ch := range c.channels {
+		ch.shutdown()
+		delete(c.channels, id)
+	}
+	for _, q := range c.exclusive {
+		c.broker.deleteQueue(q)
+	}
+	c.exclusive = nil
+}
@@ -0,0 +1,588 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+)
+
+type channel struct {
+	id   uint16
+	conn *conn
+
+	// 以下字段仅由读 goroutine 访问
+	closing    bool
+	confirm    bool
+	publishSeq uint64
+	prefetch   int
+	pending    *message
+	bodySize   int
+	mandatory  bool
+
+	// deliverMu 保证 delivery tag 按照发送的顺序递增
+	deliverMu sync.Mutex
+
+	mu          sync.Mutex
+	deliveryTag uint64
+	unacked     map[uint64]*delivery
+	consumers   map[string]*consumer
+}
+
+// delivery 为已投递但尚未确认的消息
+type delivery struct {
+	consumer *consumer
+	msg      *message
+}
+
+type consumer struct {
+	tag      string
+	ch       *channel
+	queue    *queue
+	noAck    bool
+	prefetch int
+	done     chan struct{}
+
+	// 以下字段由 queue.mu 保护 notify 表示 consumer 由 broker 取消 需要通知客户端
+	unacked   int
+	cancelled bool
+	notify    bool
+}
+
+func malformed(method uint32) error {
+	return connectionError(replySyntaxError, method, "malformed arguments for method class %d method %d", method>>16, method&0xffff)
+}
+
+// channelFrame 处理 channel 上的 frame channel 级别的异常关闭 channel 其余的异常关闭连接
+func (c *conn) channelFrame(f frame) error {
+	ch := c.channels[f.channel]
+	var err error
+	if f.typ == frameMethod {
+		d := &decoder{b: f.payload}
+		id := d.long()
+		switch {
+		case ch == nil && id == channelOpen:
+			return c.openChannel(f.channel)
+		case ch == nil:
+			return connectionError(replyChannelError, id, "expected 'channel.open' for channel %d", f.channel)
+		case ch.closing:
+			// 等待客户端回复 close-ok 期间忽略其他的方法
+			switch id {
+			case channelClose:
+				delete(c.channels, ch.id)
+				return c.sendMethod(ch.id, newMethod(channelCloseOk))
+			case channelCloseOk:
+				delete(c.channels, ch.id)
+			}
+			return nil
+		}
+		err = ch.method(id, d)
+	} else {
+		if ch == nil {
+			return connectionError(replyChannelError, 0, "unexpected frame on channel %d", f.channel)
+		}
+		if ch.closing {
+			return nil
+		}
+		err = ch.content(f)
+	}
+
+	var ae *amqpError
+	if !errors.As(err, &ae) || ae.connection {
+		return err
+	}
+	ch.shutdown()
+	ch.closing = true
+	e := newMethod(channelClose)
+	e.short(ae.code)
+	e.shortstr(ae.text)
+	e.short(uint16(ae.method >> 16))
+	e.short(uint16(ae.method))
+	return c.sendMethod(ch.id, e)
+}
+
+func (c *conn) openChannel(id uint16) error {
+	if id > channelMax {
+		return connectionError(replyNotAllowed, channelOpen, "channel number %d exceeds channel_max %d", id, channelMax)
+	}
+	c.channels[id] = &channel{
+		id:        id,
+		conn:      c,
+		unacked:   make(map[uint64]*delivery),
+		consumers: make(map[string]*consumer),
+	}
+	e := newMethod(channelOpenOk)
+	e.longstr("")
+	return c.sendMethod(id, e)
+}
+
+func (ch *channel) method(id uint32, d *decoder) error {
+	if ch.pending != nil {
+		return connectionError(replyUnexpectedFrame, id, "expected content header for class %d, got method %#x", classBasic, id)
+	}
+
+	switch id {
+	case channelOpen:
+		return connectionError(replyChannelError, id, "second 'channel.open' seen")
+	case channelFlow:
+		active := d.bits(1)[0]
+		if d.err != nil {
+			return malformed(id)
+		}
+		e := newMethod(channelFlowOk)
+		e.bits(active)
+		return ch.conn.sendMethod(ch.id, e)
+	case channelClose:
+		ch.shutdown()
+		delete(ch.conn.channels, ch.id)
+		return ch.conn.sendMethod(ch.id, newMethod(channelCloseOk))
+	case channelCloseOk:
+		return nil
+	case queueDeclare:
+		return ch.queueDeclare(d)
+	case queueDelete:
+		return ch.queueDelete(d)
+	case basicQos:
+		return ch.basicQos(d)
+	case basicConsume:
+		return ch.basicConsume(d)
+	case basicCancel:
+		return ch.basicCancel(d)
+	case basicPublish:
+		return ch.basicPublish(d)
+	case basicAck:
+		tag := d.longlong()
+		multiple := d.bits(1)[0]
+		if d.err != nil {
+			return malformed(id)
+		}
+		return ch.settle(id, tag, multiple, false)
+	case basicReject:
+		tag := d.longlong()
+		requeue := d.bits(1)[0]
+		if d.err != nil {
+			return malformed(id)
+		}
+		return ch.settle(id, tag, false, requeue)
+	case basicNack:
+		tag := d.longlong()
+		bits := d.bits(2)
+		if d.err != nil {
+			return malformed(id)
+		}
+		return ch.settle(id, tag, bits[0], bits[1])
+	case confirmSelect:
+		noWait := d.bits(1)[0]
+		if d.err != nil {
+			return malformed(id)
+		}
+		ch.confirm = true
+		if noWait {
+			return nil
+		}
+		return ch.conn.sendMethod(ch.id, newMethod(confirmSelectOk))
+	default:
+		return connectionError(replyNotImplemented, id, "method class %d method %d is not implemented", id>>16, id&0xffff)
+	}
+}
+
+func (ch *channel) queueDeclare(d *decoder) error {
+	d.short()
+	name := d.shortstr()
+	bits := d.bits(5)
+	d.table()
+	if d.err != nil {
+		return malformed(queueDeclare)
+	}
+
+	q, messages, consumers, err := ch.conn.broker.declareQueue(ch.conn, name, queueOptions{
+		passive:    bits[0],
+		durable:    bits[1],
+		exclusive:  bits[2],
+		autoDelete: bits[3],
+	})
+	if err != nil || bits[4] {
+		return err
+	}
+	e := newMethod(queueDeclareOk)
+	e.shortstr(q.key.name)
+	e.long(uint32(messages))
+	e.long(uint32(consumers))
+	return ch.conn.sendMethod(ch.id, e)
+}
+
+// queueDelete 删除队列 与 RabbitMQ 一致 删除不存在的队列同样回复 delete-ok
+func (ch *channel) queueDelete(d *decoder) error {
+	d.short()
+	name := d.shortstr()
+	bits := d.bits(3)
+	if d.err != nil {
+		return malformed(queueDelete)
+	}
+	ifUnused, ifEmpty, noWait := bits[0], bits[1], bits[2]
+
+	var n int
+	q, err := ch.conn.broker.lookupQueue(ch.conn, name, queueDelete)
+	var ae *amqpError
+	switch {
+	case errors.As(err, &ae) && ae.code == replyNotFound:
+	case err != nil:
+		return err
+	default:
+		messages, consumers := q.stats()
+		if ifUnused && consumers > 0 {
+			return channelError(replyPreconditionFailed, queueDelete, "queue '%s' in vhost '%s' in use", name, ch.conn.vhost)
+		}
+		if ifEmpty && messages > 0 {
+			return channelError(replyPreconditionFailed, queueDelete, "queue '%s' in vhost '%s' is not empty", name, ch.conn.vhost)
+		}
+		n = ch.conn.broker.deleteQueue(q)
+	}
+	if noWait {
+		return nil
+	}
+	e := newMethod(queueDeleteOk)
+	e.long(uint32(n))
+	return ch.conn.sendMethod(ch.id, e)
+}
+
+// basicQos 设置此后创建的 consumer 的 prefetch count global 与否均按照每个 consumer 计算
+func (ch *channel) basicQos(d *decoder) error {
+	size := d.long()
+	count := d.short()
+	d.bits(1)
+	if d.err != nil {
+		return malformed(basicQos)
+	}
+	if size != 0 {
+		return connectionError(replyNotImplemented, basicQos, "prefetch_size!=0 (%d)", size)
+	}
+	ch.prefetch = int(count)
+	return ch.conn.sendMethod(ch.id, newMethod(basicQosOk))
+}
+
+func (ch *channel) basicConsume(d *decoder) error {
+	d.short()
+	name := d.shortstr()
+	tag := d.shortstr()
+	bits := d.bits(4)
+	d.table()
+	if d.err != nil {
+		return malformed(basicConsume)
+	}
+	noAck, exclusive, noWait := bits[1], bits[2], bits[3]
+
+	if tag == "" {
+		tag = fmt.Sprintf("amq.ctag-%016x", rand.Uint64())
+	}
+	ch.mu.Lock()
+	_, exists := ch.consumers[tag]
+	ch.mu.Unlock()
+	if exists {
+		return connectionError(replyNotAllowed, basicConsume, "attempt to reuse consumer tag '%s'", tag)
+	}
+
+	q, err := ch.conn.broker.lookupQueue(ch.conn, name, basicConsume)
+	if err != nil {
+		return err
+	}
+	cs := &consumer{
+		tag:      tag,
+		ch:       ch,
+		queue:    q,
+		noAck:    noAck,
+		prefetch: ch.prefetch,
+		done:     make(chan struct{}),
+	}
+	if err := q.addConsumer(cs, exclusive); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.consumers[tag] = cs
+	ch.mu.Unlock()
+
+	// consume-ok 必须先于第一条 deliver 发送
+	if !noWait {
+		e := newMethod(basicConsumeOk)
+		e.shortstr(tag)
+		if err := ch.conn.sendMethod(ch.id, e); err != nil {
+			return err
+		}
+	}
+	go cs.run()
+	return nil
+}
+
+func (ch *channel) basicCancel(d *decoder) error {
+	tag := d.shortstr()
+	noWait := d.bits(1)[0]
+	if d.err != nil {
+		return malformed(basicCancel)
+	}
+
+	ch.mu.Lock()
+	cs, ok := ch.consumers[tag]
+	ch.mu.Unlock()
+	if ok {
+		ch.cancel(cs)
+	}
+	if noWait {
+		return nil
+	}
+	e := newMethod(basicCancelOk)
+	e.shortstr(tag)
+	return ch.conn.sendMethod(ch.id, e)
+}
+
+// cancel 取消 consumer 并等待其投递 goroutine 退出 auto-delete 的队列在失去所有 consumer 后被删除
+func (ch *channel) cancel(cs *consumer) {
+	last := cs.queue.cancel(cs)
+	<-cs.done
+	ch.mu.Lock()
+	delete(ch.consumers, cs.tag)
+	ch.mu.Unlock()
+
+	if last && cs.queue.autoDelete {
+		ch.conn.broker.deleteQueue(cs.queue)
+	}
+}
+
+// run 持续地从队列中取出消息并投递 直到 consumer 被取消或者连接写入失败
+func (cs *consumer) run() {
+	defer close(cs.done)
+
+	for {
+		m := cs.queue.next(cs)
+		if m == nil {
+			break
+		}
+		if err := cs.ch.deliver(cs, m); err != nil {
+			cs.queue.cancel(cs)
+			return
+		}
+	}
+
+	cs.queue.mu.Lock()
+	notify := cs.notify
+	cs.queue.mu.Unlock()
+	if !notify {
+		return
+	}
+
+	// 队列被删除时通知客户端
+	cs.ch.mu.Lock()
+	delete(cs.ch.consumers, cs.tag)
+	cs.ch.mu.Unlock()
+	e := newMethod(basicCancel)
+	e.shortstr(cs.tag)
+	e.bits(true)
+	cs.ch.conn.sendMethod(cs.ch.id, e)
+}
+
+func (ch *channel) deliver(cs *consumer, m *message) error {
+	ch.deliverMu.Lock()
+	defer ch.deliverMu.Unlock()
+
+	ch.mu.Lock()
+	ch.deliveryTag++
+	tag := ch.deliveryTag
+	if !cs.noAck {
+		ch.unacked[tag] = &delivery{consumer: cs, msg: m}
+	}
+	ch.mu.Unlock()
+
+	e := newMethod(basicDeliver)
+	e.shortstr(cs.tag)
+	e.longlong(tag)
+	e.bits(m.redelivered)
+	e.shortstr(m.exchange)
+	e.shortstr(m.routingKey)
+	return ch.conn.sendContent(ch.id, e, m)
+}
+
+// settle 处理 ack / nack / reject tag 为 0 且 multiple 时表示所有未确认的消息
+func (ch *channel) settle(method uint32, tag uint64, multiple, requeue bool) error {
+	ch.mu.Lock()
+	var tags []uint64
+	if multiple {
+		for t := range ch.unacked {
+			if t <= tag || tag == 0 {
+				tags = append(tags, t)
+			}
+		}
+	} else if _, ok := ch.unacked[tag]; ok {
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 && (tag != 0 || !multiple) {
+		ch.mu.Unlock()
+		return channelError(replyPreconditionFailed, method, "unknown delivery tag %d", tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	deliveries := make([]*delivery, 0, len(tags))
+	for _, t := range tags {
+		deliveries = append(deliveries, ch.unacked[t])
+		delete(ch.unacked, t)
+	}
+	ch.mu.Unlock()
+
+	release(deliveries, requeue)
+	return nil
+}
+
+// release 归还 consumer 的 prefetch 额度 requeue 时按照投递的顺序放回原队列
+func release(deliveries []*delivery, requeue bool) {
+	settled := make(map[*consumer]int)
+	requeued := make(map[*queue][]*message)
+	var queues []*queue
+	for _, dl := range deliveries {
+		settled[dl.consumer]++
+		if !requeue {
+			continue
+		}
+		q := dl.consumer.queue
+		if _, ok := requeued[q]; !ok {
+			queues = append(queues, q)
+		}
+		requeued[q] = append(requeued[q], dl.msg)
+	}
+	for _, q := range queues {
+		q.requeue(requeued[q])
+	}
+	for cs, n := range settled {
+		cs.queue.settle(cs, n)
+	}
+}
+
+func (ch *channel) basicPublish(d *decoder) error {
+	d.short()
+	exchange := d.shortstr()
+	routingKey := d.shortstr()
+	bits := d.bits(2)
+	if d.err != nil {
+		return malformed(basicPublish)
+	}
+	if bits[1] {
+		return connectionError(replyNotImplemented, basicPublish, "immediate=true")
+	}
+	if exchange != "" {
+		return channelError(replyNotFound, basicPublish, "no exchange '%s' in vhost '%s'", exchange, ch.conn.vhost)
+	}
+	ch.pending = &message{exchange: exchange, routingKey: routingKey}
+	ch.mandatory = bits[0]
+	return nil
+}
+
+// content 接收 basic.publish 之后的 content header 以及 body
+func (ch *channel) content(f frame) error {
+	m := ch.pending
+	if m == nil {
+		return connectionError(replyUnexpectedFrame, 0, "unexpected content frame type %d on channel %d", f.typ, ch.id)
+	}
+
+	if f.typ == frameHeader {
+		if m.properties != nil {
+			return connectionError(replyUnexpectedFrame, 0, "expected content body, got content header")
+		}
+		d := &decoder{b: f.payload}
+		class := d.short()
+		d.short()
+		size := d.longlong()
+		if d.err != nil || class != classBasic {
+			return connectionError(replyFrameError, 0, "invalid content header")
+		}
+		if size > maxMessageSize {
+			ch.pending = nil
+			return channelError(replyPreconditionFailed, basicPublish, "message size %d is larger than configured max size %d", size, maxMessageSize)
+		}
+		m.properties = d.b
+		ch.bodySize = int(size)
+		m.body = make([]byte, 0, ch.bodySize)
+	} else {
+		if m.properties == nil {
+			return connectionError(replyUnexpectedFrame, 0, "expected content header, got content body")
+		}
+		if len(m.body)+len(f.payload) > ch.bodySize {
+			return connectionError(replyFrameError, 0, "content body exceeds the declared size %d", ch.bodySize)
+		}
+		m.body = append(m.body, f.payload...)
+	}
+
+	if len(m.body) < ch.bodySize {
+		return nil
+	}
+	ch.pending = nil
+	return ch.route(m)
+}
+
+// route 通过默认交换机将消息投递到与 routing key 同名的队列 开启 confirm 时回复 basic.ack
+func (ch *channel) route(m *message) error {
+	b := ch.conn.broker
+	b.mu.Lock()
+	q := b.queues[queueKey{vhost: ch.conn.vhost, name: m.routingKey}]
+	b.mu.Unlock()
+
+	if q != nil {
+		q.push(m)
+	} else if ch.mandatory {
+		e := newMethod(basicReturn)
+		e.short(replyNoRoute)
+		e.shortstr(replyNames[replyNoRoute])
+		e.shortstr(m.exchange)
+		e.shortstr(m.routingKey)
+		if err := ch.conn.sendContent(ch.id, e, m); err != nil {
+			return err
+		}
+	}
+
+	if !ch.confirm {
+		return nil
+	}
+	ch.publishSeq++
+	e := newMethod(basicAck)
+	e.longlong(ch.publishSeq)
+	e.bits(false)
+	return ch.conn.sendMethod(ch.id, e)
+}
+
+// shutdown 取消 channel 上所有的 consumer 并将未确认的消息放回队列
+func (ch *channel) shutdown() {
+	ch.mu.Lock()
+	consumers := make([]*consumer, 0, len(ch.consumers))
+	for _, cs := range ch.consumers {
+		consumers = append(consumers, cs)
+	}
+	ch.mu.Unlock()
+	for _, cs := range consumers {
+		ch.cancel(cs)
+	}
+
+	ch.mu.Lock()
+	tags := make([]uint64, 0, len(ch.unacked))
+	for t := range ch.unacked {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	deliveries := make([]*delivery, 0, len(tags))
+	for _, t := range tags {
+		deliveries = append(deliveries, ch.unacked[t])
+	}
+	ch.unacked = make(map[uint64]*delivery)
+	ch.mu.Unlock()
+
+	release(deliveries, true)
+	ch.pending = nil
+}
@@ -0,0 +1,333 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frame 类型
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+
+	frameEnd = 0xCE
+
+	// frameOverhead 为 frame 头部以及结束符的长度
+	frameOverhead = 8
+)
+
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+const (
+	classConnection = 10
+	classChannel    = 20
+	classQueue      = 50
+	classBasic      = 60
+	classConfirm    = 85
+)
+
+// method id 的高 16 位为 class id 低 16 位为 method id
+const (
+	connectionStart   = classConnection<<16 | 10
+	connectionStartOk = classConnection<<16 | 11
+	connectionTune    = classConnection<<16 | 30
+	connectionTuneOk  = classConnection<<16 | 31
+	connectionOpen    = classConnection<<16 | 40
+	connectionOpenOk  = classConnection<<16 | 41
+	connectionClose   = classConnection<<16 | 50
+	connectionCloseOk = classConnection<<16 | 51
+
+	channelOpen    = classChannel<<16 | 10
+	channelOpenOk  = classChannel<<16 | 11
+	channelFlow    = classChannel<<16 | 20
+	channelFlowOk  = classChannel<<16 | 21
+	channelClose   = classChannel<<16 | 40
+	channelCloseOk = classChannel<<16 | 41
+
+	queueDeclare   = classQueue<<16 | 10
+	queueDeclareOk = classQueue<<16 | 11
+	queueDelete    = classQueue<<16 | 40
+	queueDeleteOk  = classQueue<<16 | 41
+
+	basicQos       = classBasic<<16 | 10
+	basicQosOk     = classBasic<<16 | 11
+	basicConsume   = classBasic<<16 | 20
+	basicConsumeOk = classBasic<<16 | 21
+	basicCancel    = classBasic<<16 | 30
+	basicCancelOk  = classBasic<<16 | 31
+	basicPublish   = classBasic<<16 | 40
+	basicReturn    = classBasic<<16 | 50
+	basicDeliver   = classBasic<<16 | 60
+	basicAck       = classBasic<<16 | 80
+	basicReject    = classBasic<<16 | 90
+	basicNack      = classBasic<<16 | 120
+
+	confirmSelect   = classConfirm<<16 | 10
+	confirmSelectOk = classConfirm<<16 | 11
+)
+
+// reply code
+const (
+	replySuccess            = 200
+	replyNoRoute            = 312
+	replyAccessRefused      = 403
+	replyNotFound           = 404
+	replyResourceLocked     = 405
+	replyPreconditionFailed = 406
+	replyFrameError         = 501
+	replySyntaxError        = 502
+	replyCommandInvalid     = 503
+	replyChannelError       = 504
+	replyUnexpectedFrame    = 505
+	replyNotAllowed         = 530
+	replyNotImplemented     = 540
+)
+
+var replyNames = map[uint16]string{
+	replyNoRoute:            "NO_ROUTE",
+	replyAccessRefused:      "ACCESS_REFUSED",
+	replyNotFound:           "NOT_FOUND",
+	replyResourceLocked:     "RESOURCE_LOCKED",
+	replyPreconditionFailed: "PRECONDITION_FAILED",
+	replyFrameError:         "FRAME_ERROR",
+	replySyntaxError:        "SYNTAX_ERROR",
+	replyCommandInvalid:     "COMMAND_INVALID",
+	replyChannelError:       "CHANNEL_ERROR",
+	replyUnexpectedFrame:    "UNEXPECTED_FRAME",
+	replyNotAllowed:         "NOT_ALLOWED",
+	replyNotImplemented:     "NOT_IMPLEMENTED",
+}
+
+// amqpError 为需要通过 close 方法回复的异常 connection 为 false 时仅关闭 channel
+type amqpError struct {
+	code       uint16
+	text       string
+	method     uint32
+	connection bool
+}
+
+func (e *amqpError) Error() string {
+	return e.text
+}
+
+func newError(connection bool, code uint16, method uint32, format string, args ...any) *amqpError {
+	return &amqpError{
+		code:       code,
+		text:       replyNames[code] + " - " + fmt.Sprintf(format, args...),
+		method:     method,
+		connection: connection,
+	}
+}
+
+func channelError(code uint16, method uint32, format string, args ...any) *amqpError {
+	return newError(false, code, method, format, args...)
+}
+
+func connectionError(code uint16, method uint32, format string, args ...any) *amqpError {
+	return newError(true, code, method, format, args...)
+}
+
+type frame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(br *bufio.Reader, frameMax int) (frame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return frame{}, err
+	}
+	size := int(binary.BigEndian.Uint32(header[3:]))
+	if size > frameMax-frameOverhead {
+		return frame{}, connectionError(replyFrameError, 0, "frame size %d exceeds frame max %d", size, frameMax)
+	}
+
+	buf := make([]byte, size+1)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return frame{}, err
+	}
+	if buf[size] != frameEnd {
+		return frame{}, connectionError(replyFrameError, 0, "invalid frame end octet %#x", buf[size])
+	}
+	return frame{
+		typ:     header[0],
+		channel: binary.BigEndian.Uint16(header[1:]),
+		payload: buf[:size],
+	}, nil
+}
+
+func appendFrame(b []byte, typ byte, channel uint16, payload []byte) []byte {
+	b = append(b, typ)
+	b = binary.BigEndian.AppendUint16(b, channel)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	b = append(b, payload...)
+	return append(b, frameEnd)
+}
+
+var errMalformed = errors.New("malformed frame")
+
+// decoder 用于解析方法参数 出现越界时记录 errMalformed 并返回零值
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if n < 0 || len(d.b) < n {
+		if d.err == nil {
+			d.err = errMalformed
+		}
+		d.b = nil
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) octet() byte {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) short() uint16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (d *decoder) long() uint32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *decoder) longlong() uint64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (d *decoder) shortstr() string {
+	return string(d.next(int(d.octet())))
+}
+
+func (d *decoder) longstr() []byte {
+	return d.next(int(d.long()))
+}
+
+// table 跳过 field table 其内容不影响 broker 的行为
+func (d *decoder) table() {
+	d.longstr()
+}
+
+// bits 返回打包在同一个 octet 中的连续 bit 参数
+func (d *decoder) bits(n int) []bool {
+	v := d.octet()
+	bs := make([]bool, n)
+	for i := range bs {
+		bs[i] = v&(1<<i) != 0
+	}
+	return bs
+}
+
+// encoder 用于构造方法参数
+type encoder struct {
+	b []byte
+}
+
+func newMethod(id uint32) *encoder {
+	e := &encoder{}
+	e.long(id)
+	return e
+}
+
+func (e *encoder) octet(v byte) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) short(v uint16) {
+	e.b = binary.BigEndian.AppendUint16(e.b, v)
+}
+
+func (e *encoder) long(v uint32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) longlong(v uint64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+}
+
+func (e *encoder) shortstr(s string) {
+	e.octet(byte(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) longstr(s string) {
+	e.long(uint32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) bits(bs ...bool) {
+	var v byte
+	for i, b := range bs {
+		if b {
+			v |= 1 << i
+		}
+	}
+	e.octet(v)
+}
+
+// table 写入 field table fields 负责写入各个字段
+func (e *encoder) table(fields func(e *encoder)) {
+	start := len(e.b)
+	e.long(0)
+	fields(e)
+	binary.BigEndian.PutUint32(e.b[start:], uint32(len(e.b)-start-4))
+}
+
+func (e *encoder) fieldString(name, v string) {
+	e.shortstr(name)
+	e.octet('S')
+	e.longstr(v)
+}
+
+func (e *encoder) fieldBool(name string, v bool) {
+	e.shortstr(name)
+	e.octet('t')
+	e.bits(v)
+}
+
+func (e *encoder) fieldTable(name string, fields func(e *encoder)) {
+	e.shortstr(name)
+	e.octet('F')
+	e.table(fields)
+}
@@ -0,0 +1,256 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+type message struct {
+	exchange    string
+	routingKey  string
+	properties  []byte // content header 中 property flags 以及 property list 的原始内容
+	body        []byte
+	redelivered bool
+}
+
+// queue 为 FIFO 队列 消息由每个 consumer 各自的 goroutine 取出并投递
+type queue struct {
+	key        queueKey
+	durable    bool
+	exclusive  bool
+	autoDelete bool
+	owner      *conn
+
+	mu                sync.Mutex
+	cond              *sync.Cond
+	messages          []*message
+	consumers         []*consumer
+	exclusiveConsumer bool
+	deleted           bool
+}
+
+type queueOptions struct {
+	passive    bool
+	durable    bool
+	exclusive  bool
+	autoDelete bool
+}
+
+func newQueue(key queueKey, opts queueOptions) *queue {
+	q := &queue{
+		key:        key,
+		durable:    opts.durable,
+		exclusive:  opts.exclusive,
+		autoDelete: opts.autoDelete,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// declareQueue 创建队列或者校验已有队列的参数 返回队列以及当前的消息数和 consumer 数
+func (b *Broker) declareQueue(c *conn, name string, opts queueOptions) (*queue, int, int, error) {
+	if name == "" && !opts.passive {
+		name = fmt.Sprintf("amq.gen-%016x", rand.Uint64())
+	} else if strings.HasPrefix(name, "amq.") && !opts.passive {
+		return nil, 0, 0, channelError(replyAccessRefused, queueDeclare, "queue name '%s' contains reserved prefix 'amq.*'", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := queueKey{vhost: c.vhost, name: name}
+	q, ok := b.queues[key]
+	if !ok {
+		if opts.passive {
+			return nil, 0, 0, channelError(replyNotFound, queueDeclare, "no queue '%s' in vhost '%s'", name, c.vhost)
+		}
+		q = newQueue(key, opts)
+		if opts.exclusive {
+			q.owner = c
+			c.exclusive = append(c.exclusive, q)
+		}
+		b.queues[key] = q
+		return q, 0, 0, nil
+	}
+
+	if err := q.access(c, queueDeclare); err != nil {
+		return nil, 0, 0, err
+	}
+	if !opts.passive {
+		for _, arg := range []struct {
+			name              string
+			received, current bool
+		}{
+			{"durable", opts.durable, q.durable},
+			{"exclusive", opts.exclusive, q.exclusive},
+			{"auto_delete", opts.autoDelete, q.autoDelete},
+		} {
+			if arg.received != arg.current {
+				return nil, 0, 0, channelError(replyPreconditionFailed, queueDeclare,
+					"inequivalent arg '%s' for queue '%s' in vhost '%s': received '%t' but current is '%t'",
+					arg.name, name, c.vhost, arg.received, arg.current)
+			}
+		}
+	}
+	messages, consumers := q.stats()
+	return q, messages, consumers, nil
+}
+
+// lookupQueue 返回已存在的队列 队列不存在时返回 NOT_FOUND
+func (b *Broker) lookupQueue(c *conn, name string, method uint32) (*queue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queueKey{vhost: c.vhost, name: name}]
+	if !ok {
+		return nil, channelError(replyNotFound, method, "no queue '%s' in vhost '%s'", name, c.vhost)
+	}
+	if err := q.access(c, method); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// deleteQueue 删除队列并取消其所有的 consumer 返回被丢弃的消息数
+func (b *Broker) deleteQueue(q *queue) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.queues[q.key] == q {
+		delete(b.queues, q.key)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.messages)
+	q.messages = nil
+	q.deleted = true
+	for _, cs := range q.consumers {
+		cs.cancelled = true
+		cs.notify = true
+	}
+	q.consumers = nil
+	q.cond.Broadcast()
+	return n
+}
+
+// access 判断连接是否可以访问队列 独占队列仅允许声明它的连接访问
+func (q *queue) access(c *conn, method uint32) error {
+	if q.exclusive && q.owner != c {
+		return channelError(replyResourceLocked, method,
+			"cannot obtain exclusive access to locked queue '%s' in vhost '%s'", q.key.name, q.key.vhost)
+	}
+	return nil
+}
+
+func (q *queue) stats() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages), len(q.consumers)
+}
+
+func (q *queue) push(m *message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.deleted {
+		return
+	}
+	q.messages = append(q.messages, m)
+	q.cond.Broadcast()
+}
+
+// requeue 将未确认的消息按照原有的顺序放回队首
+func (q *queue) requeue(ms []*message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.deleted {
+		return
+	}
+	for _, m := range ms {
+		m.redelivered = true
+	}
+	q.messages = append(ms[:len(ms):len(ms)], q.messages...)
+	q.cond.Broadcast()
+}
+
+// addConsumer 注册 consumer exclusive 的 consumer 要求队列没有其他的 consumer
+func (q *queue) addConsumer(cs *consumer, exclusive bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.exclusiveConsumer || (exclusive && len(q.consumers) > 0) {
+		return channelError(replyAccessRefused, basicConsume, "queue '%s' in vhost '%s' in exclusive use", q.key.name, q.key.vhost)
+	}
+	q.exclusiveConsumer = exclusive
+	q.consumers = append(q.consumers, cs)
+	return nil
+}
+
+// cancel 取消 consumer 返回队列是否因此失去了所有的 consumer
+func (q *queue) cancel(cs *consumer) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cs.cancelled {
+		return false
+	}
+	cs.cancelled = true
+	for i, other := range q.consumers {
+		if other == cs {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+	q.exclusiveConsumer = false
+	q.cond.Broadcast()
+	return len(q.consumers) == 0
+}
+
+// next 阻塞直到有消息并且 consumer 未超过 prefetch 的限制 consumer 被取消时返回 nil
+func (q *queue) next(cs *consumer) *message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if cs.cancelled {
+			return nil
+		}
+		if len(q.messages) > 0 && (cs.noAck || cs.prefetch == 0 || cs.unacked < cs.prefetch) {
+			m := q.messages[0]
+			q.messages[0] = nil
+			q.messages = q.messages[1:]
+			if !cs.noAck {
+				cs.unacked++
+			}
+			return m
+		}
+		q.cond.Wait()
+	}
+}
+
+// settle 在消息被确认或者拒绝后归还 consumer 的 prefetch 额度
+func (q *queue) settle(cs *consumer, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cs.unacked -= n
+	q.cond.Broadcast()
+}
@@ -2,4 +2,18 @@ module github.com/packetd/packetd-benchmark/amqp/producer
 
 go 1.24.4
 
-require github.com/rabbitmq/amqp091-go v1.10.0
+replace github.com/packetd/packetd-benchmark/common v0.0.0 => ./../../common
+
+require (
+	github.com/packetd/packetd-benchmark/common v0.0.0
+	github.com/rabbitmq/amqp091-go v1.10.0
+)
+
+require (
+	github.com/jedib0t/go-pretty/v6 v6.6.7 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
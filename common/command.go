@@ -0,0 +1,303 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Command 为 packetd-bench 的子命令
+type Command struct {
+	// Name 为子命令的完整路径 如 `http client`
+	Name string
+
+	// Usage 为子命令的简要说明 ArgsUsage 为位置参数的说明
+	Usage     string
+	ArgsUsage string
+
+	// RegisterFlags 注册子命令的命令行参数
+	RegisterFlags func(fs *flag.FlagSet)
+
+	// Runner 在参数解析完成后创建基于 Runner 的压测 执行后输出结果
+	// 未设置时执行 Run args 为剩余的位置参数
+	Runner func() *Runner
+	Run    func(args []string) error
+}
+
+// FlagSet 返回注册了子命令参数的 FlagSet
+func (c *Command) FlagSet(prog string) *flag.FlagSet {
+	fs := flag.NewFlagSet(prog+" "+c.Name, flag.ContinueOnError)
+	if c.RegisterFlags != nil {
+		c.RegisterFlags(fs)
+	}
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s\n\n%s\n\nFlags:\n", strings.TrimSpace(fs.Name()+" [flags] "+c.ArgsUsage), c.Usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Execute 解析参数并执行子命令 globals 中显式设置的参数会作为子命令同名参数的默认值
+func (c *Command) Execute(prog string, args []string, globals *flag.FlagSet) error {
+	fs := c.FlagSet(prog)
+	var rf runnerFlags
+	if c.Runner != nil {
+		rf.register(fs)
+	}
+	if err := inheritFlags(fs, globals); err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return &parseError{err: err}
+	}
+
+	if c.Runner == nil {
+		return c.Run(fs.Args())
+	}
+	cr := &commandRunner{prog: prog, cmd: c, args: args, globals: globals}
+	if len(rf.sweep) > 0 {
+		if rf.search.Enabled {
+			return errors.New("-sweep and -search can not be used together")
+		}
+		// 未加引号时 `-sweep workers=1,8 body_size=1KB,64KB` 中之后的维度为位置参数
+		for _, arg := range fs.Args() {
+			if err := rf.sweep.Set(arg); err != nil {
+				return err
+			}
+		}
+		return cr.runSweep(rf.sweep)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if rf.search.Enabled {
+		return cr.runSearch(rf.search)
+	}
+
+	r := c.Runner()
+	result, err := r.Run()
+	if err != nil {
+		return err
+	}
+	return r.Report(result)
+}
+
+// runnerFlags 为基于 Runner 的子命令额外支持的执行模式
+type runnerFlags struct {
+	sweep  Sweep
+	search SearchConfig
+}
+
+func (f *runnerFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.sweep, "sweep", "sweep flags over the cartesian product of values and print a matrix table, e.g. \"workers=1,8,64 body_size=1KB,64KB\"")
+	f.search.RegisterFlags(fs)
+}
+
+// commandRunner 使用相同的命令行参数多次创建子命令的 Runner 用于 sweep 以及 search 模式
+type commandRunner struct {
+	prog    string
+	cmd     *Command
+	args    []string
+	globals *flag.FlagSet
+}
+
+// runner 重新解析命令行参数后使用 values 覆盖 names 对应的参数 返回创建 Runner 的函数
+//
+// 同一个子命令的参数绑定在相同的变量上 因此每次执行前需要重新解析
+func (cr *commandRunner) runner(names, values []string) (func() *Runner, error) {
+	if _, err := cr.flags(names, values); err != nil {
+		return nil, err
+	}
+	return cr.cmd.Runner, nil
+}
+
+// flags 重新解析命令行参数并使用 values 覆盖 names 对应的参数 返回解析后的参数集合
+func (cr *commandRunner) flags(names, values []string) (*flag.FlagSet, error) {
+	fs := cr.cmd.FlagSet(cr.prog)
+	fs.SetOutput(io.Discard)
+	new(runnerFlags).register(fs)
+	if err := inheritFlags(fs, cr.globals); err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(cr.args); err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		if fs.Lookup(name) == nil || name == "sweep" || strings.HasPrefix(name, "search") {
+			return nil, fmt.Errorf("unknown flag -%s", name)
+		}
+		if err := fs.Set(name, values[i]); err != nil {
+			return nil, fmt.Errorf("invalid value %q for flag -%s: %w", values[i], name, err)
+		}
+	}
+	return fs, nil
+}
+
+func inheritFlags(fs, globals *flag.FlagSet) error {
+	if globals == nil {
+		return nil
+	}
+
+	var err error
+	globals.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch {
+		case fs.Lookup(f.Name) != nil:
+			err = fs.Set(f.Name, f.Value.String())
+		case f.Name == "output" && f.Value.String() != OutputTable, f.Name == "output_file":
+			// 未注册输出参数的子命令仅以日志或者表格输出 不能静默地忽略其他的输出格式
+			err = fmt.Errorf("%s does not support -%s=%s", fs.Name(), f.Name, f.Value.String())
+		}
+	})
+	return err
+}
+
+type Commands []*Command
+
+// Lookup 返回与 args 前缀匹配的子命令以及剩余的参数
+func (cs Commands) Lookup(args []string) (*Command, []string) {
+	for _, c := range cs {
+		words := strings.Fields(c.Name)
+		if len(args) < len(words) {
+			continue
+		}
+		matched := true
+		for i, w := range words {
+			if args[i] != w {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return c, args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+// PrintUsage 输出名称以 prefix 开头的子命令列表
+func (cs Commands) PrintUsage(w io.Writer, prefix string) {
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range cs {
+		if prefix != "" && !strings.HasPrefix(c.Name+" ", prefix+" ") {
+			continue
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", c.Name, c.Usage)
+	}
+}
+
+// RegisterGlobalFlags 注册所有子命令共享的参数 包括 packetd 的访问方式以及结果输出格式
+func RegisterGlobalFlags(fs *flag.FlagSet) {
+	RegisterPacketdFlags(fs)
+	fs.String("output", OutputTable, "result output format, options: table/json/jsonl/csv")
+	fs.String("output_file", "", "write result to file instead of stdout")
+}
+
+// Main 为 packetd-bench 的入口 解析全局参数后执行对应的子命令
+//
+// 全局参数需要位于子命令之前 如 `packetd-bench -packetd_addr 10.0.0.1:9091 http client -workers 8`
+func Main(prog string, commands Commands, args []string) {
+	globals := flag.NewFlagSet(prog, flag.ContinueOnError)
+	RegisterGlobalFlags(globals)
+	globals.Usage = func() {
+		w := globals.Output()
+		fmt.Fprintf(w, "Usage: %s [global flags] <command> [flags] [args]\n\n", prog)
+		commands.PrintUsage(w, "")
+		fmt.Fprintf(w, "\nGlobal Flags:\n")
+		globals.PrintDefaults()
+		fmt.Fprintf(w, "\nUse \"%s <command> -h\" for more information about a command.\n", prog)
+	}
+
+	if err := globals.Parse(args); err != nil {
+		exitParseError(err)
+	}
+	if globals.NArg() == 0 {
+		globals.Usage()
+		os.Exit(2)
+	}
+
+	cmd, rest := commands.Lookup(globals.Args())
+	if cmd == nil {
+		w := globals.Output()
+		group := globals.Arg(0)
+		if !commands.hasGroup(group) {
+			fmt.Fprintf(w, "unknown command %q\n\n", group)
+			commands.PrintUsage(w, "")
+			os.Exit(2)
+		}
+
+		fmt.Fprintf(w, "Usage: %s %s <command> [flags] [args]\n\n", prog, group)
+		commands.PrintUsage(w, group)
+		if globals.NArg() > 1 && isHelpFlag(globals.Arg(1)) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	err := cmd.Execute(prog, rest, globals)
+	if isParseError(err) {
+		exitParseError(err)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (cs Commands) hasGroup(group string) bool {
+	for _, c := range cs {
+		if strings.HasPrefix(c.Name, group+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help", "--h":
+		return true
+	}
+	return false
+}
+
+// parseError 标记命令行参数解析失败 flag 包已经输出了错误信息以及用法
+type parseError struct {
+	err error
+}
+
+func (e *parseError) Error() string { return e.err.Error() }
+
+func (e *parseError) Unwrap() error { return e.err }
+
+func isParseError(err error) bool {
+	var pe *parseError
+	return errors.As(err, &pe)
+}
+
+func exitParseError(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
@@ -0,0 +1,297 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// SaveRecord 以 JSON Lines 格式将结果追加至 path
+func SaveRecord(path string, rec *Record) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(rec)
+}
+
+// LoadRecords 读取 json 或 jsonl 格式保存的结果
+func LoadRecords(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []*Record
+	dec := json.NewDecoder(f)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", path, err)
+		}
+		if rec.SchemaVersion != SchemaVersion {
+			return nil, fmt.Errorf("decode %s: unsupported schema version %d", path, rec.SchemaVersion)
+		}
+		records = append(records, &rec)
+	}
+	return records, nil
+}
+
+// Key 返回用于对齐两次压测结果的标识 由协议以及压测参数组成
+func (r *Record) Key() string {
+	c := r.Config
+	if c == nil {
+		c = &RecordConfig{}
+	}
+
+	params := make([]string, 0, len(c.Params))
+	for k, v := range c.Params {
+		params = append(params, k+"="+v)
+	}
+	sort.Strings(params)
+
+	// 按时间压测时请求总数不具备参考意义
+	total := strconv.Itoa(c.Total)
+	if c.Duration != "" && c.Duration != "0s" {
+		total = ""
+	}
+
+	parts := []string{
+		r.Proto,
+		"workers=" + strconv.Itoa(c.Workers),
+		"total=" + total,
+		"rate=" + strconv.FormatFloat(c.Rate, 'f', -1, 64),
+		"duration=" + c.Duration,
+		"profile=" + c.Profile,
+		"body_size=" + strconv.Itoa(c.BodySize),
+	}
+	parts = append(parts, params...)
+	return strings.Join(parts, ",")
+}
+
+// Metric 为参与对比的指标
+type Metric struct {
+	Name  string
+	Value func(r *Record) float64
+}
+
+var CompareMetrics = []Metric{
+	{Name: "qps", Value: func(r *Record) float64 { return r.QPS }},
+	{Name: "p50", Value: func(r *Record) float64 { return r.Latency.P50 }},
+	{Name: "p99", Value: func(r *Record) float64 { return r.Latency.P99 }},
+	{Name: "capture", Value: func(r *Record) float64 { return r.Packetd.CapturePercent }},
+	{Name: "cpu", Value: func(r *Record) float64 { return r.Resource.CPUCores }},
+	{Name: "memory", Value: func(r *Record) float64 { return r.Resource.MemoryBytes }},
+}
+
+// Tolerance 为指标允许的相对变化
+//
+// Percent 为正数时表示指标增长超过 Percent% 视为退化 负数时表示下降超过 |Percent|% 视为退化
+type Tolerance struct {
+	Metric  string
+	Percent float64
+}
+
+// DefaultTolerances 为默认的退化判定阈值
+const DefaultTolerances = "capture=-0.1%,cpu=+10%,memory=+10%"
+
+// ParseTolerances 解析 `cpu=+10%,capture=-0.1%` 格式的阈值
+func ParseTolerances(s string) (map[string]Tolerance, error) {
+	known := make(map[string]bool)
+	for _, m := range CompareMetrics {
+		known[m.Name] = true
+	}
+
+	tolerances := make(map[string]Tolerance)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tolerance %q", part)
+		}
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+
+		value = strings.TrimSuffix(strings.TrimSpace(value), "%")
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f == 0 {
+			return nil, fmt.Errorf("invalid tolerance %q", part)
+		}
+		tolerances[name] = Tolerance{Metric: name, Percent: f}
+	}
+	return tolerances, nil
+}
+
+// Delta 为单个指标的对比结果
+type Delta struct {
+	Key       string
+	Stage     string
+	Metric    string
+	Base      float64
+	Current   float64
+	Percent   float64
+	Tolerance *Tolerance
+	Regressed bool
+}
+
+// Compare 对齐 base 以及 current 中协议和参数相同的结果并计算各指标的变化
+//
+// 同一份文件中存在多条相同标识的结果时使用最后一条 只存在于其中一份文件中的结果会被忽略
+func Compare(base, current []*Record, tolerances map[string]Tolerance) ([]Delta, []string) {
+	index := func(records []*Record) (map[string]*Record, []string) {
+		m := make(map[string]*Record)
+		var keys []string
+		for _, r := range records {
+			key := r.Key()
+			if _, ok := m[key]; !ok {
+				keys = append(keys, key)
+			}
+			m[key] = r
+		}
+		return m, keys
+	}
+
+	baseIndex, _ := index(base)
+	currIndex, keys := index(current)
+
+	var deltas []Delta
+	var unmatched []string
+	for _, key := range keys {
+		b, ok := baseIndex[key]
+		if !ok {
+			unmatched = append(unmatched, key)
+			continue
+		}
+		c := currIndex[key]
+		deltas = append(deltas, compareRecord(key, "", b, c, tolerances)...)
+
+		stages := make(map[string]*Record)
+		for _, s := range b.Stages {
+			stages[s.Stage] = s
+		}
+		for _, s := range c.Stages {
+			if bs, ok := stages[s.Stage]; ok {
+				deltas = append(deltas, compareRecord(key, s.Stage, bs, s, tolerances)...)
+			}
+		}
+	}
+	return deltas, unmatched
+}
+
+func compareRecord(key, stage string, base, current *Record, tolerances map[string]Tolerance) []Delta {
+	deltas := make([]Delta, 0, len(CompareMetrics))
+	for _, m := range CompareMetrics {
+		d := Delta{
+			Key:     key,
+			Stage:   stage,
+			Metric:  m.Name,
+			Base:    m.Value(base),
+			Current: m.Value(current),
+		}
+		if d.Base != 0 {
+			d.Percent = (d.Current - d.Base) / math.Abs(d.Base) * 100
+		}
+		if t, ok := tolerances[m.Name]; ok {
+			d.Tolerance = &t
+			if t.Percent > 0 {
+				d.Regressed = d.Percent > t.Percent
+			} else {
+				d.Regressed = d.Percent < t.Percent
+			}
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// PrintCompareTable 输出对比结果
+func PrintCompareTable(w io.Writer, deltas []Delta) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader([]interface{}{
+		"run",
+		"stage",
+		"metric",
+		"base",
+		"current",
+		"delta",
+		"tolerance",
+		"status",
+	})
+
+	var last string
+	for _, d := range deltas {
+		run := d.Key
+		if run == last {
+			run = ""
+		} else if last != "" {
+			t.AppendSeparator()
+		}
+		last = d.Key
+
+		tolerance := "-"
+		status := "ok"
+		if d.Tolerance != nil {
+			tolerance = fmt.Sprintf("%+g%%", d.Tolerance.Percent)
+		}
+		if d.Regressed {
+			status = "REGRESSION"
+		}
+		t.AppendRow([]interface{}{
+			run,
+			d.Stage,
+			d.Metric,
+			formatMetric(d.Metric, d.Base),
+			formatMetric(d.Metric, d.Current),
+			fmt.Sprintf("%+.3f%%", d.Percent),
+			tolerance,
+			status,
+		})
+	}
+	t.Render()
+}
+
+func formatMetric(name string, v float64) string {
+	switch name {
+	case "p50", "p99":
+		return fmt.Sprintf("%.3fms", v)
+	case "capture":
+		return fmt.Sprintf("%.3f%%", v)
+	case "memory":
+		return fmt.Sprintf("%.3fMB", v/1024/1024)
+	}
+	return fmt.Sprintf("%.3f", v)
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"flag"
+	"time"
+)
+
+// ConvergeConfig 控制压测结束后等待 packetd 协议指标稳定的方式
+type ConvergeConfig struct {
+	// Interval 为轮询间隔
+	Interval time.Duration
+
+	// Quiet 为判定稳定所需的计数器无变化时长
+	Quiet time.Duration
+
+	// Timeout 为最长等待时间
+	Timeout time.Duration
+}
+
+func (c *ConvergeConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.Interval, "converge_interval", 50*time.Millisecond, "packetd protocol metrics polling interval after the run")
+	fs.DurationVar(&c.Quiet, "converge_quiet", 500*time.Millisecond, "consider protocol counter converged after it stays unchanged for this period")
+	fs.DurationVar(&c.Timeout, "converge_timeout", 10*time.Second, "max time waiting for protocol counter to converge")
+}
+
+// Convergence 为等待协议指标稳定的结果
+type Convergence struct {
+	// Samples 为最后一次采集到的协议指标
+	Samples Samples
+
+	// Lag 为从开始等待到计数器最后一次变化（或达到预期值）的耗时 即 packetd 的处理延迟
+	// 等待期间计数器没有变化时为 0
+	Lag time.Duration
+
+	// TimedOut 表示在超时之前计数器仍未稳定
+	TimedOut bool
+}
+
+// WaitProtocolStable 轮询 packetd 协议指标 直到计数器 metric 相对于 base 的增量达到 expected
+// 或者在 Quiet 时长内不再变化 最长等待 Timeout
+func WaitProtocolStable(conf ConvergeConfig, metric string, base Samples, expected float64) (*Convergence, error) {
+	if conf.Interval <= 0 {
+		conf.Interval = 50 * time.Millisecond
+	}
+
+	start := time.Now()
+	lastChange := start
+	var lastValue float64
+
+	for first := true; ; first = false {
+		samples, err := RequestProtocolMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		value, _ := Increase(base, samples, metric, nil)
+		if value >= expected {
+			return &Convergence{Samples: samples, Lag: now.Sub(start)}, nil
+		}
+		// 第一次采样作为比较的基准 不视为变化
+		switch {
+		case first:
+			lastValue = value
+		case value != lastValue:
+			lastValue = value
+			lastChange = now
+		}
+		if now.Sub(lastChange) >= conf.Quiet {
+			return &Convergence{Samples: samples, Lag: lastChange.Sub(start)}, nil
+		}
+		if now.Sub(start) >= conf.Timeout {
+			return &Convergence{Samples: samples, Lag: now.Sub(start), TimedOut: true}, nil
+		}
+		time.Sleep(conf.Interval)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProtocolMetrics 启动返回 http_requests_total 的 packetd 协议指标接口 计数器的取值由 value 决定
+func fakeProtocolMetrics(t *testing.T, value func(poll int64) int64) {
+	var polls atomic.Int64
+	fakePacketd(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "http_requests_total %d\n", value(polls.Add(1)))
+	})
+}
+
+// fakePacketd 将全局 packetd 配置指向由 h 处理请求的测试服务 测试结束后恢复
+func fakePacketd(t *testing.T, h http.HandlerFunc) {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	conf := Packetd()
+	addr, path := conf.Addr, conf.ProtocolPath
+	conf.Addr, conf.ProtocolPath = srv.URL, "/protocol/metrics"
+	t.Cleanup(func() {
+		conf.Addr, conf.ProtocolPath = addr, path
+	})
+}
+
+func TestWaitProtocolStable(t *testing.T) {
+	conf := ConvergeConfig{Interval: 5 * time.Millisecond, Quiet: 50 * time.Millisecond, Timeout: 2 * time.Second}
+
+	t.Run("nothing captured", func(t *testing.T) {
+		fakeProtocolMetrics(t, func(int64) int64 { return 7 })
+		base, err := RequestProtocolMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cvg, err := WaitProtocolStable(conf, "http_requests_total", base, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cvg.TimedOut || cvg.Lag != 0 {
+			t.Errorf("want converged with zero lag, got lag=%v timed_out=%v", cvg.Lag, cvg.TimedOut)
+		}
+	})
+
+	t.Run("partially captured", func(t *testing.T) {
+		// 第 1 次请求作为 base 之后的 5 次采样中计数器持续增长
+		fakeProtocolMetrics(t, func(poll int64) int64 { return min(poll, 6) })
+		base, err := RequestProtocolMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cvg, err := WaitProtocolStable(conf, "http_requests_total", base, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cvg.TimedOut || cvg.Lag <= 0 {
+			t.Errorf("want converged with positive lag, got lag=%v timed_out=%v", cvg.Lag, cvg.TimedOut)
+		}
+	})
+
+	t.Run("expected reached", func(t *testing.T) {
+		fakeProtocolMetrics(t, func(poll int64) int64 { return poll * 10 })
+		base, err := RequestProtocolMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cvg, err := WaitProtocolStable(conf, "http_requests_total", base, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cvg.TimedOut {
+			t.Error("want converged")
+		}
+	})
+}
@@ -0,0 +1,163 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// 操作失败的错误类型
+const (
+	ErrorTimeout  = "timeout"
+	ErrorRefused  = "refused"
+	ErrorReset    = "reset"
+	ErrorProtocol = "protocol"
+	ErrorStatus   = "status"
+	ErrorOther    = "other"
+)
+
+// StatusError 表示服务端返回了非预期的状态 如 HTTP 非 2xx 状态码或者数据库返回的错误
+type StatusError struct {
+	Status string
+}
+
+func NewStatusError(format string, a ...any) error {
+	return &StatusError{Status: fmt.Sprintf(format, a...)}
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status: " + e.Status
+}
+
+// ProtocolError 表示响应不符合协议规范
+type ProtocolError struct {
+	Err error
+}
+
+func NewProtocolError(err error) error {
+	return &ProtocolError{Err: err}
+}
+
+func (e *ProtocolError) Error() string {
+	return "protocol error: " + e.Err.Error()
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError 返回 err 所属的错误类型
+//
+// 部分客户端不会保留底层的网络错误 无法通过 errors.Is 判断时退化为匹配错误信息
+func ClassifyError(err error) string {
+	var se *StatusError
+	var pe *ProtocolError
+	var ne net.Error
+	switch {
+	case errors.As(err, &se):
+		return ErrorStatus
+	case errors.As(err, &pe):
+		return ErrorProtocol
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &ne) && ne.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorReset
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return ErrorTimeout
+	case strings.Contains(msg, "connection refused"):
+		return ErrorRefused
+	case strings.Contains(msg, "connection reset"), strings.Contains(msg, "broken pipe"), strings.Contains(msg, "eof"):
+		return ErrorReset
+	}
+	return ErrorOther
+}
+
+// ErrorCounts 为各错误类型的出现次数
+type ErrorCounts map[string]int64
+
+func (c ErrorCounts) Total() int64 {
+	var n int64
+	for _, v := range c {
+		n += v
+	}
+	return n
+}
+
+func (c ErrorCounts) Merge(o ErrorCounts) {
+	for k, v := range o {
+		c[k] += v
+	}
+}
+
+// String 按照错误类型排序输出 如 `refused=3 timeout=1`
+func (c ErrorCounts) String() string {
+	classes := make([]string, 0, len(c))
+	for k := range c {
+		classes = append(classes, k)
+	}
+	sort.Strings(classes)
+
+	parts := make([]string, 0, len(classes))
+	for _, k := range classes {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, c[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// errorCounter 并发安全地按照错误类型计数
+type errorCounter struct {
+	mu     sync.Mutex
+	total  int64
+	counts ErrorCounts
+}
+
+func newErrorCounter() *errorCounter {
+	return &errorCounter{counts: make(ErrorCounts)}
+}
+
+// add 记录一次错误 返回该错误是否为对应类型的第一次出现以及当前的错误总数
+func (c *errorCounter) add(class string) (bool, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total++
+	c.counts[class]++
+	return c.counts[class] == 1, c.total
+}
+
+func (c *errorCounter) snapshot() ErrorCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(ErrorCounts, len(c.counts))
+	counts.Merge(c.counts)
+	return counts
+}
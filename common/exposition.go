@@ -0,0 +1,254 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels 为时间序列的标签集合
+type Labels map[string]string
+
+// Matches 判断 l 是否包含 match 中的所有标签
+func (l Labels) Matches(match Labels) bool {
+	for k, v := range match {
+		if l[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Sample 为 Prometheus 文本格式中的一条样本
+type Sample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+
+	// Timestamp 为样本携带的毫秒时间戳 未携带时为 0
+	Timestamp int64
+}
+
+type Samples []Sample
+
+// Filter 返回指标名称为 name 且包含 match 中所有标签的样本
+func (s Samples) Filter(name string, match Labels) Samples {
+	var ret Samples
+	for _, sample := range s {
+		if sample.Name == name && sample.Labels.Matches(match) {
+			ret = append(ret, sample)
+		}
+	}
+	return ret
+}
+
+// Sum 返回指标名称为 name 且包含 match 中所有标签的样本之和
+func (s Samples) Sum(name string, match Labels) float64 {
+	var total float64
+	for _, sample := range s.Filter(name, match) {
+		total += sample.Value
+	}
+	return total
+}
+
+// Get 返回指标 name 所有时间序列之和
+func (s Samples) Get(name string) float64 {
+	return s.Sum(name, nil)
+}
+
+// Key 返回样本所属时间序列的唯一标识
+func (s Sample) Key() string {
+	names := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(s.Labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Increase 计算计数器 name 从 start 到 end 的增量 返回增量以及检测到的重置次数
+//
+// 按时间序列逐条计算 end 中的值小于 start 或者时间序列消失时视为计数器被重置
+// 重置后的时间序列以 end 中的值作为增量 start 中不存在的时间序列视为从 0 开始
+func Increase(start, end Samples, name string, match Labels) (float64, int) {
+	prev := make(map[string]float64)
+	for _, sample := range start.Filter(name, match) {
+		prev[sample.Key()] = sample.Value
+	}
+
+	var delta float64
+	var resets int
+	for _, sample := range end.Filter(name, match) {
+		key := sample.Key()
+		v, ok := prev[key]
+		delete(prev, key)
+		if sample.Value < v {
+			resets++
+			delta += sample.Value
+			continue
+		}
+		if ok {
+			delta += sample.Value - v
+		} else {
+			delta += sample.Value
+		}
+	}
+	return delta, resets + len(prev)
+}
+
+// ParseText 解析 Prometheus 文本格式
+func ParseText(r io.Reader) (Samples, error) {
+	var samples Samples
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*KB), MB)
+
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sample, err := parseSampleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+func parseSampleLine(line string) (Sample, error) {
+	var sample Sample
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	sample.Name = line[:end]
+	line = line[end:]
+
+	if strings.HasPrefix(line, "{") {
+		labels, rest, err := parseLabels(line[1:])
+		if err != nil {
+			return sample, err
+		}
+		sample.Labels = labels
+		line = rest
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return sample, fmt.Errorf("invalid sample value %q", line)
+	}
+
+	v, err := parseSampleValue(fields[0])
+	if err != nil {
+		return sample, err
+	}
+	sample.Value = v
+
+	if len(fields) == 2 {
+		ts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		sample.Timestamp = ts
+	}
+	return sample, nil
+}
+
+// parseLabels 解析 `{` 之后的标签 返回 `}` 之后的剩余内容
+func parseLabels(s string) (Labels, string, error) {
+	labels := make(Labels)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid label in %q", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, `"`) {
+			return nil, "", fmt.Errorf("label %q value not quoted", name)
+		}
+
+		var b strings.Builder
+		i := 1
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '"' {
+				break
+			}
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				default:
+					b.WriteByte(s[i])
+				}
+				continue
+			}
+			b.WriteByte(c)
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("label %q value not terminated", name)
+		}
+		labels[name] = b.String()
+
+		s = strings.TrimLeft(s[i+1:], " \t")
+		s = strings.TrimPrefix(s, ",")
+	}
+}
+
+func parseSampleValue(s string) (float64, error) {
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
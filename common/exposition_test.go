@@ -0,0 +1,162 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseText(t *testing.T, text string) Samples {
+	t.Helper()
+	samples, err := ParseText(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("ParseText: %v", err)
+	}
+	return samples
+}
+
+func TestParseText(t *testing.T) {
+	samples := mustParseText(t, `
+# HELP http_requests_total The total number of http requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",path="/a\"b\\c\nd"} 12 1700000000000
+http_requests_total{ method = "POST" , } 3.5
+process_cpu_seconds_total 1e3
+up +Inf
+down -Inf
+ratio NaN
+`)
+
+	want := Samples{
+		{Name: "http_requests_total", Labels: Labels{"method": "GET", "path": "/a\"b\\c\nd"}, Value: 12, Timestamp: 1700000000000},
+		{Name: "http_requests_total", Labels: Labels{"method": "POST"}, Value: 3.5},
+		{Name: "process_cpu_seconds_total", Value: 1000},
+		{Name: "up", Value: math.Inf(1)},
+		{Name: "down", Value: math.Inf(-1)},
+	}
+	if len(samples) != len(want)+1 {
+		t.Fatalf("want %d samples, got %d", len(want)+1, len(samples))
+	}
+	if !reflect.DeepEqual(samples[:len(want)], want) {
+		t.Errorf("got %+v, want %+v", samples[:len(want)], want)
+	}
+	if last := samples[len(want)]; last.Name != "ratio" || !math.IsNaN(last.Value) {
+		t.Errorf("want ratio NaN, got %+v", last)
+	}
+
+	if got := samples.Get("http_requests_total"); got != 15.5 {
+		t.Errorf("Get: want 15.5, got %v", got)
+	}
+	if got := samples.Sum("http_requests_total", Labels{"method": "POST"}); got != 3.5 {
+		t.Errorf("Sum: want 3.5, got %v", got)
+	}
+}
+
+func TestParseTextMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "missing name", line: `{method="GET"} 1`},
+		{name: "missing value", line: `http_requests_total`},
+		{name: "missing value with labels", line: `http_requests_total{method="GET"}`},
+		{name: "too many fields", line: `http_requests_total 1 2 3`},
+		{name: "invalid value", line: `http_requests_total one`},
+		{name: "invalid timestamp", line: `http_requests_total 1 now`},
+		{name: "unquoted label value", line: `http_requests_total{method=GET} 1`},
+		{name: "unterminated label value", line: `http_requests_total{method="GET} 1`},
+		{name: "missing label name", line: `http_requests_total{="GET"} 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseText(strings.NewReader("# TYPE http_requests_total counter\n" + tt.line + "\n"))
+			if err == nil {
+				t.Fatalf("want error for %q", tt.line)
+			}
+			if !strings.HasPrefix(err.Error(), "line 2: ") {
+				t.Errorf("want error with line number, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIncrease(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		match      Labels
+		delta      float64
+		resets     int
+	}{
+		{
+			name:  "delta",
+			start: `http_requests_total{path="/a"} 10` + "\n" + `http_requests_total{path="/b"} 5`,
+			end:   `http_requests_total{path="/a"} 15` + "\n" + `http_requests_total{path="/b"} 7`,
+			delta: 7,
+		},
+		{
+			name:   "reset",
+			start:  `http_requests_total{path="/a"} 100` + "\n" + `http_requests_total{path="/b"} 5`,
+			end:    `http_requests_total{path="/a"} 3` + "\n" + `http_requests_total{path="/b"} 7`,
+			delta:  5,
+			resets: 1,
+		},
+		{
+			name:  "new series",
+			start: `http_requests_total{path="/a"} 10`,
+			end:   `http_requests_total{path="/a"} 12` + "\n" + `http_requests_total{path="/b"} 4`,
+			delta: 6,
+		},
+		{
+			name:   "missing series",
+			start:  `http_requests_total{path="/a"} 10` + "\n" + `http_requests_total{path="/b"} 5`,
+			end:    `http_requests_total{path="/a"} 12`,
+			delta:  2,
+			resets: 1,
+		},
+		{
+			name:  "missing metric",
+			start: `grpc_requests_total 10`,
+			end:   `grpc_requests_total 20`,
+		},
+		{
+			name:  "label order",
+			start: `http_requests_total{path="/a",method="GET"} 10`,
+			end:   `http_requests_total{method="GET",path="/a"} 11`,
+			delta: 1,
+		},
+		{
+			name:  "match",
+			start: `http_requests_total{path="/a"} 10` + "\n" + `http_requests_total{path="/b"} 5`,
+			end:   `http_requests_total{path="/a"} 15` + "\n" + `http_requests_total{path="/b"} 0`,
+			match: Labels{"path": "/a"},
+			delta: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := mustParseText(t, tt.start)
+			end := mustParseText(t, tt.end)
+			delta, resets := Increase(start, end, "http_requests_total", tt.match)
+			if delta != tt.delta || resets != tt.resets {
+				t.Errorf("want delta=%v resets=%d, got delta=%v resets=%d", tt.delta, tt.resets, delta, resets)
+			}
+		})
+	}
+}
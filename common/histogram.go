@@ -0,0 +1,221 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// subBucketBits 决定了直方图的精度 2^11 个子桶约等于 3 位有效数字
+	subBucketBits      = 11
+	subBucketCount     = 1 << subBucketBits
+	subBucketHalfCount = subBucketCount / 2
+
+	bucketCount = 64 - subBucketBits + 1
+	countsLen   = subBucketCount + (bucketCount-1)*subBucketHalfCount
+)
+
+// Histogram 是 HDR 风格的延迟直方图
+//
+// 数值按指数分桶 每个桶内再线性划分子桶 保证任意数值的相对误差不超过 1/subBucketHalfCount
+// 所有方法均可并发调用
+type Histogram struct {
+	counts []int64
+	total  atomic.Int64
+	sum    atomic.Int64
+	min    atomic.Int64
+	max    atomic.Int64
+}
+
+func NewHistogram() *Histogram {
+	h := &Histogram{
+		counts: make([]int64, countsLen),
+	}
+	h.min.Store(math.MaxInt64)
+	return h
+}
+
+func countsIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	sub := int(v >> shift)
+	return subBucketCount + (shift-1)*subBucketHalfCount + (sub - subBucketHalfCount)
+}
+
+// valueFromIndex 返回索引对应子桶内的最大值
+func valueFromIndex(idx int) int64 {
+	if idx < subBucketCount {
+		return int64(idx)
+	}
+	shift := (idx-subBucketCount)/subBucketHalfCount + 1
+	sub := int64((idx-subBucketCount)%subBucketHalfCount + subBucketHalfCount)
+	return (sub+1)<<shift - 1
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+
+	atomic.AddInt64(&h.counts[countsIndex(v)], 1)
+	h.total.Add(1)
+	h.sum.Add(v)
+
+	for {
+		old := h.min.Load()
+		if v >= old || h.min.CompareAndSwap(old, v) {
+			break
+		}
+	}
+	for {
+		old := h.max.Load()
+		if v <= old || h.max.CompareAndSwap(old, v) {
+			break
+		}
+	}
+}
+
+// Merge 将 o 的所有记录合并到 h 中
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil || o.Count() == 0 {
+		return
+	}
+	for i := range o.counts {
+		if n := atomic.LoadInt64(&o.counts[i]); n > 0 {
+			atomic.AddInt64(&h.counts[i], n)
+		}
+	}
+	h.total.Add(o.total.Load())
+	h.sum.Add(o.sum.Load())
+
+	for {
+		old, v := h.min.Load(), o.min.Load()
+		if v >= old || h.min.CompareAndSwap(old, v) {
+			break
+		}
+	}
+	for {
+		old, v := h.max.Load(), o.max.Load()
+		if v <= old || h.max.CompareAndSwap(old, v) {
+			break
+		}
+	}
+}
+
+func (h *Histogram) Count() int64 {
+	return h.total.Load()
+}
+
+func (h *Histogram) Min() time.Duration {
+	if h.Count() == 0 {
+		return 0
+	}
+	return time.Duration(h.min.Load())
+}
+
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max.Load())
+}
+
+func (h *Histogram) Mean() time.Duration {
+	n := h.Count()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(h.sum.Load() / n)
+}
+
+// Percentile 返回百分位 p (0~100) 对应的延迟
+func (h *Histogram) Percentile(p float64) time.Duration {
+	total := h.Count()
+	if total == 0 {
+		return 0
+	}
+
+	target := int64(math.Ceil(p / 100 * float64(total)))
+	if target < 1 {
+		target = 1
+	}
+
+	var cumulative int64
+	for i := range h.counts {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		if cumulative >= target {
+			return h.clamp(valueFromIndex(i))
+		}
+	}
+	return h.Max()
+}
+
+func (h *Histogram) clamp(v int64) time.Duration {
+	if max := h.max.Load(); v > max {
+		v = max
+	}
+	if min := h.min.Load(); v < min {
+		v = min
+	}
+	return time.Duration(v)
+}
+
+// WriteDistribution 以 HdrHistogram 的 percentile 格式输出完整分布 数值单位为毫秒
+func (h *Histogram) WriteDistribution(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"); err != nil {
+		return err
+	}
+
+	total := h.Count()
+	var cumulative int64
+	for i := range h.counts {
+		n := atomic.LoadInt64(&h.counts[i])
+		if n == 0 {
+			continue
+		}
+		cumulative += n
+
+		pct := float64(cumulative) / float64(total)
+		inverse := "inf"
+		if pct < 1 {
+			inverse = fmt.Sprintf("%.2f", 1/(1-pct))
+		}
+		value := float64(h.clamp(valueFromIndex(i))) / float64(time.Millisecond)
+		if _, err := fmt.Fprintf(w, "%12.3f %14.12f %10d %14s\n", value, pct, cumulative, inverse); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "#[Mean    = %12.3f, Max   = %12.3f]\n#[Min     = %12.3f, Count = %12d]\n",
+		float64(h.Mean())/float64(time.Millisecond),
+		float64(h.Max())/float64(time.Millisecond),
+		float64(h.Min())/float64(time.Millisecond),
+		total,
+	)
+	return err
+}
+
+// FormatLatency 将延迟格式化为便于阅读的字符串 保留微秒精度
+func FormatLatency(d time.Duration) string {
+	if d > time.Microsecond {
+		d = d.Round(time.Microsecond)
+	}
+	return d.String()
+}
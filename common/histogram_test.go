@@ -0,0 +1,140 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withinPrecision 判断 got 与 want 的相对误差是否在直方图的精度之内
+func withinPrecision(got, want time.Duration) bool {
+	return math.Abs(float64(got-want)) <= float64(want)/subBucketHalfCount
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	if h.Count() != 0 || h.Min() != 0 || h.Max() != 0 || h.Mean() != 0 {
+		t.Errorf("want zero stats, got count=%d min=%v max=%v mean=%v", h.Count(), h.Min(), h.Max(), h.Mean())
+	}
+	for _, p := range []float64{0, 50, 99.9, 100} {
+		if v := h.Percentile(p); v != 0 {
+			t.Errorf("Percentile(%v): want 0, got %v", p, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteDistribution(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "NaN") {
+		t.Errorf("distribution of empty histogram contains NaN:\n%s", buf.String())
+	}
+}
+
+func TestHistogramUniform(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	if h.Count() != 10000 {
+		t.Errorf("Count: want 10000, got %d", h.Count())
+	}
+	if h.Min() != time.Microsecond || h.Max() != 10*time.Millisecond {
+		t.Errorf("want min=1µs max=10ms, got min=%v max=%v", h.Min(), h.Max())
+	}
+	if want := 5000500 * time.Nanosecond; h.Mean() != want {
+		t.Errorf("Mean: want %v, got %v", want, h.Mean())
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Microsecond},
+		{p: 50, want: 5 * time.Millisecond},
+		{p: 90, want: 9 * time.Millisecond},
+		{p: 99, want: 9900 * time.Microsecond},
+		{p: 99.9, want: 9990 * time.Microsecond},
+		{p: 100, want: 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := h.Percentile(tt.p); !withinPrecision(got, tt.want) {
+			t.Errorf("Percentile(%v): want %v, got %v", tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestHistogramBimodal(t *testing.T) {
+	h := NewHistogram()
+	for i := 0; i < 990; i++ {
+		h.Record(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.Record(time.Second)
+	}
+
+	if got := h.Percentile(99); !withinPrecision(got, time.Millisecond) {
+		t.Errorf("Percentile(99): want 1ms, got %v", got)
+	}
+	if got := h.Percentile(99.1); got != time.Second {
+		t.Errorf("Percentile(99.1): want 1s, got %v", got)
+	}
+	if want := (990*time.Millisecond + 10*time.Second) / 1000; h.Mean() != want {
+		t.Errorf("Mean: want %v, got %v", want, h.Mean())
+	}
+}
+
+func TestHistogramSmallValuesExact(t *testing.T) {
+	h := NewHistogram()
+	h.Record(-time.Nanosecond)
+	for i := 1; i < subBucketCount; i++ {
+		h.Record(time.Duration(i))
+	}
+
+	if h.Min() != 0 {
+		t.Errorf("negative values are recorded as 0, got min=%v", h.Min())
+	}
+	if got := h.Percentile(50); got != subBucketHalfCount-1 {
+		t.Errorf("Percentile(50): want %d, got %d", subBucketHalfCount-1, got)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	for i := 1; i <= 100; i++ {
+		a.Record(time.Duration(i) * time.Millisecond)
+		b.Record(time.Duration(i+100) * time.Millisecond)
+	}
+	a.Merge(NewHistogram())
+	a.Merge(nil)
+	a.Merge(b)
+
+	if a.Count() != 200 || a.Min() != time.Millisecond || a.Max() != 200*time.Millisecond {
+		t.Errorf("want count=200 min=1ms max=200ms, got count=%d min=%v max=%v", a.Count(), a.Min(), a.Max())
+	}
+	if got := a.Percentile(50); !withinPrecision(got, 100*time.Millisecond) {
+		t.Errorf("Percentile(50): want 100ms, got %v", got)
+	}
+
+	empty := NewHistogram()
+	empty.Merge(b)
+	if empty.Min() != 101*time.Millisecond || empty.Count() != 100 {
+		t.Errorf("merge into empty histogram: want min=101ms count=100, got min=%v count=%d", empty.Min(), empty.Count())
+	}
+}
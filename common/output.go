@@ -0,0 +1,355 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputJSONL = "jsonl"
+	OutputCSV   = "csv"
+)
+
+// SchemaVersion 为结构化输出的版本号 字段发生不兼容变更时递增
+const SchemaVersion = 1
+
+// Record 为结构化输出的单条结果
+type Record struct {
+	SchemaVersion int       `json:"schema_version"`
+	Timestamp     time.Time `json:"timestamp"`
+	Name          string    `json:"name,omitempty"`
+	Proto         string    `json:"proto"`
+	Stage         string    `json:"stage,omitempty"`
+
+	Config *RecordConfig `json:"config,omitempty"`
+
+	Requests       int     `json:"requests"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	QPS            float64 `json:"qps"`
+	BPS            float64 `json:"bps"`
+	RequestBPS     float64 `json:"request_bps,omitempty"`
+	ResponseBPS    float64 `json:"response_bps,omitempty"`
+	Late           int64   `json:"late"`
+	Dropped        int64   `json:"dropped"`
+	Aborted        bool    `json:"aborted"`
+
+	Errors   ErrorRecord    `json:"errors"`
+	Latency  LatencyRecord  `json:"latency"`
+	Packetd  PacketdRecord  `json:"packetd"`
+	Resource ResourceRecord `json:"resource"`
+
+	Stages []*Record `json:"stages,omitempty"`
+}
+
+// RecordConfig 为产生该结果的压测参数
+type RecordConfig struct {
+	Workers  int               `json:"workers"`
+	Total    int               `json:"total"`
+	Interval string            `json:"interval"`
+	Rate     float64           `json:"rate"`
+	Duration string            `json:"duration"`
+	Warmup   string            `json:"warmup"`
+	Cooldown string            `json:"cooldown"`
+	Profile  string            `json:"profile"`
+	BodySize int               `json:"body_size"`
+	Params   map[string]string `json:"params"`
+}
+
+type ErrorRecord struct {
+	Count   int64       `json:"count"`
+	Percent float64     `json:"percent"`
+	Classes ErrorCounts `json:"classes"`
+}
+
+// LatencyRecord 中的延迟单位均为毫秒
+type LatencyRecord struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	P999  float64 `json:"p999_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+type PacketdRecord struct {
+	ProtoRequests  float64 `json:"proto_requests"`
+	ProtoResets    int     `json:"proto_resets"`
+	CapturePercent float64 `json:"capture_percent"`
+	CaptureLagMS   float64 `json:"capture_lag_ms"`
+	Converged      bool    `json:"converged"`
+}
+
+type ResourceRecord struct {
+	CPUCores       float64 `json:"cpu_cores"`
+	MemoryBytes    float64 `json:"memory_bytes"`
+	PeakCPUCores   float64 `json:"peak_cpu_cores"`
+	PeakRSSBytes   float64 `json:"peak_rss_bytes"`
+	PeakHeapBytes  float64 `json:"peak_heap_bytes"`
+	PeakGoroutines float64 `json:"peak_goroutines"`
+	CtxSwitches    float64 `json:"ctx_switches"`
+	ReadBytes      float64 `json:"read_bytes"`
+	WriteBytes     float64 `json:"write_bytes"`
+}
+
+func NewRecord(conf RunConfig, r *Result) *Record {
+	rec := newRecord(r)
+	params := make(map[string]string, len(r.Columns))
+	for _, c := range r.Columns {
+		params[c.Name] = fmt.Sprint(c.Value)
+	}
+	rec.Config = &RecordConfig{
+		Workers:  conf.Workers,
+		Total:    conf.Total,
+		Interval: conf.Interval.String(),
+		Rate:     conf.Rate,
+		Duration: conf.Duration.String(),
+		Warmup:   conf.Warmup.String(),
+		Cooldown: conf.Cooldown.String(),
+		Profile:  conf.Profile,
+		BodySize: conf.BodySize,
+		Params:   params,
+	}
+	for _, stage := range r.Stages {
+		rec.Stages = append(rec.Stages, newRecord(stage))
+	}
+	return rec
+}
+
+func newRecord(r *Result) *Record {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return &Record{
+		SchemaVersion:  SchemaVersion,
+		Timestamp:      time.Now(),
+		Proto:          r.Proto,
+		Stage:          r.Stage,
+		Requests:       r.Total,
+		ElapsedSeconds: r.Elapsed.Seconds(),
+		QPS:            r.QPS(),
+		BPS:            r.BPS() * 8,
+		RequestBPS:     r.RequestBPS() * 8,
+		ResponseBPS:    r.ResponseBPS() * 8,
+		Late:           r.Late,
+		Dropped:        r.Dropped,
+		Aborted:        r.Aborted,
+		Errors: ErrorRecord{
+			Count:   r.Errors.Total(),
+			Percent: r.ErrorPercent(),
+			Classes: r.Errors,
+		},
+		Latency: LatencyRecord{
+			Count: r.Latency.Count(),
+			Mean:  ms(r.Latency.Mean()),
+			P50:   ms(r.Latency.Percentile(50)),
+			P90:   ms(r.Latency.Percentile(90)),
+			P99:   ms(r.Latency.Percentile(99)),
+			P999:  ms(r.Latency.Percentile(99.9)),
+			Max:   ms(r.Latency.Max()),
+		},
+		Packetd: PacketdRecord{
+			ProtoRequests:  r.ProtoRequests,
+			ProtoResets:    r.ProtoResets,
+			CapturePercent: r.ProtoPercent(),
+			CaptureLagMS:   ms(r.CaptureLag),
+			Converged:      r.Converged,
+		},
+		Resource: ResourceRecord{
+			CPUCores:       r.Resource.CPU,
+			MemoryBytes:    r.Resource.Mem,
+			PeakCPUCores:   r.Resource.PeakCPU,
+			PeakRSSBytes:   r.Resource.PeakMem,
+			PeakHeapBytes:  r.Resource.PeakHeap,
+			PeakGoroutines: r.Resource.PeakGoroutines,
+			CtxSwitches:    r.Resource.CtxSwitches,
+			ReadBytes:      r.Resource.ReadBytes,
+			WriteBytes:     r.Resource.WriteBytes,
+		},
+	}
+}
+
+// WriteRecord 按照 format 将结果写入 w
+func WriteRecord(w io.Writer, format string, rec *Record) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rec)
+	case OutputJSONL:
+		return json.NewEncoder(w).Encode(rec)
+	case OutputCSV:
+		return writeCSV(w, []*Record{rec})
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+var csvHeader = []string{
+	"schema_version",
+	"timestamp",
+	"name",
+	"proto",
+	"stage",
+	"workers",
+	"total",
+	"interval",
+	"rate",
+	"duration",
+	"warmup",
+	"cooldown",
+	"profile",
+	"body_size",
+	"params",
+	"requests",
+	"elapsed_seconds",
+	"qps",
+	"bps",
+	"request_bps",
+	"response_bps",
+	"late",
+	"dropped",
+	"aborted",
+	"errors",
+	"error_percent",
+	"error_classes",
+	"latency_mean_ms",
+	"latency_p50_ms",
+	"latency_p90_ms",
+	"latency_p99_ms",
+	"latency_p999_ms",
+	"latency_max_ms",
+	"proto_requests",
+	"proto_resets",
+	"capture_percent",
+	"capture_lag_ms",
+	"converged",
+	"cpu_cores",
+	"memory_bytes",
+	"peak_cpu_cores",
+	"peak_rss_bytes",
+	"peak_heap_bytes",
+	"peak_goroutines",
+	"ctx_switches",
+	"read_bytes",
+	"write_bytes",
+}
+
+// writeCSV 输出表头以及所有结果 各阶段的结果作为独立的行输出
+func writeCSV(w io.Writer, recs []*Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err := writeCSVRows(cw, rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeCSVRows(cw *csv.Writer, rec *Record) error {
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	params, err := json.Marshal(rec.Config.Params)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range append([]*Record{rec}, rec.Stages...) {
+		row := []string{
+			strconv.Itoa(r.SchemaVersion),
+			r.Timestamp.Format(time.RFC3339),
+			rec.Name,
+			r.Proto,
+			r.Stage,
+			strconv.Itoa(rec.Config.Workers),
+			strconv.Itoa(rec.Config.Total),
+			rec.Config.Interval,
+			f(rec.Config.Rate),
+			rec.Config.Duration,
+			rec.Config.Warmup,
+			rec.Config.Cooldown,
+			rec.Config.Profile,
+			strconv.Itoa(rec.Config.BodySize),
+			string(params),
+			strconv.Itoa(r.Requests),
+			f(r.ElapsedSeconds),
+			f(r.QPS),
+			f(r.BPS),
+			f(r.RequestBPS),
+			f(r.ResponseBPS),
+			strconv.FormatInt(r.Late, 10),
+			strconv.FormatInt(r.Dropped, 10),
+			strconv.FormatBool(r.Aborted),
+			strconv.FormatInt(r.Errors.Count, 10),
+			f(r.Errors.Percent),
+			r.Errors.Classes.String(),
+			f(r.Latency.Mean),
+			f(r.Latency.P50),
+			f(r.Latency.P90),
+			f(r.Latency.P99),
+			f(r.Latency.P999),
+			f(r.Latency.Max),
+			f(r.Packetd.ProtoRequests),
+			strconv.Itoa(r.Packetd.ProtoResets),
+			f(r.Packetd.CapturePercent),
+			f(r.Packetd.CaptureLagMS),
+			strconv.FormatBool(r.Packetd.Converged),
+			f(r.Resource.CPUCores),
+			f(r.Resource.MemoryBytes),
+			f(r.Resource.PeakCPUCores),
+			f(r.Resource.PeakRSSBytes),
+			f(r.Resource.PeakHeapBytes),
+			f(r.Resource.PeakGoroutines),
+			f(r.Resource.CtxSwitches),
+			f(r.Resource.ReadBytes),
+			f(r.Resource.WriteBytes),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openOutput 打开结果输出文件 jsonl 格式以追加方式写入 便于多次压测的结果汇总到同一个文件
+func openOutput(path, format string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if format == OutputJSONL {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	return os.OpenFile(path, flags, 0o644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,83 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteRecordZeroRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *Result
+	}{
+		{
+			name: "no window opened",
+			result: &Result{
+				Proto:   "http",
+				Errors:  make(ErrorCounts),
+				Latency: NewHistogram(),
+			},
+		},
+		{
+			name: "zero rate stage",
+			result: &Result{
+				Proto:         "http",
+				BodySize:      1024,
+				Elapsed:       time.Second,
+				ProtoRequests: 3,
+				Errors:        make(ErrorCounts),
+				Latency:       NewHistogram(),
+				Stages: []*Result{
+					{Stage: "hold:0qps/1s", Elapsed: time.Second, Errors: make(ErrorCounts), Latency: NewHistogram()},
+				},
+			},
+		},
+		{
+			name: "transfer",
+			result: &Result{
+				Proto:    "http",
+				Transfer: true,
+				Errors:   make(ErrorCounts),
+				Latency:  NewHistogram(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []string{"json", "jsonl", "csv"} {
+				var buf bytes.Buffer
+				if err := WriteRecord(&buf, format, NewRecord(RunConfig{}, tt.result)); err != nil {
+					t.Fatalf("WriteRecord(%s): %v", format, err)
+				}
+				if format != "json" {
+					continue
+				}
+
+				var rec Record
+				if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if rec.QPS != 0 || rec.BPS != 0 || rec.Packetd.CapturePercent != 0 || rec.Resource.CPUCores != 0 {
+					t.Errorf("want zero rates, got qps=%v bps=%v percent=%v cpu=%v",
+						rec.QPS, rec.BPS, rec.Packetd.CapturePercent, rec.Resource.CPUCores)
+				}
+			}
+		})
+	}
+}
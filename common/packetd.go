@@ -0,0 +1,184 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PacketdConfig 描述了 packetd 指标接口的访问方式
+//
+// 所有参数均可通过同名的大写环境变量设置默认值 如 `-packetd_addr` 对应 `PACKETD_ADDR`
+type PacketdConfig struct {
+	// Addr 为 packetd 的监听地址 可携带 http:// 或 https:// 前缀
+	Addr         string
+	MetricsPath  string
+	ProtocolPath string
+	Timeout      time.Duration
+
+	Username string
+	Password string
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+}
+
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func newPacketdConfigFromEnv() PacketdConfig {
+	return PacketdConfig{
+		Addr:                  envString("PACKETD_ADDR", "localhost:9091"),
+		MetricsPath:           envString("PACKETD_METRICS_PATH", "/metrics"),
+		ProtocolPath:          envString("PACKETD_PROTOCOL_PATH", "/protocol/metrics"),
+		Timeout:               envDuration("PACKETD_TIMEOUT", 5*time.Second),
+		Username:              envString("PACKETD_USERNAME", ""),
+		Password:              envString("PACKETD_PASSWORD", ""),
+		TLS:                   envBool("PACKETD_TLS", false),
+		TLSCAFile:             envString("PACKETD_TLS_CA_FILE", ""),
+		TLSCertFile:           envString("PACKETD_TLS_CERT_FILE", ""),
+		TLSKeyFile:            envString("PACKETD_TLS_KEY_FILE", ""),
+		TLSInsecureSkipVerify: envBool("PACKETD_TLS_INSECURE_SKIP_VERIFY", false),
+	}
+}
+
+// RegisterFlags 注册 packetd 相关的命令行参数
+func (c *PacketdConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "packetd_addr", c.Addr, "packetd address, may be prefixed with http:// or https://")
+	fs.StringVar(&c.MetricsPath, "packetd_metrics_path", c.MetricsPath, "packetd process metrics path")
+	fs.StringVar(&c.ProtocolPath, "packetd_protocol_path", c.ProtocolPath, "packetd protocol metrics path")
+	fs.DurationVar(&c.Timeout, "packetd_timeout", c.Timeout, "packetd metrics request timeout")
+	fs.StringVar(&c.Username, "packetd_username", c.Username, "packetd basic auth username")
+	fs.StringVar(&c.Password, "packetd_password", c.Password, "packetd basic auth password")
+	fs.BoolVar(&c.TLS, "packetd_tls", c.TLS, "request packetd metrics over https")
+	fs.StringVar(&c.TLSCAFile, "packetd_tls_ca_file", c.TLSCAFile, "packetd tls ca file")
+	fs.StringVar(&c.TLSCertFile, "packetd_tls_cert_file", c.TLSCertFile, "packetd tls client certificate file")
+	fs.StringVar(&c.TLSKeyFile, "packetd_tls_key_file", c.TLSKeyFile, "packetd tls client key file")
+	fs.BoolVar(&c.TLSInsecureSkipVerify, "packetd_tls_insecure_skip_verify", c.TLSInsecureSkipVerify, "skip packetd tls certificate verification")
+}
+
+func (c *PacketdConfig) baseURL() string {
+	addr := strings.TrimSuffix(c.Addr, "/")
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr
+	}
+	if c.TLS || c.TLSCAFile != "" || c.TLSCertFile != "" {
+		return "https://" + addr
+	}
+	return "http://" + addr
+}
+
+func (c *PacketdConfig) MetricsURL() string {
+	return c.baseURL() + c.MetricsPath
+}
+
+func (c *PacketdConfig) ProtocolURL() string {
+	return c.baseURL() + c.ProtocolPath
+}
+
+func (c *PacketdConfig) newHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+	if c.TLSCAFile != "" {
+		b, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("no certificates found in " + c.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// packetd 为全局共享的 packetd 访问配置 http.Client 在请求时根据配置创建
+//
+// 配置在两次请求之间可能被修改 如依次执行的多个压测使用不同的 TLS 以及超时配置 配置变化后重新创建 http.Client
+var packetd = struct {
+	conf PacketdConfig
+
+	mut   sync.Mutex
+	built PacketdConfig
+	cli   *http.Client
+	err   error
+}{
+	conf: newPacketdConfigFromEnv(),
+}
+
+// Packetd 返回全局共享的 packetd 访问配置
+func Packetd() *PacketdConfig {
+	return &packetd.conf
+}
+
+// RegisterPacketdFlags 将全局 packetd 访问配置注册至 fs
+func RegisterPacketdFlags(fs *flag.FlagSet) {
+	packetd.conf.RegisterFlags(fs)
+}
+
+func packetdClient() (*http.Client, error) {
+	packetd.mut.Lock()
+	defer packetd.mut.Unlock()
+
+	if packetd.cli == nil && packetd.err == nil || packetd.built != packetd.conf {
+		packetd.built = packetd.conf
+		packetd.cli, packetd.err = packetd.conf.newHTTPClient()
+	}
+	return packetd.cli, packetd.err
+}
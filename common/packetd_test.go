@@ -0,0 +1,42 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPacketdClientRebuild(t *testing.T) {
+	fakePacketd(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintln(w, "http_requests_total 1")
+	})
+	conf := Packetd()
+	timeout := conf.Timeout
+	t.Cleanup(func() { conf.Timeout = timeout })
+
+	conf.Timeout = 10 * time.Millisecond
+	if _, err := RequestProtocolMetrics(); err == nil {
+		t.Fatal("want timeout error")
+	}
+
+	// 修改后的超时需要在之后的请求中生效
+	conf.Timeout = 5 * time.Second
+	if _, err := RequestProtocolMetrics(); err != nil {
+		t.Fatalf("want the new timeout applied, got %v", err)
+	}
+}
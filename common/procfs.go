@@ -0,0 +1,180 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	procRoot = "/proc"
+
+	// clockTicks 为 /proc/<pid>/stat 中 CPU 时间的单位 即 sysconf(_SC_CLK_TCK)
+	// 主流 Linux 发行版均为 100
+	clockTicks = 100
+
+	// commLen 为 /proc/<pid>/comm 中进程名的最大长度
+	commLen = 15
+)
+
+// ProcfsSource 直接读取 /proc/<pid> 下的 stat status 以及 io 文件采集进程资源
+//
+// 读取 io 文件需要与目标进程相同的用户或者 root 权限 无权限时读写字节数为 0
+type ProcfsSource struct {
+	pid    int
+	ioOnce sync.Once
+}
+
+func NewProcfsSource(pid int) (*ProcfsSource, error) {
+	if _, err := os.Stat(filepath.Join(procRoot, strconv.Itoa(pid))); err != nil {
+		return nil, fmt.Errorf("process %d not found: %w", pid, err)
+	}
+	return &ProcfsSource{pid: pid}, nil
+}
+
+func (s *ProcfsSource) path(name string) string {
+	return filepath.Join(procRoot, strconv.Itoa(s.pid), name)
+}
+
+func (s *ProcfsSource) Snapshot() (ResourcePoint, error) {
+	p := ResourcePoint{Time: time.Now()}
+
+	cpu, err := s.readStat()
+	if err != nil {
+		return p, err
+	}
+	p.CPUSeconds = cpu
+
+	status, err := readKeyValues(s.path("status"))
+	if err != nil {
+		return p, err
+	}
+	// VmRSS 的单位为 kB
+	p.RSS = status["VmRSS"] * 1024
+	p.CtxSwitches = status["voluntary_ctxt_switches"] + status["nonvoluntary_ctxt_switches"]
+
+	ioStats, err := readKeyValues(s.path("io"))
+	if err != nil {
+		if !errors.Is(err, os.ErrPermission) {
+			return p, err
+		}
+		s.ioOnce.Do(func() {
+			log.Printf("no permission to read %s, read/write bytes are not available\n", s.path("io"))
+		})
+		return p, nil
+	}
+	p.ReadBytes = ioStats["read_bytes"]
+	p.WriteBytes = ioStats["write_bytes"]
+	return p, nil
+}
+
+// readStat 返回进程累计的用户态以及内核态 CPU 时间 单位为秒
+func (s *ProcfsSource) readStat() (float64, error) {
+	b, err := os.ReadFile(s.path("stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// 进程名可能包含空格以及括号 从最后一个 `)` 之后开始解析
+	// 之后的字段从 state 开始 utime 以及 stime 分别为 stat 中的第 14 以及第 15 个字段
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid %s", s.path("stat"))
+	}
+	fields := strings.Fields(string(b[i+1:]))
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("invalid %s", s.path("stat"))
+	}
+
+	utime, err := strconv.ParseFloat(fields[11], 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseFloat(fields[12], 64)
+	if err != nil {
+		return 0, err
+	}
+	return (utime + stime) / clockTicks, nil
+}
+
+// readKeyValues 解析 `key: value [unit]` 格式的文件 忽略无法解析为数值的行
+func readKeyValues(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	kv := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			continue
+		}
+		if n, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			kv[strings.TrimSpace(k)] = n
+		}
+	}
+	return kv, scanner.Err()
+}
+
+// FindProcess 根据进程名查找进程号 匹配到多个进程时返回错误
+func FindProcess(name string) (int, error) {
+	if len(name) > commLen {
+		name = name[:commLen]
+	}
+
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return 0, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(b)) == name {
+			pids = append(pids, pid)
+		}
+	}
+
+	switch len(pids) {
+	case 0:
+		return 0, fmt.Errorf("process %q not found", name)
+	case 1:
+		return pids[0], nil
+	}
+	return 0, fmt.Errorf("multiple processes named %q found: %v, use -resource_pid instead", name, pids)
+}
@@ -0,0 +1,217 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StageRamp  = "ramp"
+	StageHold  = "hold"
+	StageStep  = "step"
+	StageSpike = "spike"
+	StageSine  = "sine"
+)
+
+// Stage 为负载曲线中的一个阶段
+type Stage struct {
+	Kind     string
+	From     float64
+	To       float64
+	Duration time.Duration
+
+	// Period 仅用于 sine 阶段 表示速率在 From 与 To 之间往返一次的周期
+	Period time.Duration
+
+	spec string
+}
+
+func (s Stage) String() string {
+	return s.spec
+}
+
+// RateAt 返回阶段开始 elapsed 之后的目标速率
+func (s Stage) RateAt(elapsed time.Duration) float64 {
+	switch s.Kind {
+	case StageRamp:
+		ratio := float64(elapsed) / float64(s.Duration)
+		return s.From + (s.To-s.From)*math.Min(ratio, 1)
+	case StageSine:
+		phase := 2 * math.Pi * float64(elapsed) / float64(s.Period)
+		return s.From + (s.To-s.From)*(1-math.Cos(phase))/2
+	}
+	return s.To
+}
+
+// Profile 描述了按阶段变化的负载曲线
+//
+// 格式为逗号分隔的阶段列表 速率单位为 qps
+//
+//	ramp:0->5000qps/60s       在 60s 内从 0 线性增加至 5000qps 省略起始速率时从上一阶段的速率开始
+//	hold:120s                 保持上一阶段的速率 120s 也可以写作 hold:5000qps/120s
+//	step:3000qps/30s          以 3000qps 持续 30s
+//	spike:20000qps/5s         以 20000qps 持续 5s 语义同 step 便于在报告中区分
+//	sine:1000->5000qps/60s/10s 在 60s 内按 10s 的周期在 1000 与 5000qps 之间往返 周期默认为阶段时长
+type Profile []Stage
+
+func ParseProfile(s string) (Profile, error) {
+	var p Profile
+	var last float64
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		stage, err := parseStage(spec, last)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage %q: %w", spec, err)
+		}
+		p = append(p, stage)
+		last = stage.RateAt(stage.Duration)
+	}
+
+	if len(p) == 0 {
+		return nil, fmt.Errorf("empty profile")
+	}
+	return p, nil
+}
+
+func parseStage(spec string, last float64) (Stage, error) {
+	kind, args, ok := strings.Cut(spec, ":")
+	if !ok {
+		return Stage{}, fmt.Errorf("missing ':'")
+	}
+
+	stage := Stage{Kind: kind, From: last, To: last, spec: spec}
+	parts := strings.Split(args, "/")
+
+	var rate string
+	switch kind {
+	case StageHold:
+		if len(parts) == 2 {
+			rate, parts = parts[0], parts[1:]
+		}
+	case StageRamp, StageStep, StageSpike:
+		if len(parts) != 2 {
+			return Stage{}, fmt.Errorf("want <rate>/<duration>")
+		}
+		rate, parts = parts[0], parts[1:]
+	case StageSine:
+		if len(parts) != 2 && len(parts) != 3 {
+			return Stage{}, fmt.Errorf("want <low>-><high>/<duration>[/<period>]")
+		}
+		rate, parts = parts[0], parts[1:]
+	default:
+		return Stage{}, fmt.Errorf("unknown stage kind %q", kind)
+	}
+
+	if rate != "" {
+		from, to, err := parseRateRange(rate)
+		if err != nil {
+			return Stage{}, err
+		}
+		if from >= 0 {
+			stage.From = from
+		} else if kind != StageRamp {
+			stage.From = to
+		}
+		stage.To = to
+		if kind == StageSine && from < 0 {
+			return Stage{}, fmt.Errorf("sine stage requires <low>-><high>")
+		}
+	}
+
+	d, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return Stage{}, err
+	}
+	if d <= 0 {
+		return Stage{}, fmt.Errorf("duration must be positive")
+	}
+	stage.Duration = d
+
+	if kind == StageSine {
+		stage.Period = d
+		if len(parts) == 2 {
+			if stage.Period, err = time.ParseDuration(parts[1]); err != nil {
+				return Stage{}, err
+			}
+			if stage.Period <= 0 {
+				return Stage{}, fmt.Errorf("period must be positive")
+			}
+		}
+	}
+	return stage, nil
+}
+
+// parseRateRange 解析 `5000qps` 或 `0->5000qps` 格式的速率 省略起始速率时 from 返回 -1
+func parseRateRange(s string) (float64, float64, error) {
+	parse := func(v string) (float64, error) {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "qps"), 64)
+		if err != nil {
+			return 0, err
+		}
+		if f < 0 {
+			return 0, fmt.Errorf("rate must not be negative")
+		}
+		return f, nil
+	}
+
+	from := -1.0
+	lhs, rhs, ok := strings.Cut(s, "->")
+	if ok {
+		f, err := parse(lhs)
+		if err != nil {
+			return 0, 0, err
+		}
+		from = f
+	} else {
+		rhs = lhs
+	}
+
+	to, err := parse(rhs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func (p Profile) Duration() time.Duration {
+	var total time.Duration
+	for _, s := range p {
+		total += s.Duration
+	}
+	return total
+}
+
+// RateAt 返回负载曲线开始 elapsed 之后的目标速率 超出曲线范围时使用边界速率
+func (p Profile) RateAt(elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		return p[0].RateAt(0)
+	}
+	for _, s := range p {
+		if elapsed < s.Duration {
+			return s.RateAt(elapsed)
+		}
+		elapsed -= s.Duration
+	}
+	last := p[len(p)-1]
+	return last.RateAt(last.Duration)
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProfileRateAt(t *testing.T) {
+	p, err := ParseProfile("ramp:0->1000qps/10s, hold:5s, step:0qps/2s, spike:5000qps/1s, sine:1000->3000qps/4s/2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Duration() != 22*time.Second {
+		t.Errorf("Duration: want 22s, got %v", p.Duration())
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{elapsed: -time.Second, want: 0},
+		{elapsed: 0, want: 0},
+		{elapsed: 5 * time.Second, want: 500},
+		{elapsed: 10*time.Second - time.Millisecond, want: 999.9},
+		{elapsed: 10 * time.Second, want: 1000},
+		{elapsed: 15*time.Second - time.Nanosecond, want: 1000},
+		{elapsed: 15 * time.Second, want: 0},
+		{elapsed: 17*time.Second - time.Nanosecond, want: 0},
+		{elapsed: 17 * time.Second, want: 5000},
+		{elapsed: 18 * time.Second, want: 1000},
+		{elapsed: 18*time.Second + 500*time.Millisecond, want: 2000},
+		{elapsed: 19 * time.Second, want: 3000},
+		{elapsed: 20 * time.Second, want: 1000},
+		{elapsed: time.Hour, want: 1000},
+	}
+	for _, tt := range tests {
+		if got := p.RateAt(tt.elapsed); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("RateAt(%v): want %v, got %v", tt.elapsed, tt.want, got)
+		}
+	}
+}
+
+func TestParseProfileInherit(t *testing.T) {
+	p, err := ParseProfile("step:3000qps/30s,ramp:6000qps/10s,hold:1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ from, to float64 }{{3000, 3000}, {3000, 6000}, {6000, 6000}}
+	for i, s := range p {
+		if s.From != want[i].from || s.To != want[i].to {
+			t.Errorf("stage %d: want %v->%v, got %v->%v", i, want[i].from, want[i].to, s.From, s.To)
+		}
+	}
+}
+
+func TestParseProfileInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"hold",
+		"ramp:1000qps",
+		"step:-1qps/10s",
+		"step:1000qps/0s",
+		"sine:5000qps/60s",
+		"sine:1000->5000qps/60s/0s",
+		"burst:1000qps/10s",
+	} {
+		if _, err := ParseProfile(s); err == nil {
+			t.Errorf("ParseProfile(%q): want error", s)
+		}
+	}
+}
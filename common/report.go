@@ -0,0 +1,259 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Result 为单次压测的结果
+type Result struct {
+	Proto    string
+	Stage    string
+	Total    int
+	Workers  int
+	BodySize int
+	Columns  []Column
+
+	// Transfer 表示 Workload 实现了 Transfer RequestBytes 以及 ResponseBytes 为成功的操作传输的 body 字节数
+	Transfer      bool
+	RequestBytes  int64
+	ResponseBytes int64
+
+	// Rate 开环模式下的目标速率 Late/Dropped 分别为延迟执行以及被丢弃的操作数 不包含 warmup 以及 cooldown 期间的操作
+	Rate    float64
+	Late    int64
+	Dropped int64
+
+	Elapsed  time.Duration
+	Latency  *Histogram
+	Resource Resource
+
+	// Errors 为失败的操作数 失败的操作计入 Total 但不计入延迟统计
+	// Aborted 表示失败的操作数达到了 MaxErrors 压测被提前中止
+	Errors  ErrorCounts
+	Aborted bool
+
+	// ProtoRequests 为压测期间 packetd 协议计数器的增量 ProtoResets 为期间检测到的计数器重置次数
+	ProtoRequests float64
+	ProtoResets   int
+
+	// CaptureLag 为压测结束后 packetd 协议计数器达到稳定的耗时 Converged 为 false 时表示等待超时
+	CaptureLag time.Duration
+	Converged  bool
+
+	// Timeline 为压测期间 packetd 进程指标的采样序列
+	Timeline []ResourcePoint
+
+	// Stages 为按负载曲线划分的各阶段结果
+	Stages []*Result
+}
+
+func (r *Result) setConvergence(c *Convergence) {
+	r.CaptureLag = c.Lag
+	r.Converged = !c.TimedOut
+}
+
+func (r *Result) captureLag() string {
+	if !r.Converged {
+		return ">" + FormatLatency(r.CaptureLag)
+	}
+	return FormatLatency(r.CaptureLag)
+}
+
+// perSecond 返回 v 在压测期间的每秒均值 没有任何窗口开启时 Elapsed 为 0 此时返回 0
+func (r *Result) perSecond(v float64) float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return v / r.Elapsed.Seconds()
+}
+
+func (r *Result) QPS() float64 {
+	return r.perSecond(float64(r.Total))
+}
+
+func (r *Result) BPS() float64 {
+	if r.Transfer {
+		return r.perSecond(float64(r.RequestBytes + r.ResponseBytes))
+	}
+	return r.perSecond(float64(r.Total * r.BodySize))
+}
+
+func (r *Result) RequestBPS() float64 {
+	return r.perSecond(float64(r.RequestBytes))
+}
+
+func (r *Result) ResponseBPS() float64 {
+	return r.perSecond(float64(r.ResponseBytes))
+}
+
+func (r *Result) ErrorPercent() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Errors.Total()) / float64(r.Total) * 100
+}
+
+func (r *Result) ProtoPercent() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return r.ProtoRequests / float64(r.Total) * 100
+}
+
+func PrintTable(w io.Writer, r *Result) {
+	header := []interface{}{
+		"request",
+		"workers",
+		"elapsed",
+		"qps",
+	}
+	row := []interface{}{
+		r.Total,
+		r.Workers,
+		fmt.Sprintf("%.3fs", r.Elapsed.Seconds()),
+		fmt.Sprintf("%.3f", r.QPS()),
+	}
+	if r.Rate > 0 {
+		header = append(header, "rate")
+		row = append(row, fmt.Sprintf("%.3f", r.Rate))
+	}
+	if r.Rate > 0 || len(r.Stages) > 0 {
+		header = append(header, "late", "dropped")
+		row = append(row, r.Late, r.Dropped)
+	}
+	if r.Transfer {
+		header = append(header, "bps (request)", "bps (response)")
+		row = append(row, HumanizeBit(r.RequestBPS()), HumanizeBit(r.ResponseBPS()))
+	} else if r.BodySize > 0 {
+		header = append(header, "bps")
+		row = append(row, HumanizeBit(r.BPS()))
+	}
+	header = append(header, "errors", "error (percent)")
+	row = append(row, r.Errors.Total(), fmt.Sprintf("%.3f%%", r.ErrorPercent()))
+	if len(r.Errors) > 0 {
+		header = append(header, "error (class)")
+		row = append(row, r.Errors.String())
+	}
+	header = append(header, "p50", "p90", "p99", "p99.9", "max")
+	row = append(row,
+		FormatLatency(r.Latency.Percentile(50)),
+		FormatLatency(r.Latency.Percentile(90)),
+		FormatLatency(r.Latency.Percentile(99)),
+		FormatLatency(r.Latency.Percentile(99.9)),
+		FormatLatency(r.Latency.Max()),
+	)
+	for _, c := range r.Columns {
+		header = append(header, c.Name)
+		row = append(row, c.Value)
+	}
+	header = append(header,
+		"proto (request)",
+		"proto (percent)",
+	)
+	row = append(row,
+		int(r.ProtoRequests),
+		fmt.Sprintf("%.3f%%", r.ProtoPercent()),
+	)
+	if r.ProtoResets > 0 {
+		header = append(header, "proto (resets)")
+		row = append(row, r.ProtoResets)
+	}
+	if r.CaptureLag > 0 || r.Converged {
+		header = append(header, "capture lag")
+		row = append(row, r.captureLag())
+	}
+	header = append(header,
+		"cpu (core)",
+		"memory (MB)",
+	)
+	row = append(row,
+		fmt.Sprintf("%.3f", r.Resource.CPU),
+		fmt.Sprintf("%.3f", r.Resource.Mem/1024/1024),
+	)
+	if r.Resource.Sampled {
+		header = append(header, "cpu peak (core)", "memory peak (MB)")
+		row = append(row,
+			fmt.Sprintf("%.3f", r.Resource.PeakCPU),
+			fmt.Sprintf("%.3f", r.Resource.PeakMem/1024/1024),
+		)
+	}
+	if r.Resource.PeakGoroutines > 0 {
+		header = append(header, "heap peak (MB)", "goroutines peak")
+		row = append(row,
+			fmt.Sprintf("%.3f", r.Resource.PeakHeap/1024/1024),
+			int(r.Resource.PeakGoroutines),
+		)
+	}
+	if r.Resource.CtxSwitches > 0 {
+		header = append(header, "ctx switches", "read (MB)", "write (MB)")
+		row = append(row,
+			int(r.Resource.CtxSwitches),
+			fmt.Sprintf("%.3f", r.Resource.ReadBytes/1024/1024),
+			fmt.Sprintf("%.3f", r.Resource.WriteBytes/1024/1024),
+		)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(header)
+	t.AppendRow(row)
+	t.AppendSeparator()
+	t.Render()
+}
+
+// PrintStageTable 输出负载曲线各阶段的结果
+//
+// 阶段边界处的协议指标为单次采集 packetd 尚未处理完的请求会计入下一个阶段 各阶段的 proto (percent) 仅供参考
+func PrintStageTable(w io.Writer, r *Result) {
+	header := []interface{}{
+		"stage",
+		"request",
+		"elapsed",
+		"qps",
+		"error (percent)",
+		"p99",
+		"proto (request)",
+		"proto (percent)",
+		"cpu (core)",
+		"cpu peak (core)",
+		"memory (MB)",
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(header)
+	for _, stage := range r.Stages {
+		t.AppendRow([]interface{}{
+			stage.Stage,
+			stage.Total,
+			fmt.Sprintf("%.3fs", stage.Elapsed.Seconds()),
+			fmt.Sprintf("%.3f", stage.QPS()),
+			fmt.Sprintf("%.3f%%", stage.ErrorPercent()),
+			FormatLatency(stage.Latency.Percentile(99)),
+			int(stage.ProtoRequests),
+			fmt.Sprintf("%.3f%%", stage.ProtoPercent()),
+			fmt.Sprintf("%.3f", stage.Resource.CPU),
+			fmt.Sprintf("%.3f", stage.Resource.PeakCPU),
+			fmt.Sprintf("%.3f", stage.Resource.Mem/1024/1024),
+		})
+	}
+	t.AppendSeparator()
+	t.Render()
+}
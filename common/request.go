@@ -14,88 +14,40 @@
 package common
 
 import (
-	"io"
+	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
-	"time"
 )
 
-func RequestProtocolMetrics() (map[string]float64, error) {
-	return doRequest("http://localhost:9091/protocol/metrics")
+func RequestProtocolMetrics() (Samples, error) {
+	return doRequest(Packetd().ProtocolURL())
 }
 
-func RequestMetrics() (map[string]float64, error) {
-	return doRequest("http://localhost:9091/metrics")
+func RequestMetrics() (Samples, error) {
+	return doRequest(Packetd().MetricsURL())
 }
 
-func doRequest(url string) (map[string]float64, error) {
-	rsp, err := http.Get(url)
+func doRequest(url string) (Samples, error) {
+	cli, err := packetdClient()
 	if err != nil {
 		return nil, err
 	}
-	defer rsp.Body.Close()
 
-	b, err := io.ReadAll(rsp.Body)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	metrics := make(map[string]float64)
-	lines := strings.Split(string(b), "\n")
-	for _, line := range lines {
-		if len(line) == 0 || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.Split(line, " ")
-		f, err := strconv.ParseFloat(parts[1], 64)
-		if err != nil {
-			continue
-		}
-
-		name := strings.Split(parts[0], "{")[0]
-		metrics[name] = f
+	if conf := Packetd(); conf.Username != "" || conf.Password != "" {
+		req.SetBasicAuth(conf.Username, conf.Password)
 	}
-	return metrics, nil
-}
 
-type Resource struct {
-	CPU float64
-	Mem float64
-}
-
-type ResourceRecorder struct {
-	t     time.Time
-	start Resource
-}
-
-func NewResourceRecorder() *ResourceRecorder {
-	return &ResourceRecorder{}
-}
-
-func (r *ResourceRecorder) Start() {
-	r.t = time.Now()
-	metrics, err := RequestMetrics()
+	rsp, err := cli.Do(req)
 	if err != nil {
-		return
-	}
-
-	r.start = Resource{
-		CPU: metrics["process_cpu_seconds_total"],
-	}
-}
-
-func (r *ResourceRecorder) End() Resource {
-	var resource Resource
-	metrics, err := RequestMetrics()
-	if err != nil {
-		return resource
+		return nil, err
 	}
+	defer rsp.Body.Close()
 
-	cpu := (metrics["process_cpu_seconds_total"] - r.start.CPU) / (time.Now().Sub(r.t).Seconds())
-	return Resource{
-		CPU: cpu,
-		Mem: metrics["process_resident_memory_bytes"],
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request %s: unexpected status %s", url, rsp.Status)
 	}
+	return ParseText(rsp.Body)
 }
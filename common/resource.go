@@ -0,0 +1,307 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ResourceSourcePacketd = "packetd"
+	ResourceSourceProcfs  = "procfs"
+)
+
+// ResourceConfig 控制 packetd 进程资源的采集方式
+type ResourceConfig struct {
+	// Source 为资源的采集来源 packetd 表示请求 packetd 的 /metrics 接口
+	// procfs 表示直接读取 /proc/<pid> 下的文件 不依赖 packetd 的指标接口 仅支持 Linux
+	Source string
+
+	// PID 为 procfs 模式下的进程号 为 0 时按照 Process 查找进程
+	PID     int
+	Process string
+
+	// Interval 为周期采样的间隔 为 0 时仅在压测开始和结束时采集
+	// File 为完整采样时间序列的输出文件
+	Interval time.Duration
+	File     string
+}
+
+func (c *ResourceConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Source, "resource_source", ResourceSourcePacketd, "packetd process resource source, options: packetd/procfs")
+	fs.IntVar(&c.PID, "resource_pid", 0, "packetd process id, used with -resource_source=procfs")
+	fs.StringVar(&c.Process, "resource_process", "packetd", "packetd process name, used with -resource_source=procfs when -resource_pid is not set")
+	fs.DurationVar(&c.Interval, "resource_interval", time.Second, "packetd process metrics sampling interval, 0 disables sampling")
+	fs.StringVar(&c.File, "resource_file", "", "dump sampled packetd process metrics timeline to file as csv")
+}
+
+// NewSource 根据配置创建资源采集来源
+func (c *ResourceConfig) NewSource() (ResourceSource, error) {
+	switch c.Source {
+	case "", ResourceSourcePacketd:
+		return packetdSource{}, nil
+	case ResourceSourceProcfs:
+		pid := c.PID
+		if pid <= 0 {
+			var err error
+			if pid, err = FindProcess(c.Process); err != nil {
+				return nil, err
+			}
+		}
+		return NewProcfsSource(pid)
+	}
+	return nil, fmt.Errorf("unknown resource source %q", c.Source)
+}
+
+// ResourceSource 提供进程资源的瞬时快照 返回的 ResourcePoint 中 CPU 字段无需填充
+type ResourceSource interface {
+	Snapshot() (ResourcePoint, error)
+}
+
+// packetdSource 通过 packetd 的 /metrics 接口采集进程资源
+type packetdSource struct{}
+
+func (packetdSource) Snapshot() (ResourcePoint, error) {
+	metrics, err := RequestMetrics()
+	if err != nil {
+		return ResourcePoint{}, err
+	}
+	return ResourcePoint{
+		Time:       time.Now(),
+		CPUSeconds: metrics.Get("process_cpu_seconds_total"),
+		RSS:        metrics.Get("process_resident_memory_bytes"),
+		Heap:       metrics.Get("go_memstats_heap_inuse_bytes"),
+		Goroutines: metrics.Get("go_goroutines"),
+	}, nil
+}
+
+type Resource struct {
+	CPU float64
+	Mem float64
+
+	// 以下字段为区间内的增量 仅 procfs 来源支持
+	CtxSwitches float64
+	ReadBytes   float64
+	WriteBytes  float64
+
+	// 以下字段来自于 ResourceSampler 的周期采样 Sampled 表示是否开启了采样
+	Sampled        bool
+	PeakCPU        float64
+	PeakMem        float64
+	PeakHeap       float64
+	PeakGoroutines float64
+}
+
+// ResourceRecorder 采集区间 [Start, End] 内的平均 CPU 使用以及结束时的内存
+type ResourceRecorder struct {
+	src   ResourceSource
+	start ResourcePoint
+}
+
+func NewResourceRecorder(src ResourceSource) *ResourceRecorder {
+	return &ResourceRecorder{src: src}
+}
+
+// Start 采集区间开始时的资源 失败时调用方记录日志后继续压测 End 仅返回结束时的内存
+func (r *ResourceRecorder) Start() error {
+	p, err := r.src.Snapshot()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot resource: %w", err)
+	}
+	r.start = p
+	return nil
+}
+
+// End 返回区间内的资源使用 Start 失败时无法计算区间内的增量 仅返回结束时的内存
+func (r *ResourceRecorder) End() (Resource, error) {
+	p, err := r.src.Snapshot()
+	if err != nil {
+		return Resource{}, fmt.Errorf("failed to snapshot resource: %w", err)
+	}
+	if r.start.Time.IsZero() {
+		return Resource{Mem: p.RSS}, nil
+	}
+
+	return Resource{
+		CPU:         (p.CPUSeconds - r.start.CPUSeconds) / p.Time.Sub(r.start.Time).Seconds(),
+		Mem:         p.RSS,
+		CtxSwitches: p.CtxSwitches - r.start.CtxSwitches,
+		ReadBytes:   p.ReadBytes - r.start.ReadBytes,
+		WriteBytes:  p.WriteBytes - r.start.WriteBytes,
+	}, nil
+}
+
+// ResourcePoint 为一次进程资源采样
+type ResourcePoint struct {
+	Time       time.Time
+	CPUSeconds float64
+
+	// CPU 为距离上一次采样的平均 CPU 核数 第一次采样时为 0
+	CPU float64
+	RSS float64
+
+	// Heap 以及 Goroutines 仅 packetd 来源支持
+	Heap       float64
+	Goroutines float64
+
+	// CtxSwitches ReadBytes 以及 WriteBytes 为累计值 仅 procfs 来源支持
+	CtxSwitches float64
+	ReadBytes   float64
+	WriteBytes  float64
+}
+
+// ResourceSampler 在压测期间周期性采集 packetd 的进程资源
+type ResourceSampler struct {
+	src      ResourceSource
+	interval time.Duration
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	points []ResourcePoint
+}
+
+func NewResourceSampler(src ResourceSource, interval time.Duration) *ResourceSampler {
+	return &ResourceSampler{
+		src:      src,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *ResourceSampler) Start() {
+	s.sample()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+}
+
+func (s *ResourceSampler) Stop() {
+	close(s.done)
+	s.wg.Wait()
+	s.sample()
+}
+
+func (s *ResourceSampler) sample() {
+	p, err := s.src.Snapshot()
+	if err != nil {
+		log.Printf("failed to sample resource: %v\n", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.points); n > 0 {
+		prev := s.points[n-1]
+		if d := p.Time.Sub(prev.Time).Seconds(); d > 0 {
+			p.CPU = (p.CPUSeconds - prev.CPUSeconds) / d
+		}
+	}
+	s.points = append(s.points, p)
+}
+
+func (s *ResourceSampler) Points() []ResourcePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points := make([]ResourcePoint, len(s.points))
+	copy(points, s.points)
+	return points
+}
+
+// Peak 将 [start, end) 之间采样得到的峰值合并至 r end 为零值时表示不限制结束时间
+func (s *ResourceSampler) Peak(r *Resource, start, end time.Time) {
+	r.Sampled = true
+	r.PeakMem = math.Max(r.PeakMem, r.Mem)
+	points := s.Points()
+	for i, p := range points {
+		if p.Time.Before(start) || (!end.IsZero() && !p.Time.Before(end)) {
+			continue
+		}
+
+		// 第一个采样点的 CPU 覆盖的区间在 start 之前
+		if i > 0 && !points[i-1].Time.Before(start) {
+			r.PeakCPU = math.Max(r.PeakCPU, p.CPU)
+		}
+		r.PeakMem = math.Max(r.PeakMem, p.RSS)
+		r.PeakHeap = math.Max(r.PeakHeap, p.Heap)
+		r.PeakGoroutines = math.Max(r.PeakGoroutines, p.Goroutines)
+	}
+	r.PeakCPU = math.Max(r.PeakCPU, r.CPU)
+}
+
+// WriteResourceTimeline 以 CSV 格式输出完整的采样时间序列
+func WriteResourceTimeline(w io.Writer, points []ResourcePoint) error {
+	cw := csv.NewWriter(w)
+	header := []string{
+		"timestamp",
+		"elapsed_seconds",
+		"cpu_seconds_total",
+		"cpu_cores",
+		"rss_bytes",
+		"heap_inuse_bytes",
+		"goroutines",
+		"ctx_switches_total",
+		"read_bytes_total",
+		"write_bytes_total",
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, p := range points {
+		row := []string{
+			p.Time.Format(time.RFC3339Nano),
+			f(p.Time.Sub(points[0].Time).Seconds()),
+			f(p.CPUSeconds),
+			f(p.CPU),
+			f(p.RSS),
+			f(p.Heap),
+			f(p.Goroutines),
+			f(p.CtxSwitches),
+			f(p.ReadBytes),
+			f(p.WriteBytes),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,542 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Column 描述 Workload 需要在报告中额外展示的列
+type Column struct {
+	Name  string
+	Value interface{}
+}
+
+// Workload 定义了单一协议的压测操作
+//
+// Runner 负责并发调度、资源采集以及结果输出 各协议只需要实现具体的操作
+type Workload interface {
+	// Setup 在第一次操作之前调用 用于建立连接等准备工作
+	Setup() error
+
+	// Do 执行第 idx 次操作
+	Do(idx int) error
+
+	// Teardown 在所有操作完成后调用 用于释放资源
+	Teardown() error
+
+	// Columns 返回报告中展示的协议相关列
+	Columns() []Column
+}
+
+// Transfer 由请求以及响应大小随操作变化的 Workload 实现
+//
+// 实现后 bps 按照成功的操作实际传输的 body 字节数计算 并分别展示请求以及响应的 bps
+type Transfer interface {
+	// Transferred 返回第 idx 次操作的请求以及响应 body 的字节数
+	Transferred(idx int) (request, response int)
+}
+
+// RunConfig 为 Runner 的通用配置
+type RunConfig struct {
+	// Proto 协议名称 用于读取 packetd 的 `<proto>_requests_total` 指标
+	Proto string
+
+	Workers int
+	Total   int
+
+	// Interval 为闭环模式下的操作间隔 默认由调度器统一等待 即所有 worker 合计每个 Interval 派发一次操作
+	// PaceWorkers 为 true 时由每个 worker 在每次操作前各自等待 Interval
+	Interval    time.Duration
+	PaceWorkers bool
+
+	// Rate 开环模式下每秒调度的操作数 大于 0 时忽略 Interval
+	Rate float64
+
+	// Duration 大于 0 时按时间压测 忽略 Total
+	// Warmup 以及 Cooldown 期间的操作会正常执行 但不计入统计
+	Duration time.Duration
+	Warmup   time.Duration
+	Cooldown time.Duration
+
+	// Profile 为分阶段的负载曲线 设置后按曲线控制开环速率 并按阶段输出统计结果 详见 ParseProfile
+	Profile string
+
+	// BodySize 单次操作传输的字节数 用于计算 bps 为 0 时不展示 Workload 实现了 Transfer 时不使用
+	BodySize int
+
+	// Output 结果输出格式 可选 table/json/jsonl/csv OutputFile 为空时输出至标准输出
+	Output     string
+	OutputFile string
+
+	// Resource 控制 packetd 进程资源的采集方式
+	Resource ResourceConfig
+
+	// Converge 控制压测结束后等待 packetd 协议指标稳定的方式
+	Converge ConvergeConfig
+
+	// SaveFile 以 JSON Lines 格式追加保存结果 供 compare 命令对比
+	SaveFile string
+
+	// HistogramFile 完整延迟分布的输出文件 `-` 表示标准输出 为空时不输出
+	HistogramFile string
+
+	// MaxErrors 大于 0 时 失败的操作数达到该值后提前中止压测
+	MaxErrors int64
+}
+
+// RegisterFlags 注册 Runner 相关的通用命令行参数
+func (c *RunConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.Rate, "rate", 0, "open-loop constant arrival rate (operations per second), 0 means closed-loop")
+	fs.DurationVar(&c.Duration, "duration", 0, "run for the given duration instead of -total")
+	fs.DurationVar(&c.Warmup, "warmup", 0, "warmup period excluded from statistics, requires -duration")
+	fs.DurationVar(&c.Cooldown, "cooldown", 0, "cooldown period excluded from statistics, requires -duration")
+	fs.StringVar(&c.Profile, "profile", "", "staged load profile, e.g. 'ramp:0->5000qps/60s,hold:120s,spike:20000qps/5s'")
+	fs.StringVar(&c.Output, "output", OutputTable, "result output format, options: table/json/jsonl/csv")
+	fs.StringVar(&c.OutputFile, "output_file", "", "write result to file instead of stdout")
+	fs.StringVar(&c.SaveFile, "save_file", "", "append result record to file for later comparison")
+	fs.StringVar(&c.HistogramFile, "histogram_file", "", "dump full latency histogram to file, '-' for stdout")
+	fs.Int64Var(&c.MaxErrors, "max_errors", 0, "abort the run once failed operations reach this number, 0 means never abort")
+	c.Resource.RegisterFlags(fs)
+	c.Converge.RegisterFlags(fs)
+	RegisterPacketdFlags(fs)
+}
+
+// Runner 驱动 Workload 执行压测并生成结果
+type Runner struct {
+	conf RunConfig
+	wl   Workload
+}
+
+func NewRunner(conf RunConfig, wl Workload) *Runner {
+	if conf.Workers <= 0 {
+		conf.Workers = 1
+	}
+	return &Runner{
+		conf: conf,
+		wl:   wl,
+	}
+}
+
+func (r *Runner) Run() (*Result, error) {
+	switch r.conf.Output {
+	case "", OutputTable, OutputJSON, OutputJSONL, OutputCSV:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", r.conf.Output)
+	}
+
+	var profile Profile
+	if r.conf.Profile != "" {
+		if r.conf.Duration > 0 || r.conf.Rate > 0 {
+			return nil, errors.New("-profile conflicts with -duration and -rate")
+		}
+		p, err := ParseProfile(r.conf.Profile)
+		if err != nil {
+			return nil, err
+		}
+		profile = p
+		r.conf.Duration = profile.Duration()
+	}
+	if r.conf.Duration <= 0 && (r.conf.Warmup > 0 || r.conf.Cooldown > 0) {
+		return nil, errors.New("-warmup and -cooldown require -duration")
+	}
+
+	src, err := r.conf.Resource.NewSource()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.wl.Setup(); err != nil {
+		return nil, err
+	}
+	defer r.wl.Teardown()
+
+	columns := r.wl.Columns()
+	desc := describeColumns(columns)
+	transfer, _ := r.wl.(Transfer)
+
+	var rateAt func(time.Duration) float64
+	switch {
+	case profile != nil:
+		rateAt = func(elapsed time.Duration) float64 {
+			return profile.RateAt(elapsed - r.conf.Warmup)
+		}
+	case r.conf.Rate > 0:
+		rateAt = func(time.Duration) float64 {
+			return r.conf.Rate
+		}
+	}
+
+	start := time.Now()
+	var deadline time.Time
+	var windows []*window
+	switch {
+	case profile != nil:
+		t := start.Add(r.conf.Warmup)
+		for _, stage := range profile {
+			windows = append(windows, newWindow(t, t.Add(stage.Duration), src))
+			t = t.Add(stage.Duration)
+		}
+		deadline = t.Add(r.conf.Cooldown)
+	case r.conf.Duration > 0:
+		t := start.Add(r.conf.Warmup)
+		windows = append(windows, newWindow(t, t.Add(r.conf.Duration), src))
+		deadline = windows[0].end.Add(r.conf.Cooldown)
+	default:
+		windows = append(windows, newWindow(start, time.Time{}, src))
+		if err := windows[0].open(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 延迟执行以及被丢弃的操作按照计划发送时间计入对应的窗口 warmup 以及 cooldown 期间的不计入结果
+	sched := newScheduler(r.conf, rateAt, func(i int) {
+		if r.conf.Duration <= 0 && ShouldLog(r.conf.Total, i) {
+			log.Printf("[%d/%d] %s request, %s\n", i+1, r.conf.Total, r.conf.Proto, desc)
+		}
+	}, func(j job) {
+		if w := findWindow(windows, j.intended); w != nil {
+			w.dropped.Add(1)
+		}
+	})
+
+	abort := make(chan struct{})
+	var watched <-chan error
+	if r.conf.Duration > 0 {
+		watched = watchWindows(windows, abort, sched.Stop)
+		stop := r.logProgress(start, deadline, desc)
+		defer stop()
+	}
+
+	var sampler *ResourceSampler
+	if r.conf.Resource.Interval > 0 {
+		sampler = NewResourceSampler(src, r.conf.Resource.Interval)
+		sampler.Start()
+	}
+
+	ch := sched.Start(start, deadline)
+	var aborted atomic.Bool
+	errs := newErrorCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < r.conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				if j.intended.IsZero() && r.conf.PaceWorkers && r.conf.Interval > 0 {
+					time.Sleep(r.conf.Interval)
+				}
+				t0 := time.Now()
+				err := r.wl.Do(j.idx)
+
+				// 开环模式下从计划发送时间开始计算延迟 避免 coordinated omission
+				var late bool
+				if !j.intended.IsZero() {
+					late = t0.Sub(j.intended) > j.period
+					t0 = j.intended
+				}
+				w := findWindow(windows, t0)
+				if late && w != nil {
+					w.late.Add(1)
+				}
+
+				if err != nil {
+					class := ClassifyError(err)
+					first, total := errs.add(class)
+					if first {
+						log.Printf("first %s error: %v\n", class, err)
+					}
+					if w != nil {
+						w.recordError(class)
+					}
+					if r.conf.MaxErrors > 0 && total >= r.conf.MaxErrors && !aborted.Swap(true) {
+						log.Printf("aborting, errors reached %d\n", r.conf.MaxErrors)
+						sched.Stop()
+					}
+					continue
+				}
+				if w != nil {
+					w.record(time.Since(t0))
+					if transfer != nil {
+						w.recordTransfer(transfer.Transferred(j.idx))
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if aborted.Load() {
+		close(abort)
+	}
+
+	metric := r.conf.Proto + "_requests_total"
+	result := &Result{
+		Proto:    r.conf.Proto,
+		Rate:     r.conf.Rate,
+		Aborted:  aborted.Load(),
+		Errors:   make(ErrorCounts),
+		Workers:  r.conf.Workers,
+		BodySize: r.conf.BodySize,
+		Transfer: transfer != nil,
+		Columns:  columns,
+		Latency:  NewHistogram(),
+	}
+
+	if watched == nil {
+		win := windows[0]
+		result.Total = int(win.completed.Load())
+		result.Elapsed = time.Since(start)
+		result.Latency = win.latency
+		result.Late = win.late.Load()
+		result.Dropped = win.dropped.Load()
+		result.Errors = win.errors.snapshot()
+		result.RequestBytes = win.requestBytes.Load()
+		result.ResponseBytes = win.responseBytes.Load()
+		resource, err := win.rr.End()
+		if err != nil {
+			log.Println(err)
+		}
+		result.Resource = resource
+		if sampler != nil {
+			sampler.Stop()
+			sampler.Peak(&result.Resource, win.start, time.Time{})
+			result.Timeline = sampler.Points()
+		}
+
+		cvg, err := WaitProtocolStable(r.conf.Converge, metric, win.protoStart, float64(result.Total))
+		if err != nil {
+			return nil, err
+		}
+		win.protoEnd = cvg.Samples
+		result.ProtoRequests, result.ProtoResets = win.protoDelta(metric)
+		result.setConvergence(cvg)
+		return result, nil
+	}
+
+	err = <-watched
+	if sampler != nil {
+		sampler.Stop()
+		result.Timeline = sampler.Points()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有 cooldown 或者提前中止时最后一个窗口结束后不再有流量 等待协议指标稳定后再计算增量
+	if r.conf.Cooldown <= 0 || result.Aborted {
+		last := lastOpened(windows)
+		cvg, err := WaitProtocolStable(r.conf.Converge, metric, last.protoStart, float64(last.completed.Load()))
+		if err != nil {
+			return nil, err
+		}
+		last.protoEnd = cvg.Samples
+		result.setConvergence(cvg)
+	}
+	var cpuSeconds float64
+	for i, win := range windows {
+		if !win.opened {
+			continue
+		}
+		stage := &Result{
+			Proto:         r.conf.Proto,
+			Workers:       r.conf.Workers,
+			BodySize:      r.conf.BodySize,
+			Transfer:      transfer != nil,
+			RequestBytes:  win.requestBytes.Load(),
+			ResponseBytes: win.responseBytes.Load(),
+			Total:         int(win.completed.Load()),
+			Elapsed:       win.end.Sub(win.start),
+			Latency:       win.latency,
+			Late:          win.late.Load(),
+			Dropped:       win.dropped.Load(),
+			Errors:        win.errors.snapshot(),
+			Resource:      win.resource,
+		}
+		stage.ProtoRequests, stage.ProtoResets = win.protoDelta(metric)
+		if sampler != nil {
+			sampler.Peak(&stage.Resource, win.start, win.end)
+		}
+		if profile != nil {
+			stage.Stage = profile[i].String()
+			result.Stages = append(result.Stages, stage)
+		}
+
+		result.Total += stage.Total
+		result.Late += stage.Late
+		result.Dropped += stage.Dropped
+		result.Elapsed += stage.Elapsed
+		result.RequestBytes += stage.RequestBytes
+		result.ResponseBytes += stage.ResponseBytes
+		result.ProtoRequests += stage.ProtoRequests
+		result.ProtoResets += stage.ProtoResets
+		result.Latency.Merge(stage.Latency)
+		result.Errors.Merge(stage.Errors)
+		result.Resource.Mem = stage.Resource.Mem
+		result.Resource.CtxSwitches += stage.Resource.CtxSwitches
+		result.Resource.ReadBytes += stage.Resource.ReadBytes
+		result.Resource.WriteBytes += stage.Resource.WriteBytes
+		result.Resource.Sampled = stage.Resource.Sampled
+		result.Resource.PeakCPU = math.Max(result.Resource.PeakCPU, stage.Resource.PeakCPU)
+		result.Resource.PeakMem = math.Max(result.Resource.PeakMem, stage.Resource.PeakMem)
+		result.Resource.PeakHeap = math.Max(result.Resource.PeakHeap, stage.Resource.PeakHeap)
+		result.Resource.PeakGoroutines = math.Max(result.Resource.PeakGoroutines, stage.Resource.PeakGoroutines)
+		cpuSeconds += stage.Resource.CPU * stage.Elapsed.Seconds()
+	}
+	if result.Elapsed > 0 {
+		result.Resource.CPU = cpuSeconds / result.Elapsed.Seconds()
+	}
+	return result, nil
+}
+
+func lastOpened(windows []*window) *window {
+	last := windows[0]
+	for _, w := range windows {
+		if w.opened {
+			last = w
+		}
+	}
+	return last
+}
+
+func findWindow(windows []*window, t time.Time) *window {
+	for _, w := range windows {
+		if w.end.IsZero() || w.contains(t) {
+			return w
+		}
+	}
+	return nil
+}
+
+// logProgress 在按时间压测时定期输出当前所处的阶段
+func (r *Runner) logProgress(start, deadline time.Time, desc string) func() {
+	total := deadline.Sub(start)
+	ticker := time.NewTicker(total / 10)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				phase := "measure"
+				switch elapsed := now.Sub(start); {
+				case elapsed < r.conf.Warmup:
+					phase = "warmup"
+				case elapsed >= r.conf.Warmup+r.conf.Duration:
+					phase = "cooldown"
+				}
+				log.Printf("[%s/%s] %s request (%s), %s\n",
+					now.Sub(start).Truncate(time.Second), total, r.conf.Proto, phase, desc)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Report 输出压测结果
+func (r *Runner) Report(result *Result) error {
+	if err := r.writeResult(result); err != nil {
+		return err
+	}
+	if err := r.writeFiles(result); err != nil {
+		return err
+	}
+	if result.Aborted {
+		return fmt.Errorf("run aborted, errors reached -max_errors=%d", r.conf.MaxErrors)
+	}
+	return nil
+}
+
+// Record 返回结果对应的结构化输出
+func (r *Runner) Record(result *Result) *Record {
+	return NewRecord(r.conf, result)
+}
+
+// writeFiles 输出 SaveFile ResourceFile 以及 HistogramFile 等结果文件
+func (r *Runner) writeFiles(result *Result) error {
+	if r.conf.SaveFile != "" {
+		if err := SaveRecord(r.conf.SaveFile, NewRecord(r.conf, result)); err != nil {
+			return err
+		}
+	}
+	if r.conf.Resource.File != "" {
+		if err := writeFile(r.conf.Resource.File, func(w io.Writer) error {
+			return WriteResourceTimeline(w, result.Timeline)
+		}); err != nil {
+			return err
+		}
+	}
+	return r.writeHistogram(result)
+}
+
+func (r *Runner) writeHistogram(result *Result) error {
+	switch r.conf.HistogramFile {
+	case "":
+		return nil
+	case "-":
+		return result.Latency.WriteDistribution(os.Stdout)
+	}
+	return writeFile(r.conf.HistogramFile, result.Latency.WriteDistribution)
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return write(f)
+}
+
+func (r *Runner) writeResult(result *Result) error {
+	format := r.conf.Output
+	if format == "" {
+		format = OutputTable
+	}
+
+	w, err := openOutput(r.conf.OutputFile, format)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if format != OutputTable {
+		return WriteRecord(w, format, NewRecord(r.conf, result))
+	}
+
+	PrintTable(w, result)
+	if len(result.Stages) > 0 {
+		PrintStageTable(w, result)
+	}
+	return nil
+}
+
+func describeColumns(columns []Column) string {
+	parts := make([]string, 0, len(columns))
+	for _, c := range columns {
+		parts = append(parts, fmt.Sprintf("%s=%v", c.Name, c.Value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+// sleepWorkload 每次操作耗时 d 在 slowUntil 之前耗时 slow
+type sleepWorkload struct {
+	d         time.Duration
+	slow      time.Duration
+	slowUntil time.Time
+}
+
+func (w sleepWorkload) Setup() error    { return nil }
+func (w sleepWorkload) Teardown() error { return nil }
+
+func (w sleepWorkload) Do(int) error {
+	if time.Now().Before(w.slowUntil) {
+		time.Sleep(w.slow)
+		return nil
+	}
+	time.Sleep(w.d)
+	return nil
+}
+
+func (w sleepWorkload) Columns() []Column { return nil }
+
+func TestRunnerOpenLoop(t *testing.T) {
+	fakeProtocolMetrics(t, func(int64) int64 { return 0 })
+	conf := RunConfig{
+		Proto:    "http",
+		Workers:  1,
+		Duration: 300 * time.Millisecond,
+		Converge: ConvergeConfig{Interval: 5 * time.Millisecond, Quiet: 20 * time.Millisecond, Timeout: time.Second},
+	}
+
+	t.Run("keeping up", func(t *testing.T) {
+		conf := conf
+		conf.Rate = 20
+		r, err := NewRunner(conf, sleepWorkload{d: time.Millisecond}).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Total == 0 || r.Late != 0 || r.Dropped != 0 {
+			t.Errorf("want no late or dropped jobs, got total=%d late=%d dropped=%d", r.Total, r.Late, r.Dropped)
+		}
+	})
+
+	t.Run("falling behind", func(t *testing.T) {
+		// 每 5ms 调度一次 但每次操作耗时 20ms 唯一的 worker 无法跟上速率
+		conf := conf
+		conf.Rate = 200
+		r, err := NewRunner(conf, sleepWorkload{d: 20 * time.Millisecond}).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Late == 0 || r.Dropped == 0 {
+			t.Errorf("want late and dropped jobs, got total=%d late=%d dropped=%d", r.Total, r.Late, r.Dropped)
+		}
+
+		// 延迟从计划发送时间开始计算 包含了在队列中等待的时间
+		if p50 := r.Latency.Percentile(50); p50 < 25*time.Millisecond {
+			t.Errorf("want latency to include queueing delay, got p50 %v", p50)
+		}
+	})
+	t.Run("warmup excluded", func(t *testing.T) {
+		// 仅 warmup 期间无法跟上速率 之后的延迟执行以及丢弃不应计入结果
+		conf := conf
+		conf.Rate = 100
+		conf.Warmup = 200 * time.Millisecond
+		wl := sleepWorkload{d: time.Millisecond, slow: 30 * time.Millisecond, slowUntil: time.Now().Add(100 * time.Millisecond)}
+		r, err := NewRunner(conf, wl).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Total == 0 || r.Late != 0 || r.Dropped != 0 {
+			t.Errorf("want warmup late and dropped jobs excluded, got total=%d late=%d dropped=%d", r.Total, r.Late, r.Dropped)
+		}
+	})
+}
@@ -0,0 +1,434 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario 描述一组按顺序执行的压测 支持 YAML 以及 JSON 格式
+//
+//	name: release
+//	pause: 10s
+//	workloads:
+//	  - name: http-1kb
+//	    command: http client
+//	    flags:
+//	      addr: localhost:8083
+//	      workers: 8
+//	      duration: 30s
+//	      body_size: 1KB
+//	  - command: redis client
+//	    pause: 30s
+//	    flags: {cmd: set, body_size: 64KB, total: 100000}
+type Scenario struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Pause 为相邻两个压测之间的默认间隔
+	Pause string `json:"pause" yaml:"pause"`
+
+	Workloads []ScenarioWorkload `json:"workloads" yaml:"workloads"`
+}
+
+// ScenarioWorkload 为场景中的单个压测
+type ScenarioWorkload struct {
+	// Name 为空时使用 Command
+	Name string `json:"name" yaml:"name"`
+
+	// Command 为子命令名称 如 `http client` 仅支持基于 Runner 的子命令
+	Command string `json:"command" yaml:"command"`
+
+	// Flags 为子命令的命令行参数 不包含前缀 `-`
+	Flags map[string]any `json:"flags" yaml:"flags"`
+
+	// Pause 为该压测结束后的间隔 为空时使用 Scenario.Pause
+	Pause string `json:"pause" yaml:"pause"`
+}
+
+// LoadScenario 加载场景文件 扩展名为 .json 时按照 JSON 解析 否则按照 YAML 解析
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Scenario
+	if filepath.Ext(path) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&s)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(&s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func (w ScenarioWorkload) name() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return w.Command
+}
+
+func (w ScenarioWorkload) pause(def time.Duration) (time.Duration, error) {
+	if w.Pause == "" {
+		return def, nil
+	}
+	return time.ParseDuration(w.Pause)
+}
+
+// ScenarioResult 为场景中单个压测的结果 Err 不为空时表示压测失败
+type ScenarioResult struct {
+	Name   string
+	Runner *Runner
+	Result *Result
+	Err    error
+}
+
+// scenarioRunner 负责创建以及执行场景中的压测
+type scenarioRunner struct {
+	prog     string
+	commands Commands
+}
+
+// prepare 检查场景中所有压测的参数 在执行之前发现配置错误 返回每个压测结束后的间隔
+func (sr *scenarioRunner) prepare(s *Scenario) ([]time.Duration, error) {
+	if len(s.Workloads) == 0 {
+		return nil, errors.New("scenario has no workloads")
+	}
+
+	var def time.Duration
+	if s.Pause != "" {
+		d, err := time.ParseDuration(s.Pause)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario pause: %w", err)
+		}
+		def = d
+	}
+
+	pauses := make([]time.Duration, 0, len(s.Workloads))
+	for i, w := range s.Workloads {
+		if _, err := sr.workload(w); err != nil {
+			return nil, fmt.Errorf("workload #%d (%s): %w", i+1, w.name(), err)
+		}
+		pause, err := w.pause(def)
+		if err != nil {
+			return nil, fmt.Errorf("workload #%d (%s): invalid pause: %w", i+1, w.name(), err)
+		}
+		pauses = append(pauses, pause)
+	}
+	return pauses, nil
+}
+
+// workload 使用 Flags 设置子命令参数 返回创建 Runner 的函数
+//
+// 同一个子命令的参数绑定在相同的变量上 因此需要在执行前重新设置
+func (sr *scenarioRunner) workload(w ScenarioWorkload) (func() *Runner, error) {
+	cmd, rest := sr.commands.Lookup(strings.Fields(w.Command))
+	if cmd == nil || len(rest) > 0 {
+		return nil, fmt.Errorf("unknown command %q", w.Command)
+	}
+	if cmd.Runner == nil {
+		return nil, fmt.Errorf("command %q is not a benchmark", w.Command)
+	}
+
+	fs := cmd.FlagSet(sr.prog)
+	fs.SetOutput(io.Discard)
+	if err := setFlags(fs, w.Flags); err != nil {
+		return nil, err
+	}
+	return cmd.Runner, nil
+}
+
+// setFlags 按照名称顺序设置 fs 中的参数
+func setFlags(fs *flag.FlagSet, flags map[string]any) error {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag -%s", name)
+		}
+		if err := fs.Set(name, flagValue(flags[name])); err != nil {
+			return fmt.Errorf("invalid value for flag -%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flagValue 将场景文件中的取值转换为参数字符串
+//
+// JSON 中的数字以及 YAML 中的浮点数解析为 float64 fmt.Sprint 会将较大的整数格式化为科学计数法 如 1e+06
+func flagValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+// Run 依次执行场景中的压测 单个压测失败时继续执行后续的压测
+func (sr *scenarioRunner) Run(s *Scenario) ([]*ScenarioResult, error) {
+	pauses, err := sr.prepare(s)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ScenarioResult, 0, len(s.Workloads))
+	for i, w := range s.Workloads {
+		log.Printf("[%d/%d] scenario workload %s\n", i+1, len(s.Workloads), w.name())
+		res := sr.run(w)
+		if res.Err != nil {
+			log.Printf("scenario workload %s failed: %v\n", res.Name, res.Err)
+		}
+		results = append(results, res)
+
+		if i < len(s.Workloads)-1 && pauses[i] > 0 {
+			log.Printf("pause %s before next workload\n", pauses[i])
+			time.Sleep(pauses[i])
+		}
+	}
+	return results, nil
+}
+
+func (sr *scenarioRunner) run(w ScenarioWorkload) *ScenarioResult {
+	newRunner, err := sr.workload(w)
+	if err != nil {
+		return &ScenarioResult{Name: w.name(), Err: err}
+	}
+	return runWorkload(w.name(), newRunner)
+}
+
+// runWorkload 执行单个压测并输出结果文件 被中止的压测视为失败
+func runWorkload(name string, newRunner func() *Runner) *ScenarioResult {
+	res := &ScenarioResult{Name: name}
+	res.Runner = newRunner()
+	res.Result, res.Err = res.Runner.Run()
+	if res.Err != nil {
+		return res
+	}
+	if err := res.Runner.writeFiles(res.Result); err != nil {
+		res.Err = err
+		return res
+	}
+	if res.Result.Aborted {
+		res.Err = errors.New("aborted")
+	}
+	return res
+}
+
+// ScenarioRecord 为场景的结构化输出
+type ScenarioRecord struct {
+	SchemaVersion int       `json:"schema_version"`
+	Timestamp     time.Time `json:"timestamp"`
+	Name          string    `json:"name"`
+	Workloads     []*Record `json:"workloads"`
+	Failed        []string  `json:"failed,omitempty"`
+}
+
+// scenarioRecords 返回所有产生了结果的压测记录 以及失败的压测名称
+func scenarioRecords(results []*ScenarioResult) ([]*Record, []string) {
+	var recs []*Record
+	var failed []string
+	for _, r := range results {
+		if r.Result == nil {
+			failed = append(failed, r.Name)
+			continue
+		}
+		if r.Err != nil {
+			failed = append(failed, r.Name)
+		}
+		rec := r.Runner.Record(r.Result)
+		rec.Name = r.Name
+		recs = append(recs, rec)
+	}
+	return recs, failed
+}
+
+// WriteScenario 按照 format 输出场景中所有压测的汇总结果
+func WriteScenario(w io.Writer, format, name string, results []*ScenarioResult) error {
+	recs, failed := scenarioRecords(results)
+	switch format {
+	case "", OutputTable:
+		PrintScenarioTable(w, results)
+		return nil
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&ScenarioRecord{
+			SchemaVersion: SchemaVersion,
+			Timestamp:     time.Now(),
+			Name:          name,
+			Workloads:     recs,
+			Failed:        failed,
+		})
+	case OutputJSONL:
+		enc := json.NewEncoder(w)
+		for _, rec := range recs {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputCSV:
+		return writeCSV(w, recs)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// PrintScenarioTable 以表格形式输出场景中每个压测的主要指标
+func PrintScenarioTable(w io.Writer, results []*ScenarioResult) {
+	header := []interface{}{
+		"workload",
+		"proto",
+		"request",
+		"elapsed",
+		"qps",
+		"error (percent)",
+		"p50",
+		"p99",
+		"proto (request)",
+		"proto (percent)",
+		"cpu (core)",
+		"memory (MB)",
+		"status",
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(header)
+	for _, sr := range results {
+		status := "ok"
+		if sr.Err != nil {
+			status = sr.Err.Error()
+		}
+
+		r := sr.Result
+		if r == nil {
+			t.AppendRow([]interface{}{sr.Name, "-", "-", "-", "-", "-", "-", "-", "-", "-", "-", "-", status})
+			continue
+		}
+		t.AppendRow([]interface{}{
+			sr.Name,
+			r.Proto,
+			r.Total,
+			fmt.Sprintf("%.3fs", r.Elapsed.Seconds()),
+			fmt.Sprintf("%.3f", r.QPS()),
+			fmt.Sprintf("%.3f%%", r.ErrorPercent()),
+			FormatLatency(r.Latency.Percentile(50)),
+			FormatLatency(r.Latency.Percentile(99)),
+			int(r.ProtoRequests),
+			fmt.Sprintf("%.3f%%", r.ProtoPercent()),
+			fmt.Sprintf("%.3f", r.Resource.CPU),
+			fmt.Sprintf("%.3f", r.Resource.Mem/1024/1024),
+			status,
+		})
+	}
+	t.AppendSeparator()
+	t.Render()
+}
+
+// ScenarioConfig 为 scenario 子命令的参数
+type ScenarioConfig struct {
+	Output     string
+	OutputFile string
+	SaveFile   string
+}
+
+// NewScenarioCommand 返回 `scenario` 子命令 场景中的压测从 commands 中查找
+func NewScenarioCommand(prog string, commands Commands) *Command {
+	var c ScenarioConfig
+	return &Command{
+		Name:      "scenario",
+		Usage:     "run benchmarks described in a yaml/json scenario file and print a consolidated report",
+		ArgsUsage: "<scenario file>",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Output, "output", OutputTable, "result output format, options: table/json/jsonl/csv")
+			fs.StringVar(&c.OutputFile, "output_file", "", "write result to file instead of stdout")
+			fs.StringVar(&c.SaveFile, "save_file", "", "append all result records to file for later comparison")
+		},
+		Run: func(args []string) error {
+			if len(args) != 1 {
+				return errors.New("scenario requires exactly one scenario file")
+			}
+			return RunScenario(prog, commands, args[0], c)
+		},
+	}
+}
+
+// RunScenario 执行场景文件 path 并输出汇总结果 存在失败的压测时返回错误
+func RunScenario(prog string, commands Commands, path string, c ScenarioConfig) error {
+	switch c.Output {
+	case "", OutputTable, OutputJSON, OutputJSONL, OutputCSV:
+	default:
+		return fmt.Errorf("unknown output format %q", c.Output)
+	}
+
+	s, err := LoadScenario(path)
+	if err != nil {
+		return err
+	}
+	sr := &scenarioRunner{prog: prog, commands: commands}
+	results, err := sr.Run(s)
+	if err != nil {
+		return err
+	}
+
+	w, err := openOutput(c.OutputFile, c.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := WriteScenario(w, c.Output, s.Name, results); err != nil {
+		return err
+	}
+
+	recs, failed := scenarioRecords(results)
+	if c.SaveFile != "" {
+		for _, rec := range recs {
+			if err := SaveRecord(c.SaveFile, rec); err != nil {
+				return err
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d workload(s) failed: %v", len(failed), failed)
+	}
+	return nil
+}
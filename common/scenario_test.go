@@ -0,0 +1,106 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScenarioFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "json",
+			file: "scenario.json",
+			content: `{"workloads": [{"command": "http client", "flags": {
+				"total": 1000000, "rate": 2500.5, "duration": "90s", "body_size": "64KB", "echo": true}}]}`,
+		},
+		{
+			name: "yaml",
+			file: "scenario.yaml",
+			content: `
+workloads:
+  - command: http client
+    flags:
+      total: 1000000
+      rate: 2500.5
+      duration: 90s
+      body_size: 64KB
+      echo: true
+`,
+		},
+		{
+			name: "yaml exponent",
+			file: "scenario.yml",
+			content: `
+workloads:
+  - command: http client
+    flags:
+      total: 1.0e+6
+      rate: 2500.5
+      duration: 90s
+      body_size: 64KB
+      echo: true
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := LoadScenario(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				total    int
+				rate     float64
+				duration time.Duration
+				bodySize string
+				echo     bool
+			)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.IntVar(&total, "total", 1, "")
+			fs.Float64Var(&rate, "rate", 0, "")
+			fs.DurationVar(&duration, "duration", 0, "")
+			fs.StringVar(&bodySize, "body_size", "1KB", "")
+			fs.BoolVar(&echo, "echo", false, "")
+
+			if err := setFlags(fs, s.Workloads[0].Flags); err != nil {
+				t.Fatal(err)
+			}
+			if total != 1000000 || rate != 2500.5 || duration != 90*time.Second || bodySize != "64KB" || !echo {
+				t.Errorf("got total=%d rate=%v duration=%v body_size=%s echo=%v", total, rate, duration, bodySize, echo)
+			}
+		})
+	}
+}
+
+func TestScenarioUnknownFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := setFlags(fs, map[string]any{"total": 1}); err == nil {
+		t.Error("want error for unknown flag")
+	}
+}
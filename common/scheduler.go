@@ -0,0 +1,189 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleStep 为开环模式下重新采样速率的最大步长 目标速率为 0 或者较低时按该步长推进
+const idleStep = 10 * time.Millisecond
+
+// job 为一次待执行的操作
+type job struct {
+	idx int
+
+	// intended 为开环模式下计划发送的时间 闭环模式下为零值
+	intended time.Time
+
+	// period 为计划发送时刻对应的调度间隔 执行时间晚于 intended+period 时视为延迟
+	period time.Duration
+}
+
+// scheduler 负责向 worker 派发操作
+//
+// 闭环模式下 worker 完成一次操作后才会领取下一次操作 Interval 用于控制派发间隔 PaceWorkers 时由 worker 自行等待
+// 开环模式下按照 rateAt 给出的速率派发 与操作是否完成无关 当所有 worker 都处于繁忙状态且队列已满时丢弃该次操作
+type scheduler struct {
+	conf     RunConfig
+	rateAt   func(elapsed time.Duration) float64
+	deadline time.Time
+	onSend   func(i int)
+	onDrop   func(j job)
+	dropped  atomic.Int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newScheduler 创建调度器 rateAt 为空时使用闭环模式 onDrop 不为空时在开环模式丢弃操作时调用
+func newScheduler(conf RunConfig, rateAt func(time.Duration) float64, onSend func(i int), onDrop func(j job)) *scheduler {
+	return &scheduler{
+		conf:   conf,
+		rateAt: rateAt,
+		onSend: onSend,
+		onDrop: onDrop,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Stop 提前结束派发 已经派发的操作仍会被执行
+func (s *scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *scheduler) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Start 开始派发操作 deadline 非零值时按时间结束派发 否则派发 Total 次操作
+func (s *scheduler) Start(start, deadline time.Time) <-chan job {
+	s.deadline = deadline
+	if s.rateAt != nil {
+		ch := make(chan job, s.conf.Workers)
+		go s.openLoop(start, ch)
+		return ch
+	}
+
+	ch := make(chan job, 1)
+	go s.closedLoop(ch)
+	return ch
+}
+
+func (s *scheduler) closedLoop(ch chan<- job) {
+	defer close(ch)
+	for i := 0; s.more(i, time.Now()); i++ {
+		if s.conf.Interval > 0 && !s.conf.PaceWorkers {
+			time.Sleep(s.conf.Interval)
+		}
+		s.onSend(i)
+		ch <- job{idx: i}
+	}
+}
+
+// openLoop 按照速率曲线的积分派发操作 即曲线下的面积每增加 1 派发一次
+//
+// 速率每隔最多 idleStep 重新采样一次 避免低速率时按单个时刻的速率计算出过长的间隔 错过之后的速率变化
+func (s *scheduler) openLoop(start time.Time, ch chan<- job) {
+	defer close(ch)
+
+	var i int
+	next := start
+	owed := 1.0 // 距离下一次派发累计的面积 达到 1 时派发
+	for s.more(i, next) {
+		// 一次性派发所有已到期的操作 避免 time.Sleep 的精度影响高速率下的调度
+		now := time.Now()
+		for !next.After(now) && s.more(i, next) {
+			rate := s.rateAt(next.Sub(start))
+			if rate <= 0 {
+				next = next.Add(idleStep)
+				continue
+			}
+
+			if owed >= 1 {
+				period := time.Duration(float64(time.Second) / rate)
+				if period <= 0 {
+					period = time.Nanosecond
+				}
+
+				s.onSend(i)
+				j := job{
+					idx:      i,
+					intended: next,
+					period:   period,
+				}
+				select {
+				case ch <- j:
+				default:
+					s.dropped.Add(1)
+					if s.onDrop != nil {
+						s.onDrop(j)
+					}
+				}
+				i++
+				owed = 0
+			}
+
+			need := time.Duration((1 - owed) * float64(time.Second) / rate)
+			if need > idleStep {
+				owed += rate * idleStep.Seconds()
+				next = next.Add(idleStep)
+				continue
+			}
+			if need <= 0 {
+				need = time.Nanosecond
+			}
+			owed = 1
+			next = next.Add(need)
+		}
+
+		s.sleepUntil(next)
+	}
+}
+
+// sleepUntil 等待至 t 时刻 不会超过 deadline 调用 Stop 时立即返回
+func (s *scheduler) sleepUntil(t time.Time) {
+	if !s.deadline.IsZero() && t.After(s.deadline) {
+		t = s.deadline
+	}
+	d := time.Until(t)
+	if d <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.stop:
+	}
+}
+
+// more 判断计划在 t 时刻发送的第 i 次操作是否需要派发
+func (s *scheduler) more(i int, t time.Time) bool {
+	select {
+	case <-s.stop:
+		return false
+	default:
+	}
+
+	if !s.deadline.IsZero() {
+		return t.Before(s.deadline)
+	}
+	return i < s.conf.Total
+}
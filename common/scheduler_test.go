@@ -0,0 +1,240 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerOpenLoop(t *testing.T) {
+	start := time.Now()
+
+	t.Run("intended times", func(t *testing.T) {
+		s := newScheduler(RunConfig{Workers: 1000}, func(time.Duration) float64 { return 100 }, func(int) {}, nil)
+		jobs := collect(s.Start(start.Add(-time.Hour), start.Add(-time.Hour+time.Second)))
+
+		if len(jobs) != 100 {
+			t.Fatalf("want 100 jobs, got %d", len(jobs))
+		}
+		for i, j := range jobs {
+			want := start.Add(-time.Hour + time.Duration(i)*10*time.Millisecond)
+			if j.idx != i || !j.intended.Equal(want) || j.period != 10*time.Millisecond {
+				t.Fatalf("job %d: want intended %v period 10ms, got idx=%d intended %v period %v", i, want, j.idx, j.intended, j.period)
+			}
+		}
+	})
+
+	t.Run("stage boundary", func(t *testing.T) {
+		// 前 100ms 为 100qps 之后为 1000qps 跨越边界的操作按照其计划时刻的速率调度
+		rateAt := func(elapsed time.Duration) float64 {
+			if elapsed < 100*time.Millisecond {
+				return 100
+			}
+			return 1000
+		}
+		base := start.Add(-time.Hour)
+		s := newScheduler(RunConfig{Workers: 1000}, rateAt, func(int) {}, nil)
+		jobs := collect(s.Start(base, base.Add(200*time.Millisecond)))
+
+		if len(jobs) != 110 {
+			t.Fatalf("want 110 jobs, got %d", len(jobs))
+		}
+		for i, j := range jobs {
+			want, period := time.Duration(i)*10*time.Millisecond, 10*time.Millisecond
+			if i >= 10 {
+				want, period = 100*time.Millisecond+time.Duration(i-10)*time.Millisecond, time.Millisecond
+			}
+			if got := j.intended.Sub(base); got != want || j.period != period {
+				t.Fatalf("job %d: want offset %v period %v, got %v %v", i, want, period, got, j.period)
+			}
+		}
+	})
+
+	t.Run("zero rate", func(t *testing.T) {
+		// 速率为 0 的阶段不派发操作 调度器按 idleStep 推进到下一个阶段
+		rateAt := func(elapsed time.Duration) float64 {
+			if elapsed < 55*time.Millisecond {
+				return 0
+			}
+			return 100
+		}
+		base := start.Add(-time.Hour)
+		var sent atomic.Int64
+		s := newScheduler(RunConfig{Workers: 1000}, rateAt, func(int) { sent.Add(1) }, nil)
+		jobs := collect(s.Start(base, base.Add(100*time.Millisecond)))
+
+		if len(jobs) != 4 || sent.Load() != 4 {
+			t.Fatalf("want 4 jobs, got %d sent %d", len(jobs), sent.Load())
+		}
+		if got := jobs[0].intended.Sub(base); got != 6*idleStep {
+			t.Errorf("first job: want offset %v, got %v", 6*idleStep, got)
+		}
+		if s.Dropped() != 0 {
+			t.Errorf("want no drops, got %d", s.Dropped())
+		}
+	})
+
+	t.Run("ramp from zero", func(t *testing.T) {
+		// 曲线下的面积为 t²/3 + 2*(t-3) 第 i 次派发发生在面积达到 i 时
+		p, err := ParseProfile("ramp:0->2qps/3s,hold:2s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := start.Add(-time.Hour)
+		s := newScheduler(RunConfig{Workers: 1000}, p.RateAt, func(int) {}, nil)
+		jobs := collect(s.Start(base, base.Add(p.Duration())))
+
+		if len(jobs) != 7 {
+			t.Fatalf("want 7 jobs, got %d", len(jobs))
+		}
+		want := []time.Duration{
+			idleStep,
+			1732 * time.Millisecond, // sqrt(3)
+			2449 * time.Millisecond, // sqrt(6)
+			3000 * time.Millisecond,
+			3500 * time.Millisecond,
+			4000 * time.Millisecond,
+			4500 * time.Millisecond,
+		}
+		for i, j := range jobs {
+			if got := j.intended.Sub(base); got < want[i]-idleStep || got > want[i]+idleStep {
+				t.Errorf("job %d: want offset about %v, got %v", i, want[i], got)
+			}
+		}
+	})
+
+	t.Run("step up", func(t *testing.T) {
+		// 低速率阶段的间隔不能跳过之后的高速率阶段
+		rateAt := func(elapsed time.Duration) float64 {
+			if elapsed < 50*time.Millisecond {
+				return 1
+			}
+			return 1000
+		}
+		base := start.Add(-time.Hour)
+		s := newScheduler(RunConfig{Workers: 1000}, rateAt, func(int) {}, nil)
+		jobs := collect(s.Start(base, base.Add(100*time.Millisecond)))
+
+		if len(jobs) != 51 {
+			t.Fatalf("want 51 jobs, got %d", len(jobs))
+		}
+		if got := jobs[1].intended.Sub(base); got < 50*time.Millisecond || got > 51*time.Millisecond {
+			t.Errorf("want the second job right after the step, got offset %v", got)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		// 派发在 deadline 结束 不会等待按照低速率计算出的下一次派发时间
+		p, err := ParseProfile("ramp:0->2qps/3s,hold:2s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		begin := time.Now()
+		s := newScheduler(RunConfig{Workers: 1000}, p.RateAt, func(int) {}, nil)
+		collect(s.Start(begin, begin.Add(200*time.Millisecond)))
+		if elapsed := time.Since(begin); elapsed > time.Second {
+			t.Errorf("want dispatch finished at the deadline, elapsed %v", elapsed)
+		}
+	})
+
+	t.Run("dropped", func(t *testing.T) {
+		// 没有 worker 领取时 超出队列容量的操作被丢弃 但仍计入派发次数
+		base := start.Add(-time.Hour)
+		var sent atomic.Int64
+		var dropped []job
+		s := newScheduler(RunConfig{Workers: 4}, func(time.Duration) float64 { return 1000 }, func(int) { sent.Add(1) }, func(j job) {
+			dropped = append(dropped, j)
+		})
+		ch := s.Start(base, base.Add(100*time.Millisecond))
+		waitDispatched(t, s, ch, 100)
+
+		jobs := collect(ch)
+		if len(jobs) != 4 {
+			t.Fatalf("want 4 queued jobs, got %d", len(jobs))
+		}
+		if sent.Load() != 100 || s.Dropped() != 96 {
+			t.Errorf("want 100 sent 96 dropped, got %d sent %d dropped", sent.Load(), s.Dropped())
+		}
+		for i, j := range jobs {
+			if j.idx != i {
+				t.Errorf("queued job %d: want the earliest jobs kept, got idx %d", i, j.idx)
+			}
+		}
+		if len(dropped) != 96 || dropped[0].idx != 4 || !dropped[0].intended.Equal(base.Add(4*time.Millisecond)) {
+			t.Errorf("want onDrop called for every dropped job with its intended time")
+		}
+	})
+}
+
+func TestSchedulerClosedLoop(t *testing.T) {
+	t.Run("total", func(t *testing.T) {
+		s := newScheduler(RunConfig{Workers: 4, Total: 10}, nil, func(int) {}, nil)
+		jobs := collect(s.Start(time.Now(), time.Time{}))
+
+		if len(jobs) != 10 {
+			t.Fatalf("want 10 jobs, got %d", len(jobs))
+		}
+		for i, j := range jobs {
+			if j.idx != i || !j.intended.IsZero() {
+				t.Errorf("job %d: want idx %d without intended time, got idx=%d intended %v", i, i, j.idx, j.intended)
+			}
+		}
+	})
+
+	t.Run("pace workers", func(t *testing.T) {
+		// PaceWorkers 时由 worker 等待 Interval 调度器不应等待
+		s := newScheduler(RunConfig{Workers: 4, Total: 10, Interval: time.Second, PaceWorkers: true}, nil, func(int) {}, nil)
+		begin := time.Now()
+		if jobs := collect(s.Start(begin, time.Time{})); len(jobs) != 10 {
+			t.Fatalf("want 10 jobs, got %d", len(jobs))
+		}
+		if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+			t.Errorf("scheduler waited for the interval, elapsed %v", elapsed)
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		s := newScheduler(RunConfig{Workers: 1, Total: 1 << 30}, nil, func(int) {}, nil)
+		ch := s.Start(time.Now(), time.Time{})
+		<-ch
+		s.Stop()
+		if jobs := collect(ch); len(jobs) > 2 {
+			t.Errorf("want dispatch stopped, got %d more jobs", len(jobs))
+		}
+	})
+}
+
+func collect(ch <-chan job) []job {
+	var jobs []job
+	for j := range ch {
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// waitDispatched 等待调度器处理完 n 次操作 期间不领取 channel 中的操作
+func waitDispatched(t *testing.T, s *scheduler, ch <-chan job, n int64) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for s.Dropped()+int64(len(ch)) < n {
+		select {
+		case <-timeout:
+			t.Fatalf("scheduler dispatched %d of %d jobs", s.Dropped()+int64(len(ch)), n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// SearchConfig 控制捕获饱和点的搜索
+//
+// 从 MinRate 开始以开环速率压测 速率逐次翻倍直到 proto (percent) 低于 Threshold
+// 之后在最后一次满足以及第一次不满足的速率之间二分查找
+type SearchConfig struct {
+	Enabled   bool
+	Threshold float64
+	MinRate   float64
+	MaxRate   float64
+
+	// Precision 为满足以及不满足的速率之间的相对差距 小于该值时停止搜索
+	Precision float64
+	MaxProbes int
+}
+
+func (c *SearchConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Enabled, "search", false, "binary-search the highest open-loop -rate at which proto (percent) stays above -search_threshold")
+	fs.Float64Var(&c.Threshold, "search_threshold", 99.9, "minimum proto (percent) for a rate to pass")
+	fs.Float64Var(&c.MinRate, "search_min_rate", 100, "first rate probed by the search")
+	fs.Float64Var(&c.MaxRate, "search_max_rate", 0, "upper bound of the search, 0 means doubling the rate until the threshold is violated")
+	fs.Float64Var(&c.Precision, "search_precision", 0.05, "stop when the gap between the passing and failing rates is within this fraction")
+	fs.IntVar(&c.MaxProbes, "search_max_probes", 20, "maximum number of probes")
+}
+
+func (c SearchConfig) validate() error {
+	switch {
+	case c.Threshold <= 0 || c.Threshold > 100:
+		return errors.New("-search_threshold must be in (0, 100]")
+	case c.MinRate <= 0:
+		return errors.New("-search_min_rate must be positive")
+	case c.MaxRate > 0 && c.MaxRate < c.MinRate:
+		return errors.New("-search_max_rate must not be less than -search_min_rate")
+	case c.Precision <= 0 || c.Precision >= 1:
+		return errors.New("-search_precision must be in (0, 1)")
+	case c.MaxProbes <= 0:
+		return errors.New("-search_max_probes must be positive")
+	}
+	return nil
+}
+
+// saturatedRatio 实际 qps 低于速率的该比例时认为客户端已经无法提供更高的负载
+const saturatedRatio = 0.9
+
+// searchResolution 为二分查找的最小速率间隔 速率取整后间隔小于该值时无法再得到新的速率
+const searchResolution = 1
+
+// searchMinTotal 为未设置 -duration 时每次压测的最少操作数 操作数过少时 proto (percent) 没有意义
+const searchMinTotal = 1000
+
+// SearchProbe 为搜索过程中的单次压测
+type SearchProbe struct {
+	Rate   float64
+	Result *ScenarioResult
+	Passed bool
+
+	// Saturated 表示客户端无法达到该速率 通过时表示继续提高速率没有意义
+	// 未通过时表示调度器未能按时派发操作 即存在延迟执行或者被丢弃的操作
+	Saturated bool
+	Reason    string
+}
+
+func (c SearchConfig) evaluate(p *SearchProbe) {
+	res := p.Result
+	r := res.Result
+	switch {
+	case r == nil:
+		p.Reason = res.Err.Error()
+	case r.ProtoPercent() < c.Threshold:
+		p.Reason = fmt.Sprintf("proto (percent) %.3f%% < %.3f%%", r.ProtoPercent(), c.Threshold)
+	case res.Err != nil:
+		p.Reason = res.Err.Error()
+	case r.Late > 0 || r.Dropped > 0:
+		p.Saturated = true
+		p.Reason = fmt.Sprintf("client fell behind, late=%d dropped=%d", r.Late, r.Dropped)
+	case r.QPS() < p.Rate*saturatedRatio:
+		p.Passed = true
+		p.Saturated = true
+		p.Reason = fmt.Sprintf("client saturated at %.3f qps", r.QPS())
+	default:
+		p.Passed = true
+	}
+}
+
+// SearchResult 为搜索的结果 Knee 为满足阈值的最高速率 为空时表示最低速率也不满足
+type SearchResult struct {
+	Threshold float64
+	Probes    []*SearchProbe
+	Knee      *SearchProbe
+
+	// Bounded 表示已经找到不满足阈值的速率 否则 Knee 只是达到的最高速率
+	Bounded bool
+
+	// bound 为不满足阈值的最低速率对应的压测
+	bound *SearchProbe
+}
+
+// note 说明饱和点的可信程度
+func (sr *SearchResult) note() string {
+	switch {
+	case sr.Knee == nil:
+		return "minimum rate failed"
+	case sr.Knee.Saturated:
+		return "client saturated before packetd, knee is a lower bound"
+	case sr.bound != nil && sr.bound.Saturated:
+		return "client fell behind above the knee, knee may be limited by the client"
+	case !sr.Bounded:
+		return "threshold not violated, knee is a lower bound"
+	}
+	return ""
+}
+
+// runSearch 执行捕获饱和点的搜索并输出结果 未找到满足阈值的速率时返回错误
+func (cr *commandRunner) runSearch(c SearchConfig) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	fs, err := cr.flags([]string{"rate"}, []string{formatRate(c.MinRate)})
+	if err != nil {
+		return err
+	}
+	if err := validateSearchLength(fs); err != nil {
+		return err
+	}
+
+	sr := &SearchResult{Threshold: c.Threshold}
+	probed := make(map[float64]bool)
+	var hi float64
+	rate := c.MinRate
+	for i := 0; i < c.MaxProbes; i++ {
+		log.Printf("[%d/%d] search rate=%s\n", i+1, c.MaxProbes, formatRate(rate))
+		newRunner, err := cr.runner([]string{"rate"}, []string{formatRate(rate)})
+		if err != nil {
+			return err
+		}
+
+		p := &SearchProbe{Rate: rate, Result: runWorkload("rate="+formatRate(rate), newRunner)}
+		c.evaluate(p)
+		probed[rate] = true
+		sr.Probes = append(sr.Probes, p)
+		if p.Passed {
+			sr.Knee = p
+		} else {
+			hi = rate
+			sr.Bounded = true
+			sr.bound = p
+		}
+		if p.Reason != "" {
+			log.Printf("search rate=%s: %s\n", formatRate(rate), p.Reason)
+		}
+
+		if sr.Knee == nil || (p.Passed && p.Saturated) {
+			break
+		}
+		if hi == 0 {
+			if c.MaxRate > 0 && rate >= c.MaxRate {
+				break
+			}
+			rate *= 2
+			if c.MaxRate > 0 && rate > c.MaxRate {
+				rate = c.MaxRate
+			}
+			continue
+		}
+		lo := sr.Knee.Rate
+		if hi-lo <= searchResolution || (hi-lo)/hi <= c.Precision {
+			break
+		}
+		rate = math.Round((lo + hi) / 2)
+		if probed[rate] {
+			break
+		}
+	}
+
+	conf := sr.Probes[0].Result.Runner.conf
+	w, err := openOutput(conf.OutputFile, conf.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := WriteSearch(w, conf.Output, cr.cmd.Name+" search", sr); err != nil {
+		return err
+	}
+
+	if sr.Knee == nil {
+		return fmt.Errorf("minimum rate %s failed: %s", formatRate(c.MinRate), sr.Probes[0].Reason)
+	}
+	return nil
+}
+
+// validateSearchLength 要求每次压测持续足够长的时间 默认的 -total 下每次压测只有一个操作 无法反映速率
+func validateSearchLength(fs *flag.FlagSet) error {
+	value := func(name string) any {
+		if f := fs.Lookup(name); f != nil {
+			if g, ok := f.Value.(flag.Getter); ok {
+				return g.Get()
+			}
+		}
+		return nil
+	}
+	if d, ok := value("duration").(time.Duration); ok && d > 0 {
+		return nil
+	}
+	if total, ok := value("total").(int); ok && total >= searchMinTotal {
+		return nil
+	}
+	return fmt.Errorf("-search requires -duration or -total >= %d", searchMinTotal)
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// SearchRecord 为搜索的结构化输出
+type SearchRecord struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Timestamp     time.Time            `json:"timestamp"`
+	Name          string               `json:"name"`
+	Threshold     float64              `json:"threshold_percent"`
+	KneeRate      float64              `json:"knee_rate"`
+	Knee          *Record              `json:"knee,omitempty"`
+	Bounded       bool                 `json:"bounded"`
+	Note          string               `json:"note,omitempty"`
+	Probes        []*SearchProbeRecord `json:"probes"`
+}
+
+type SearchProbeRecord struct {
+	Rate      float64 `json:"rate"`
+	Passed    bool    `json:"passed"`
+	Saturated bool    `json:"saturated"`
+	Reason    string  `json:"reason,omitempty"`
+	Result    *Record `json:"result,omitempty"`
+}
+
+// probeRecord 返回单次压测的结构化输出 压测未产生结果时为空
+func probeRecord(p *SearchProbe) *Record {
+	res := p.Result
+	if res.Result == nil {
+		return nil
+	}
+	rec := res.Runner.Record(res.Result)
+	rec.Name = res.Name
+	return rec
+}
+
+// WriteSearch 按照 format 输出搜索结果 csv 格式每次压测输出一行
+func WriteSearch(w io.Writer, format, name string, sr *SearchResult) error {
+	rec := &SearchRecord{
+		SchemaVersion: SchemaVersion,
+		Timestamp:     time.Now(),
+		Name:          name,
+		Threshold:     sr.Threshold,
+		Bounded:       sr.Bounded,
+		Note:          sr.note(),
+	}
+	var recs []*Record
+	for _, p := range sr.Probes {
+		pr := probeRecord(p)
+		if pr != nil {
+			recs = append(recs, pr)
+		}
+		rec.Probes = append(rec.Probes, &SearchProbeRecord{
+			Rate:      p.Rate,
+			Passed:    p.Passed,
+			Saturated: p.Saturated,
+			Reason:    p.Reason,
+			Result:    pr,
+		})
+	}
+	if sr.Knee != nil {
+		rec.KneeRate = sr.Knee.Rate
+		rec.Knee = probeRecord(sr.Knee)
+	}
+
+	switch format {
+	case "", OutputTable:
+		PrintSearchTable(w, sr)
+		return nil
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rec)
+	case OutputJSONL:
+		return json.NewEncoder(w).Encode(rec)
+	case OutputCSV:
+		return writeCSV(w, recs)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// PrintSearchTable 输出每次压测的结果以及饱和点
+func PrintSearchTable(w io.Writer, sr *SearchResult) {
+	metrics := func(p *SearchProbe) []interface{} {
+		r := p.Result.Result
+		if r == nil {
+			return []interface{}{"-", "-", "-", "-"}
+		}
+		return []interface{}{
+			fmt.Sprintf("%.3f", r.QPS()),
+			fmt.Sprintf("%.3f%%", r.ProtoPercent()),
+			fmt.Sprintf("%.3f", r.Resource.CPU),
+			fmt.Sprintf("%.3f", r.Resource.Mem/1024/1024),
+		}
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader([]interface{}{"probe", "rate", "qps", "proto (percent)", "cpu (core)", "memory (MB)", "result"})
+	for i, p := range sr.Probes {
+		result := "pass"
+		if !p.Passed {
+			result = "fail"
+		}
+		if p.Reason != "" {
+			result += ": " + p.Reason
+		}
+		row := []interface{}{i + 1, formatRate(p.Rate)}
+		t.AppendRow(append(append(row, metrics(p)...), result))
+	}
+	t.AppendSeparator()
+	t.Render()
+
+	kt := table.NewWriter()
+	kt.SetOutputMirror(w)
+	kt.AppendHeader([]interface{}{"knee (rate)", "qps", "proto (percent)", "cpu (core)", "memory (MB)", "threshold", "note"})
+	threshold := fmt.Sprintf("%.3f%%", sr.Threshold)
+	if sr.Knee == nil {
+		kt.AppendRow([]interface{}{"-", "-", "-", "-", "-", threshold, sr.note()})
+	} else {
+		row := []interface{}{formatRate(sr.Knee.Rate)}
+		kt.AppendRow(append(append(row, metrics(sr.Knee)...), threshold, sr.note()))
+	}
+	kt.AppendSeparator()
+	kt.Render()
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	SizeFixed    = "fixed"
+	SizeUniform  = "uniform"
+	SizeNormal   = "normal"
+	SizeExp      = "exp"
+	SizeWeighted = "weighted"
+)
+
+// expTail 为指数分布的截断位置 以均值的倍数表示 超出的概率约为 e^-20
+const expTail = 20
+
+// SizeDistribution 描述了 body 大小的分布
+//
+// 格式如下 大小的单位同 ParseBytes 参数之间不使用逗号 以便作为 -sweep 的取值
+//
+//	1KB                      固定为 1KB
+//	uniform:1KB->64KB        在 [1KB, 64KB] 之间均匀分布
+//	normal:16KB/4KB          均值为 16KB 标准差为 4KB 的正态分布 截断在 [0, 均值+6倍标准差]
+//	exp:8KB                  均值为 8KB 的指数分布 截断在 20 倍均值
+//	weighted:1KB*90/1MB*10   按照权重在若干个固定大小中选择
+type SizeDistribution struct {
+	Kind string
+
+	// Min 以及 Max 为可能采样到的最小以及最大值
+	Min int
+	Max int
+
+	mean    float64
+	stddev  float64
+	sizes   []int
+	weights []float64 // 累计权重
+	spec    string
+}
+
+func (d *SizeDistribution) String() string {
+	return d.spec
+}
+
+// Mean 返回分布的均值 截断的影响忽略不计
+func (d *SizeDistribution) Mean() int {
+	return int(d.mean)
+}
+
+// Sample 返回第 idx 次操作的大小 结果只取决于 idx 以及 stream
+// stream 用于区分同一次操作中相互独立的多个采样 如请求以及响应的大小
+func (d *SizeDistribution) Sample(idx int, stream uint64) int {
+	if d.Kind == SizeFixed {
+		return d.Min
+	}
+
+	r := rand.New(rand.NewPCG(uint64(idx), stream))
+	var v float64
+	switch d.Kind {
+	case SizeUniform:
+		return d.Min + r.IntN(d.Max-d.Min+1)
+	case SizeNormal:
+		v = d.mean + r.NormFloat64()*d.stddev
+	case SizeExp:
+		v = r.ExpFloat64() * d.mean
+	case SizeWeighted:
+		w := r.Float64() * d.weights[len(d.weights)-1]
+		for i, cum := range d.weights {
+			if w < cum {
+				return d.sizes[i]
+			}
+		}
+		return d.sizes[len(d.sizes)-1]
+	}
+	return min(max(int(math.Round(v)), d.Min), d.Max)
+}
+
+func ParseSizeDistribution(s string) (*SizeDistribution, error) {
+	d, err := parseSizeDistribution(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid size distribution %q: %w", s, err)
+	}
+	d.spec = s
+	return d, nil
+}
+
+func parseSizeDistribution(s string) (*SizeDistribution, error) {
+	parse := func(v string) (int, error) {
+		n, err := ParseBytes(strings.TrimSpace(v))
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return 0, fmt.Errorf("size must not be negative")
+		}
+		return n, nil
+	}
+
+	kind, args, ok := strings.Cut(s, ":")
+	if !ok {
+		n, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return &SizeDistribution{Kind: SizeFixed, Min: n, Max: n, mean: float64(n)}, nil
+	}
+
+	d := &SizeDistribution{Kind: kind}
+	switch kind {
+	case SizeUniform:
+		lhs, rhs, ok := strings.Cut(args, "->")
+		if !ok {
+			return nil, fmt.Errorf("want uniform:<min>-><max>")
+		}
+		lo, err := parse(lhs)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parse(rhs)
+		if err != nil {
+			return nil, err
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("min must not exceed max")
+		}
+		d.Min, d.Max, d.mean = lo, hi, float64(lo+hi)/2
+
+	case SizeNormal:
+		lhs, rhs, ok := strings.Cut(args, "/")
+		if !ok {
+			return nil, fmt.Errorf("want normal:<mean>/<stddev>")
+		}
+		mean, err := parse(lhs)
+		if err != nil {
+			return nil, err
+		}
+		stddev, err := parse(rhs)
+		if err != nil {
+			return nil, err
+		}
+		d.mean, d.stddev = float64(mean), float64(stddev)
+		d.Max = mean + 6*stddev
+
+	case SizeExp:
+		mean, err := parse(args)
+		if err != nil {
+			return nil, err
+		}
+		d.mean = float64(mean)
+		d.Max = expTail * mean
+
+	case SizeWeighted:
+		var total, sum float64
+		d.Min = math.MaxInt
+		for _, item := range strings.Split(args, "/") {
+			size, weight, ok := strings.Cut(item, "*")
+			if !ok {
+				return nil, fmt.Errorf("want weighted:<size>*<weight>/...")
+			}
+			n, err := parse(size)
+			if err != nil {
+				return nil, err
+			}
+			w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+			if err != nil {
+				return nil, err
+			}
+			if w <= 0 {
+				return nil, fmt.Errorf("weight must be positive")
+			}
+			total += w
+			sum += w * float64(n)
+			d.sizes = append(d.sizes, n)
+			d.weights = append(d.weights, total)
+			d.Min, d.Max = min(d.Min, n), max(d.Max, n)
+		}
+		d.mean = sum / total
+
+	default:
+		return nil, fmt.Errorf("unknown distribution kind %q", kind)
+	}
+	return d, nil
+}
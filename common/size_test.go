@@ -0,0 +1,145 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSizeDistribution(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  string
+		min   int
+		max   int
+		mean  int
+	}{
+		// 不带分布类型的取值兼容旧版本的 -body_size 参数
+		{input: "1KB", kind: SizeFixed, min: 1024, max: 1024, mean: 1024},
+		{input: "0B", kind: SizeFixed, min: 0, max: 0, mean: 0},
+		{input: " 64KB ", kind: SizeFixed, min: 64 * 1024, max: 64 * 1024, mean: 64 * 1024},
+		{input: "uniform:1KB->3KB", kind: SizeUniform, min: 1024, max: 3 * 1024, mean: 2 * 1024},
+		{input: "uniform:1KB->1KB", kind: SizeUniform, min: 1024, max: 1024, mean: 1024},
+		{input: "normal:16KB/4KB", kind: SizeNormal, min: 0, max: 40 * 1024, mean: 16 * 1024},
+		{input: "exp:8KB", kind: SizeExp, min: 0, max: 160 * 1024, mean: 8 * 1024},
+		{input: "weighted:1KB*90/1MB*10", kind: SizeWeighted, min: 1024, max: 1 << 20, mean: (1024*90 + (1<<20)*10) / 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := ParseSizeDistribution(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Kind != tt.kind || d.Min != tt.min || d.Max != tt.max || d.Mean() != tt.mean {
+				t.Errorf("want %s [%d, %d] mean %d, got %s [%d, %d] mean %d", tt.kind, tt.min, tt.max, tt.mean, d.Kind, d.Min, d.Max, d.Mean())
+			}
+			if d.String() != tt.input {
+				t.Errorf("String: want %q, got %q", tt.input, d.String())
+			}
+		})
+	}
+}
+
+func TestParseSizeDistributionInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"1XB",
+		"-1KB",
+		"bogus:1KB",
+		"uniform:1KB",
+		"uniform:4KB->1KB",
+		"normal:16KB",
+		"exp:",
+		"weighted:1KB",
+		"weighted:1KB*0",
+		"weighted:1KB*-1/2KB*1",
+		"weighted:1KB*x",
+	} {
+		if _, err := ParseSizeDistribution(s); err == nil {
+			t.Errorf("ParseSizeDistribution(%q): want error", s)
+		}
+	}
+}
+
+func TestSizeDistributionSample(t *testing.T) {
+	const n = 20000
+	for _, spec := range []string{
+		"1KB",
+		"uniform:1KB->64KB",
+		"normal:16KB/4KB",
+		"exp:8KB",
+		"weighted:1KB*90/1MB*10",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			d, err := ParseSizeDistribution(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sum float64
+			for i := 0; i < n; i++ {
+				v := d.Sample(i, 0)
+				if v < d.Min || v > d.Max {
+					t.Fatalf("sample %d: %d out of [%d, %d]", i, v, d.Min, d.Max)
+				}
+				if d.Sample(i, 0) != v {
+					t.Fatalf("sample %d: not deterministic", i)
+				}
+				sum += float64(v)
+			}
+
+			// 样本均值与分布均值的偏差在 5% 以内
+			if mean := sum / n; math.Abs(mean-float64(d.Mean())) > 0.05*float64(d.Mean()) {
+				t.Errorf("want mean close to %d, got %.0f", d.Mean(), mean)
+			}
+		})
+	}
+}
+
+func TestSizeDistributionStream(t *testing.T) {
+	d, err := ParseSizeDistribution("uniform:0B->1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var same int
+	for i := 0; i < 100; i++ {
+		if d.Sample(i, 0) == d.Sample(i, 1) {
+			same++
+		}
+	}
+	if same > 1 {
+		t.Errorf("want independent streams, got %d equal samples", same)
+	}
+}
+
+func TestSizeDistributionWeighted(t *testing.T) {
+	d, err := ParseSizeDistribution("weighted:1KB*3/2KB*1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20000
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		counts[d.Sample(i, 0)]++
+	}
+	if len(counts) != 2 {
+		t.Fatalf("want only the weighted sizes, got %v", counts)
+	}
+	if ratio := float64(counts[1024]) / n; math.Abs(ratio-0.75) > 0.02 {
+		t.Errorf("want 75%% of 1KB, got %.3f", ratio)
+	}
+}
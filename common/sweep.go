@@ -0,0 +1,216 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// SweepParam 为参数扫描中的单个维度 Name 为子命令的参数名称
+type SweepParam struct {
+	Name   string
+	Values []string
+}
+
+// Sweep 为参数扫描的所有维度 实现了 flag.Value
+//
+// 格式为 `name=v1,v2,...` 多个维度之间以空格分隔 也可以多次指定 -sweep
+type Sweep []SweepParam
+
+func (s *Sweep) String() string {
+	if s == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(*s))
+	for _, p := range *s {
+		parts = append(parts, p.Name+"="+strings.Join(p.Values, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *Sweep) Set(v string) error {
+	for _, field := range strings.Fields(v) {
+		name, values, ok := strings.Cut(field, "=")
+		if !ok || name == "" || values == "" {
+			return fmt.Errorf("invalid sweep %q, expected name=v1,v2", field)
+		}
+		name = strings.TrimPrefix(name, "-")
+		for _, p := range *s {
+			if p.Name == name {
+				return fmt.Errorf("duplicate sweep parameter %q", name)
+			}
+		}
+
+		param := SweepParam{Name: name}
+		for _, value := range strings.Split(values, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return fmt.Errorf("invalid sweep %q, empty value", field)
+			}
+			param.Values = append(param.Values, value)
+		}
+		*s = append(*s, param)
+	}
+	return nil
+}
+
+// Cells 返回所有维度取值的笛卡尔积 靠前的维度变化最慢
+func (s Sweep) Cells() [][]string {
+	cells := [][]string{nil}
+	for _, p := range s {
+		next := make([][]string, 0, len(cells)*len(p.Values))
+		for _, cell := range cells {
+			for _, v := range p.Values {
+				next = append(next, append(append([]string(nil), cell...), v))
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+func (s Sweep) names() []string {
+	names := make([]string, 0, len(s))
+	for _, p := range s {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// cellName 返回单元格的名称 如 `workers=8 body_size=1KB`
+func (s Sweep) cellName(cell []string) string {
+	parts := make([]string, 0, len(cell))
+	for i, v := range cell {
+		parts = append(parts, s[i].Name+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// runSweep 依次执行所有单元格 表格格式输出矩阵 其余格式与 scenario 子命令一致
+//
+// 单个单元格失败时继续执行后续的单元格 所有单元格结束后返回错误
+func (cr *commandRunner) runSweep(sweep Sweep) error {
+	cells := sweep.Cells()
+	for _, cell := range cells {
+		if _, err := cr.runner(sweep.names(), cell); err != nil {
+			return err
+		}
+	}
+
+	results := make([]*ScenarioResult, 0, len(cells))
+	for i, cell := range cells {
+		name := sweep.cellName(cell)
+		log.Printf("[%d/%d] sweep %s\n", i+1, len(cells), name)
+
+		newRunner, err := cr.runner(sweep.names(), cell)
+		if err != nil {
+			return err
+		}
+		res := runWorkload(name, newRunner)
+		if res.Err != nil {
+			log.Printf("sweep %s failed: %v\n", name, res.Err)
+		}
+		results = append(results, res)
+	}
+
+	conf := results[0].Runner.conf
+	w, err := openOutput(conf.OutputFile, conf.Output)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	switch conf.Output {
+	case "", OutputTable:
+		PrintSweepTable(w, sweep, results)
+	default:
+		if err := WriteScenario(w, conf.Output, cr.cmd.Name+" sweep", results); err != nil {
+			return err
+		}
+	}
+
+	if _, failed := scenarioRecords(results); len(failed) > 0 {
+		return fmt.Errorf("%d sweep cell(s) failed: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// PrintSweepTable 以矩阵形式输出参数扫描的结果
+//
+// 最后一个维度作为列 其余维度作为行 每个单元格包含 qps proto (percent) 以及 cpu (core)
+func PrintSweepTable(w io.Writer, sweep Sweep, results []*ScenarioResult) {
+	metrics := []string{"qps", "proto (percent)", "cpu (core)"}
+	cell := func(res *ScenarioResult) []interface{} {
+		r := res.Result
+		if r == nil {
+			return []interface{}{"-", "-", "-"}
+		}
+		return []interface{}{
+			fmt.Sprintf("%.3f", r.QPS()),
+			fmt.Sprintf("%.3f%%", r.ProtoPercent()),
+			fmt.Sprintf("%.3f", r.Resource.CPU),
+		}
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+
+	if len(sweep) == 1 {
+		header := []interface{}{sweep[0].Name}
+		for _, m := range metrics {
+			header = append(header, m)
+		}
+		t.AppendHeader(header)
+		for i, v := range sweep[0].Values {
+			t.AppendRow(append([]interface{}{v}, cell(results[i])...))
+		}
+		t.AppendSeparator()
+		t.Render()
+		return
+	}
+
+	rows, cols := sweep[:len(sweep)-1], sweep[len(sweep)-1]
+	top := make([]interface{}, 0, len(rows)+len(cols.Values)*len(metrics))
+	sub := make([]interface{}, 0, cap(top))
+	for _, p := range rows {
+		top = append(top, p.Name)
+		sub = append(sub, "")
+	}
+	for _, v := range cols.Values {
+		for _, m := range metrics {
+			top = append(top, cols.Name+"="+v)
+			sub = append(sub, m)
+		}
+	}
+	t.AppendHeader(top, table.RowConfig{AutoMerge: true})
+	t.AppendHeader(sub)
+
+	for i, values := range rows.Cells() {
+		row := make([]interface{}, 0, len(top))
+		for _, v := range values {
+			row = append(row, v)
+		}
+		for j := range cols.Values {
+			row = append(row, cell(results[i*len(cols.Values)+j])...)
+		}
+		t.AppendRow(row)
+	}
+	t.AppendSeparator()
+	t.Render()
+}
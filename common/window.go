@@ -0,0 +1,166 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// window 为参与统计的时间窗口
+//
+// 只有开始时间落在 [start, end) 之内的操作才会计入该窗口的 qps 以及延迟统计
+// packetd 的协议指标以及进程资源在窗口边界处采集
+type window struct {
+	start time.Time
+	end   time.Time
+
+	// completed 包含失败的操作 latency 仅统计成功的操作
+	completed atomic.Int64
+	latency   *Histogram
+	errors    *errorCounter
+
+	// requestBytes 以及 responseBytes 为成功的操作传输的 body 字节数 仅在 Workload 实现了 Transfer 时统计
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+
+	// late 以及 dropped 为计划发送时间落在窗口内的延迟执行以及被丢弃的操作数 仅开环模式下统计
+	late    atomic.Int64
+	dropped atomic.Int64
+
+	// opened 表示窗口是否已经开始 提前中止压测时后续的窗口不会开始
+	opened     bool
+	rr         *ResourceRecorder
+	resource   Resource
+	protoStart Samples
+	protoEnd   Samples
+}
+
+func newWindow(start, end time.Time, src ResourceSource) *window {
+	return &window{
+		start:   start,
+		end:     end,
+		latency: NewHistogram(),
+		errors:  newErrorCounter(),
+		rr:      NewResourceRecorder(src),
+	}
+}
+
+func (w *window) contains(t time.Time) bool {
+	return !t.Before(w.start) && t.Before(w.end)
+}
+
+func (w *window) record(d time.Duration) {
+	w.completed.Add(1)
+	w.latency.Record(d)
+}
+
+func (w *window) recordTransfer(request, response int) {
+	w.requestBytes.Add(int64(request))
+	w.responseBytes.Add(int64(response))
+}
+
+func (w *window) recordError(class string) {
+	w.completed.Add(1)
+	w.errors.add(class)
+}
+
+// open 在窗口开始时采集指标 进程资源是辅助指标 采集失败时仅记录日志 协议指标采集失败时返回错误
+func (w *window) open() error {
+	w.opened = true
+	if err := w.rr.Start(); err != nil {
+		log.Println(err)
+	}
+	metrics, err := RequestProtocolMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot protocol metrics: %w", err)
+	}
+	w.protoStart = metrics
+	return nil
+}
+
+// close 在窗口结束时采集指标 与 open 相同 仅协议指标采集失败时返回错误
+//
+// 缺少结束时的协议指标时无法计算窗口内的增量 不能按照 0 计入结果
+func (w *window) close() error {
+	resource, err := w.rr.End()
+	if err != nil {
+		log.Println(err)
+	}
+	w.resource = resource
+
+	metrics, err := RequestProtocolMetrics()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot protocol metrics: %w", err)
+	}
+	w.protoEnd = metrics
+	return nil
+}
+
+// protoDelta 返回窗口内计数器 name 的增量以及重置次数
+func (w *window) protoDelta(name string) (float64, int) {
+	return Increase(w.protoStart, w.protoEnd, name, nil)
+}
+
+// watchWindows 在每个窗口的边界处采集指标 所有窗口关闭后向返回的 channel 发送窗口开始或者结束时的错误
+//
+// abort 被关闭时立即关闭当前窗口并将其结束时间修改为当前时间 后续的窗口不再开始
+// 调用方需要保证 abort 被关闭时不再有操作读取窗口的边界
+// 窗口开始或者结束失败时调用 stop 结束派发 与闭环模式下的行为一致
+func watchWindows(windows []*window, abort <-chan struct{}, stop func()) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() {
+			done <- err
+			close(done)
+		}()
+		for _, w := range windows {
+			if !sleepUntil(w.start, abort) {
+				return
+			}
+			if err = w.open(); err != nil {
+				stop()
+				return
+			}
+			aborted := !sleepUntil(w.end, abort)
+			if aborted {
+				w.end = time.Now()
+			}
+			if err = w.close(); err != nil {
+				stop()
+				return
+			}
+			if aborted {
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// sleepUntil 等待至 t 时刻 abort 被关闭时返回 false
+func sleepUntil(t time.Time, abort <-chan struct{}) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-abort:
+		return false
+	}
+}
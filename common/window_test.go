@@ -0,0 +1,53 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchWindowsCloseError(t *testing.T) {
+	// 窗口开始时的协议指标采集成功 结束时失败
+	var polls atomic.Int64
+	fakePacketd(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == Packetd().ProtocolPath && polls.Add(1) > 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "http_requests_total 1")
+	})
+
+	start := time.Now()
+	windows := []*window{
+		newWindow(start, start.Add(20*time.Millisecond), packetdSource{}),
+		newWindow(start.Add(20*time.Millisecond), start.Add(40*time.Millisecond), packetdSource{}),
+	}
+	var stopped atomic.Bool
+	err := <-watchWindows(windows, make(chan struct{}), func() { stopped.Store(true) })
+
+	if err == nil || !strings.Contains(err.Error(), "failed to snapshot protocol metrics") {
+		t.Fatalf("want protocol snapshot error, got %v", err)
+	}
+	if !stopped.Load() {
+		t.Error("want dispatch stopped")
+	}
+	if windows[1].opened {
+		t.Error("want later windows not opened")
+	}
+}
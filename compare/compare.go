@@ -0,0 +1,83 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	Tolerance string
+}
+
+// NewCommand 返回 `compare` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:      "compare",
+		Usage:     "compare saved results against a baseline and fail on regression",
+		ArgsUsage: "<base> <current>",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Tolerance, "tolerance", common.DefaultTolerances, "regression tolerances, e.g. 'cpu=+10%,capture=-0.1%'")
+		},
+		Run: func(args []string) error {
+			if len(args) != 2 {
+				return errors.New("compare requires <base> and <current> result files")
+			}
+			return Run(c, args[0], args[1])
+		},
+	}
+}
+
+// Run 对比 base 以及 current 中的压测结果 存在性能回退时返回错误
+func Run(c Config, basePath, currentPath string) error {
+	tolerances, err := common.ParseTolerances(c.Tolerance)
+	if err != nil {
+		return err
+	}
+	base, err := common.LoadRecords(basePath)
+	if err != nil {
+		return err
+	}
+	current, err := common.LoadRecords(currentPath)
+	if err != nil {
+		return err
+	}
+
+	deltas, unmatched := common.Compare(base, current, tolerances)
+	for _, key := range unmatched {
+		log.Printf("no baseline found for run (%s)\n", key)
+	}
+	if len(deltas) == 0 {
+		return errors.New("no comparable runs found")
+	}
+	common.PrintCompareTable(os.Stdout, deltas)
+
+	var regressions int
+	for _, d := range deltas {
+		if d.Regressed {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d regression(s) detected", regressions)
+	}
+	return nil
+}
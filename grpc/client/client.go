@@ -0,0 +1,131 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/packetd/packetd-benchmark/common"
+	"github.com/packetd/packetd-benchmark/grpc/pb"
+)
+
+type Config struct {
+	Addr     string
+	Workers  int
+	Total    int
+	BodySize string
+	Interval time.Duration
+}
+
+func (c Config) GetBodySize() int {
+	i, err := common.ParseBytes(c.BodySize)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+type Client struct {
+	conf Config
+	conn *grpc.ClientConn
+	cli  pb.BenchmarkClient
+}
+
+func New(conf Config) *Client {
+	return &Client{
+		conf: conf,
+	}
+}
+
+func (c *Client) Setup() error {
+	conn, err := grpc.NewClient(c.conf.Addr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.cli = pb.NewBenchmarkClient(conn)
+	return nil
+}
+
+func (c *Client) Do(int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := c.cli.Size(ctx, &pb.SizeRequest{Size: int64(c.conf.GetBodySize())})
+	if err != nil {
+		return fmt.Errorf("size request error: %w", wrapError(err))
+	}
+	return nil
+}
+
+// wrapError 将服务端返回的非 OK 状态视为非预期的状态 超时以及连接错误保持原样
+func wrapError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch st.Code() {
+	case codes.DeadlineExceeded, codes.Canceled, codes.Unavailable:
+		return err
+	case codes.Internal:
+		return common.NewProtocolError(err)
+	}
+	return common.NewStatusError("%s", st.Code())
+}
+
+func (c *Client) Teardown() error {
+	return c.conn.Close()
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "bodySize", Value: c.conf.BodySize},
+	}
+}
+
+// NewCommand 返回 `grpc client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "grpc client",
+		Usage: "benchmark the grpc server with Size calls",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.BodySize, "body_size", "1KB", "request body size")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request")
+			fs.StringVar(&c.Addr, "addr", "localhost:8085", "grpc server address")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "grpc"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.Interval = c.Interval
+			rc.BodySize = c.GetBodySize()
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
@@ -11,18 +11,20 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package server
 
 import (
 	"bytes"
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"time"
 
 	"google.golang.org/grpc"
 
+	"github.com/packetd/packetd-benchmark/common"
 	"github.com/packetd/packetd-benchmark/grpc/pb"
 )
 
@@ -38,13 +40,25 @@ func (s *server) Size(_ context.Context, in *pb.SizeRequest) (*pb.SizeReply, err
 	return &pb.SizeReply{Message: bytes.Repeat([]byte{'x'}, int(in.GetSize()))}, nil
 }
 
-func main() {
-	addr := flag.String("addr", ":8085", "grpc server address")
-	flag.Parse()
+// NewCommand 返回 `grpc server` 子命令
+func NewCommand() *common.Command {
+	var addr string
+	return &common.Command{
+		Name:  "grpc server",
+		Usage: "serve the Benchmark grpc service",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&addr, "addr", ":8085", "grpc server address")
+		},
+		Run: func([]string) error {
+			return Serve(addr)
+		},
+	}
+}
 
-	lis, err := net.Listen("tcp", *addr)
+func Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	s := grpc.NewServer()
@@ -52,6 +66,7 @@ func main() {
 
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+		return fmt.Errorf("failed to serve: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,212 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+// 采样请求以及响应大小时使用的 stream 保证两者相互独立
+const (
+	streamRequest  = 0
+	streamResponse = 1
+)
+
+type Config struct {
+	Addr            string
+	Workers         int
+	Total           int
+	Method          string
+	RequestBodySize string
+	ContentType     string
+	BodySize        string
+	Echo            bool
+	Status          string
+	Interval        time.Duration
+}
+
+// GetBodySize 返回响应 body 大小的均值 分布不合法时返回 0 错误由 Setup 返回
+func (c Config) GetBodySize() int {
+	d, err := common.ParseSizeDistribution(c.BodySize)
+	if err != nil {
+		return 0
+	}
+	return d.Mean()
+}
+
+type Client struct {
+	conf       Config
+	cli        *http.Client
+	statusList []string
+
+	requestSize  *common.SizeDistribution
+	responseSize *common.SizeDistribution
+	payload      []byte
+}
+
+func New(conf Config) *Client {
+	return &Client{
+		conf:       conf,
+		statusList: strings.Split(conf.Status, ","),
+	}
+}
+
+func (c *Client) Setup() error {
+	var err error
+	if c.requestSize, err = common.ParseSizeDistribution(c.conf.RequestBodySize); err != nil {
+		return err
+	}
+	if c.responseSize, err = common.ParseSizeDistribution(c.conf.BodySize); err != nil {
+		return err
+	}
+	c.payload = bytes.Repeat([]byte{'x'}, c.requestSize.Max)
+
+	c.cli = &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: 1000,
+			IdleConnTimeout:     time.Minute,
+		},
+	}
+	return nil
+}
+
+// Transferred 返回第 idx 次请求的请求以及响应 body 大小
+// HEAD 请求以及 1xx/204/304 状态码的响应没有 body
+func (c *Client) Transferred(idx int) (int, int) {
+	request := c.requestSize.Sample(idx, streamRequest)
+	status, _ := strconv.Atoi(c.statusList[idx%len(c.statusList)])
+	switch {
+	case !bodyAllowed(c.conf.Method, status):
+		return request, 0
+	case c.conf.Echo:
+		return request, request
+	}
+	return request, c.responseSize.Sample(idx, streamResponse)
+}
+
+// bodyAllowed 判断响应是否可以携带 body 与 http server 的行为保持一致
+func bodyAllowed(method string, status int) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	return !(status >= 100 && status <= 199) && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+func (c *Client) Do(idx int) error {
+	requestSize, responseSize := c.Transferred(idx)
+	u := fmt.Sprintf("http://%s/benchmark?duration=%v&size=%d&status=%v&echo=%t",
+		c.conf.Addr,
+		c.conf.Interval.String(),
+		responseSize,
+		c.statusList[idx%len(c.statusList)],
+		c.conf.Echo,
+	)
+
+	body := c.payload[:requestSize]
+	r, err := http.NewRequest(c.conf.Method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if requestSize > 0 {
+		r.Header.Set("Content-Type", c.conf.ContentType)
+	}
+	rsp, err := c.cli.Do(r)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	h := crc32.NewIEEE()
+	n, err := io.Copy(h, rsp.Body)
+	if err != nil {
+		return err
+	}
+
+	// 服务端按照请求参数返回对应的状态码 与之不一致时视为错误
+	if status := strconv.Itoa(rsp.StatusCode); status != c.statusList[idx%len(c.statusList)] {
+		return common.NewStatusError("%s", status)
+	}
+
+	// 服务端返回其收到的请求 body 的大小以及 CRC32 用于确认请求 body 完整送达
+	checksum := crc32.ChecksumIEEE(body)
+	if v := rsp.Header.Get("X-Body-Size"); v != strconv.Itoa(requestSize) {
+		return common.NewProtocolError(fmt.Errorf("request body size mismatch: sent %d, server received %s", requestSize, v))
+	}
+	if v := rsp.Header.Get("X-Body-Checksum"); v != fmt.Sprintf("%08x", checksum) {
+		return common.NewProtocolError(fmt.Errorf("request body checksum mismatch: sent %08x, server received %s", checksum, v))
+	}
+	if int(n) != responseSize {
+		return common.NewProtocolError(fmt.Errorf("response body size mismatch: want %d, got %d", responseSize, n))
+	}
+	if c.conf.Echo && responseSize > 0 && h.Sum32() != checksum {
+		return common.NewProtocolError(fmt.Errorf("echoed body checksum mismatch: want %08x, got %08x", checksum, h.Sum32()))
+	}
+	return nil
+}
+
+func (c *Client) Teardown() error {
+	c.cli.CloseIdleConnections()
+	return nil
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "method", Value: c.conf.Method},
+		{Name: "requestBodySize", Value: c.conf.RequestBodySize},
+		{Name: "bodySize", Value: c.conf.BodySize},
+		{Name: "echo", Value: strconv.FormatBool(c.conf.Echo)},
+		{Name: "status", Value: c.conf.Status},
+	}
+}
+
+// NewCommand 返回 `http client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "http client",
+		Usage: "benchmark the http server with /benchmark requests, optionally carrying request bodies",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:8083", "http server address")
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.Method, "method", http.MethodGet, "http request method")
+			fs.StringVar(&c.RequestBodySize, "request_body_size", "0B", "request body size distribution, e.g. '4KB', 'uniform:1KB->64KB', 'normal:16KB/4KB', 'exp:8KB', 'weighted:1KB*90/1MB*10'")
+			fs.StringVar(&c.ContentType, "content_type", "application/octet-stream", "request body content type")
+			fs.StringVar(&c.BodySize, "body_size", "1KB", "response body size distribution, same format as -request_body_size, ignored with -echo")
+			fs.BoolVar(&c.Echo, "echo", false, "ask the server to echo the request body as response body")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request")
+			fs.StringVar(&c.Status, "status", "200", "http response status")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "http"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.BodySize = c.GetBodySize()
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetBodySize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{input: "1KB", want: 1024},
+		{input: "uniform:1KB->3KB", want: 2048},
+		{input: "bogus:1KB", want: 0},
+	}
+	for _, tt := range tests {
+		if got := (Config{BodySize: tt.input}).GetBodySize(); got != tt.want {
+			t.Errorf("GetBodySize(%q): want %d, got %d", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSetupInvalidSize(t *testing.T) {
+	for _, conf := range []Config{
+		{RequestBodySize: "bogus:1KB", BodySize: "0B", Status: "200"},
+		{RequestBodySize: "0B", BodySize: "uniform:4KB->1KB", Status: "200"},
+	} {
+		if err := New(conf).Setup(); err == nil {
+			t.Errorf("Setup(request=%q, response=%q): want error", conf.RequestBodySize, conf.BodySize)
+		}
+	}
+}
+
+func TestTransferred(t *testing.T) {
+	base := Config{
+		Method:          http.MethodPost,
+		RequestBodySize: "uniform:1KB->4KB",
+		BodySize:        "1KB",
+		Status:          "200",
+	}
+
+	tests := []struct {
+		name     string
+		conf     func(c *Config)
+		response func(request int) int
+	}{
+		{
+			name:     "legacy fixed size",
+			conf:     func(c *Config) { c.RequestBodySize = "2KB" },
+			response: func(int) int { return 1024 },
+		},
+		{
+			name:     "distribution",
+			conf:     func(c *Config) {},
+			response: func(int) int { return 1024 },
+		},
+		{
+			name:     "echo",
+			conf:     func(c *Config) { c.Echo = true },
+			response: func(request int) int { return request },
+		},
+		{
+			name:     "head",
+			conf:     func(c *Config) { c.Method = http.MethodHead; c.Echo = true },
+			response: func(int) int { return 0 },
+		},
+		{
+			name:     "no content",
+			conf:     func(c *Config) { c.Status = "204,304" },
+			response: func(int) int { return 0 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := base
+			tt.conf(&conf)
+			c := New(conf)
+			if err := c.Setup(); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 100; i++ {
+				request, response := c.Transferred(i)
+				if request < c.requestSize.Min || request > c.requestSize.Max {
+					t.Fatalf("request %d: size %d out of [%d, %d]", i, request, c.requestSize.Min, c.requestSize.Max)
+				}
+				if want := tt.response(request); response != want {
+					t.Fatalf("request %d: want response size %d, got %d", i, want, response)
+				}
+				if r, w := c.Transferred(i); r != request || w != response {
+					t.Fatalf("request %d: not deterministic", i)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferredStatusList(t *testing.T) {
+	// 状态码按照请求序号轮询 仅 204 的请求没有响应 body
+	c := New(Config{Method: http.MethodGet, RequestBodySize: "0B", BodySize: "1KB", Status: "200,204,500"})
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{1024, 0, 1024, 1024, 0, 1024} {
+		if _, got := c.Transferred(i); got != want {
+			t.Errorf("request %d: want response size %d, got %d", i, want, got)
+		}
+	}
+}
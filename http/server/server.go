@@ -0,0 +1,108 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+// NewCommand 返回 `http server` 子命令
+func NewCommand() *common.Command {
+	var addr string
+	return &common.Command{
+		Name:  "http server",
+		Usage: "serve /benchmark with configurable delay, response size and status, consuming and checksumming request bodies",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&addr, "addr", "localhost:8083", "http server address")
+		},
+		Run: func([]string) error {
+			return Serve(addr)
+		},
+	}
+}
+
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/benchmark", func(w http.ResponseWriter, r *http.Request) {
+		// 参数仅从 URL 中读取 请求 body 完整读取并计算 CRC32 echo 时原样返回
+		query := r.URL.Query()
+		echo := query.Get("echo") == "true"
+
+		h := crc32.NewIEEE()
+		var buf bytes.Buffer
+		dst := io.Writer(h)
+		if echo {
+			dst = io.MultiWriter(h, &buf)
+		}
+		n, err := io.Copy(dst, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		duration, _ := time.ParseDuration(query.Get("duration"))
+		size := parseSize(query.Get("size"))
+		status, _ := strconv.Atoi(query.Get("status"))
+
+		log.Printf("request from %s, method=%s, body=%d, duration=%v, size=%v, status=%v, echo=%v\n", r.RemoteAddr, r.Method, n, duration, size, status, echo)
+		if duration > 0 {
+			time.Sleep(duration)
+		}
+
+		// 返回收到的请求 body 的大小以及 CRC32 供客户端校验
+		w.Header().Set("X-Body-Size", strconv.FormatInt(n, 10))
+		w.Header().Set("X-Body-Checksum", fmt.Sprintf("%08x", h.Sum32()))
+
+		body := buf.Bytes()
+		if !echo {
+			body = bytes.Repeat([]byte{'x'}, size)
+		}
+		if status >= 200 && status <= 599 {
+			w.WriteHeader(status)
+		}
+		if len(body) > 0 && bodyAllowed(r.Method, status) {
+			w.Write(body)
+		}
+	})
+
+	log.Printf("server listening on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// bodyAllowed 判断响应是否可以携带 body HEAD 请求以及 1xx/204/304 状态码的响应不写入 body
+func bodyAllowed(method string, status int) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	return !(status >= 100 && status <= 199) && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// parseSize 解析响应 body 的大小 兼容旧版本客户端发送的带单位的取值 如 1KB
+func parseSize(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	n, _ := common.ParseBytes(s)
+	return n
+}
@@ -0,0 +1,62 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{input: "1024", want: 1024},
+		{input: "0", want: 0},
+		{input: "", want: 0},
+		// 旧版本客户端发送带单位的取值
+		{input: "1KB", want: 1024},
+		{input: "64KB", want: 64 * 1024},
+		{input: "1MB", want: 1 << 20},
+		{input: "bogus", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseSize(tt.input); got != tt.want {
+			t.Errorf("parseSize(%q): want %d, got %d", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestBodyAllowed(t *testing.T) {
+	tests := []struct {
+		method string
+		status int
+		want   bool
+	}{
+		{method: http.MethodGet, status: 200, want: true},
+		{method: http.MethodPost, status: 500, want: true},
+		{method: http.MethodGet, status: 0, want: true},
+		{method: http.MethodHead, status: 200, want: false},
+		{method: http.MethodGet, status: 100, want: false},
+		{method: http.MethodGet, status: 199, want: false},
+		{method: http.MethodGet, status: 204, want: false},
+		{method: http.MethodGet, status: 304, want: false},
+	}
+	for _, tt := range tests {
+		if got := bodyAllowed(tt.method, tt.status); got != tt.want {
+			t.Errorf("bodyAllowed(%s, %d): want %v, got %v", tt.method, tt.status, tt.want, got)
+		}
+	}
+}
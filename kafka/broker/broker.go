@@ -0,0 +1,488 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+const (
+	// nodeID 为唯一节点的 id 同时也是 controller 以及所有 group 的 coordinator
+	nodeID    = 0
+	clusterID = "packetd-bench"
+
+	maxRequestSize = 100 * 1024 * 1024
+)
+
+type Config struct {
+	Addr string
+
+	// Partitions 为自动创建以及未指定分区数的 topic 的分区数
+	Partitions       int
+	AutoCreateTopics bool
+
+	// RetentionBytes 为每个分区保留的最大数据量 超过时丢弃最早的 record batch
+	RetentionBytes string
+}
+
+// Broker 为单节点的 kafka 替身 topic 以及 consumer group 的数据仅保存在内存中
+type Broker struct {
+	conf      Config
+	retention int
+
+	mu     sync.RWMutex
+	topics map[string]*topic
+	groups *coordinator
+
+	// appended 在每次写入后关闭并替换 用于唤醒等待数据的 Fetch
+	appendMu sync.Mutex
+	appended chan struct{}
+}
+
+func New(conf Config) (*Broker, error) {
+	retention, err := common.ParseBytes(conf.RetentionBytes)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Partitions <= 0 {
+		return nil, errors.New("partitions must be positive")
+	}
+	return &Broker{
+		conf:      conf,
+		retention: retention,
+		topics:    make(map[string]*topic),
+		groups:    newCoordinator(),
+		appended:  make(chan struct{}),
+	}, nil
+}
+
+// NewCommand 返回 `kafka broker` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:  "kafka broker",
+		Usage: "serve a single-node in-memory kafka broker for the producer and consumer benchmarks",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:9092", "kafka broker address")
+			fs.IntVar(&c.Partitions, "partitions", 1, "partitions of auto-created topics and topics created with default partitions")
+			fs.BoolVar(&c.AutoCreateTopics, "auto_create_topics", true, "create unknown topics on metadata requests")
+			fs.StringVar(&c.RetentionBytes, "retention_bytes", "1024MB", "max bytes retained per partition, oldest record batches are dropped beyond it")
+		},
+		Run: func([]string) error {
+			b, err := New(c)
+			if err != nil {
+				return err
+			}
+			return b.Serve()
+		},
+	}
+}
+
+func (b *Broker) Serve() error {
+	lis, err := net.Listen("tcp", b.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go b.handle(c)
+	}
+}
+
+// conn 为单个客户端连接 host 以及 port 为客户端连接的本地地址 作为 broker 的通告地址
+type conn struct {
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	host string
+	port int32
+}
+
+// request 为解析请求头后的请求 请求头为 v1 格式
+type request struct {
+	key           int16
+	version       int16
+	correlationID int32
+	clientID      string
+	body          *decoder
+}
+
+func (b *Broker) handle(nc net.Conn) {
+	defer nc.Close()
+
+	host, port, _ := net.SplitHostPort(nc.LocalAddr().String())
+	p, _ := strconv.Atoi(port)
+	c := &conn{
+		br:   bufio.NewReader(nc),
+		bw:   bufio.NewWriter(nc),
+		host: host,
+		port: int32(p),
+	}
+	for {
+		req, err := c.readRequest()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+
+		// 不支持的 ApiVersions 版本以 v0 格式回复 客户端据此降级 其余的请求直接断开连接
+		if !supported(req.key, req.version) {
+			if req.key != apiApiVersions {
+				log.Printf("unsupported api %d version %d from %s\n", req.key, req.version, nc.RemoteAddr())
+				return
+			}
+			req.version = 0
+			if err := c.writeResponse(req, b.apiVersions(req, errUnsupportedVersion)); err != nil {
+				return
+			}
+			continue
+		}
+
+		resp, err := b.dispatch(c, req)
+		if err != nil {
+			log.Printf("api %d version %d from %s failed: %v\n", req.key, req.version, nc.RemoteAddr(), err)
+			return
+		}
+		if resp == nil {
+			continue
+		}
+		if err := c.writeResponse(req, resp); err != nil {
+			return
+		}
+	}
+}
+
+func (c *conn) readRequest() (*request, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.br, size[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(size[:]))
+	if n < 8 || n > maxRequestSize {
+		return nil, fmt.Errorf("invalid request size %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.br, buf); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{b: buf}
+	req := &request{
+		key:           d.int16(),
+		version:       d.int16(),
+		correlationID: d.int32(),
+		clientID:      d.string(),
+		body:          d,
+	}
+	return req, d.err
+}
+
+func (c *conn) writeResponse(req *request, resp *encoder) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:], uint32(len(resp.b)+4))
+	binary.BigEndian.PutUint32(header[4:], uint32(req.correlationID))
+	if _, err := c.bw.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(resp.b); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// dispatch 处理请求并返回响应 返回 nil 时不回复 如 acks=0 的 Produce
+func (b *Broker) dispatch(c *conn, req *request) (*encoder, error) {
+	var resp *encoder
+	switch req.key {
+	case apiApiVersions:
+		resp = b.apiVersions(req, errNone)
+	case apiMetadata:
+		resp = b.metadata(c, req)
+	case apiCreateTopics:
+		resp = b.createTopics(req)
+	case apiDeleteTopics:
+		resp = b.deleteTopics(req)
+	case apiProduce:
+		resp = b.produce(req)
+	case apiFetch:
+		resp = b.fetch(req)
+	case apiListOffsets:
+		resp = b.listOffsets(req)
+	case apiFindCoordinator:
+		resp = b.findCoordinator(c, req)
+	case apiJoinGroup:
+		resp = b.joinGroup(req)
+	case apiSyncGroup:
+		resp = b.syncGroup(req)
+	case apiHeartbeat:
+		resp = b.heartbeat(req)
+	case apiLeaveGroup:
+		resp = b.leaveGroup(req)
+	case apiOffsetCommit:
+		resp = b.offsetCommit(req)
+	case apiOffsetFetch:
+		resp = b.offsetFetch(req)
+	}
+	return resp, req.body.err
+}
+
+func (b *Broker) apiVersions(req *request, code int16) *encoder {
+	e := &encoder{}
+	e.int16(code)
+	e.arrayLen(len(apiVersions))
+	for _, v := range apiVersions {
+		e.int16(v.key)
+		e.int16(v.min)
+		e.int16(v.max)
+	}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	return e
+}
+
+// metadata 返回唯一节点以及 topic 的分区信息 所有分区的 leader 均为该节点
+func (b *Broker) metadata(c *conn, req *request) *encoder {
+	d := req.body
+	n := d.arrayLen()
+	var names []string
+	for i := 0; i < n && d.err == nil; i++ {
+		names = append(names, d.string())
+	}
+	autoCreate := b.conf.AutoCreateTopics
+	if req.version >= 4 {
+		autoCreate = autoCreate && d.bool()
+	}
+	if req.version >= 8 {
+		d.bool()
+		d.bool()
+	}
+
+	// v0 的空数组以及 v1+ 的 null 表示所有 topic
+	all := n < 0 || (req.version == 0 && n == 0)
+	var topics []*topic
+	b.mu.Lock()
+	if all {
+		for _, t := range b.topics {
+			topics = append(topics, t)
+		}
+	} else {
+		for _, name := range names {
+			t, ok := b.topics[name]
+			if !ok && autoCreate && validTopic(name) {
+				t = b.createTopic(name, b.conf.Partitions)
+				log.Printf("auto create topic %s with %d partitions\n", name, b.conf.Partitions)
+			}
+			if !ok && t == nil {
+				t = &topic{name: name}
+			}
+			topics = append(topics, t)
+		}
+	}
+	b.mu.Unlock()
+
+	e := &encoder{}
+	if req.version >= 3 {
+		e.int32(0)
+	}
+	e.arrayLen(1)
+	e.int32(nodeID)
+	e.string(c.host)
+	e.int32(c.port)
+	if req.version >= 1 {
+		e.nullableString(nil)
+	}
+	if req.version >= 2 {
+		id := clusterID
+		e.nullableString(&id)
+	}
+	if req.version >= 1 {
+		e.int32(nodeID)
+	}
+
+	e.arrayLen(len(topics))
+	for _, t := range topics {
+		switch {
+		case t.partitions == nil && !validTopic(t.name):
+			e.int16(errInvalidTopic)
+		case t.partitions == nil:
+			e.int16(errUnknownTopicOrPartition)
+		default:
+			e.int16(errNone)
+		}
+		e.string(t.name)
+		if req.version >= 1 {
+			e.bool(false)
+		}
+		e.arrayLen(len(t.partitions))
+		for i := range t.partitions {
+			e.int16(errNone)
+			e.int32(int32(i))
+			e.int32(nodeID)
+			if req.version >= 7 {
+				e.int32(0)
+			}
+			e.int32s([]int32{nodeID})
+			e.int32s([]int32{nodeID})
+			if req.version >= 5 {
+				e.int32s(nil)
+			}
+		}
+		if req.version >= 8 {
+			e.int32(-2147483648)
+		}
+	}
+	if req.version >= 8 {
+		e.int32(-2147483648)
+	}
+	return e
+}
+
+// validTopic 判断 topic 名称是否合法 仅允许字母 数字以及 `.` `_` `-`
+func validTopic(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 249 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Broker) createTopics(req *request) *encoder {
+	type topicSpec struct {
+		name       string
+		partitions int32
+		replicas   int16
+		assigned   int
+	}
+	type result struct {
+		name string
+		code int16
+		msg  string
+	}
+
+	d := req.body
+	n := d.arrayLen()
+	specs := make([]topicSpec, 0, max(n, 0))
+	for i := 0; i < n && d.err == nil; i++ {
+		spec := topicSpec{name: d.string(), partitions: d.int32(), replicas: d.int16()}
+		spec.assigned = d.arrayLen()
+		for j := 0; j < spec.assigned && d.err == nil; j++ {
+			d.int32()
+			d.int32s()
+		}
+		for j, configs := 0, d.arrayLen(); j < configs && d.err == nil; j++ {
+			d.string()
+			d.string()
+		}
+		specs = append(specs, spec)
+	}
+	d.int32()
+	validateOnly := req.version >= 1 && d.bool()
+
+	var results []result
+	b.mu.Lock()
+	for _, spec := range specs {
+		r := result{name: spec.name}
+		partitions := int(spec.partitions)
+		if spec.assigned > 0 {
+			partitions = spec.assigned
+		} else if partitions == -1 {
+			partitions = b.conf.Partitions
+		}
+		switch {
+		case !validTopic(spec.name):
+			r.code, r.msg = errInvalidTopic, fmt.Sprintf("Topic name %q is illegal", spec.name)
+		case b.topics[spec.name] != nil:
+			r.code, r.msg = errTopicAlreadyExists, fmt.Sprintf("Topic '%s' already exists.", spec.name)
+		case partitions <= 0:
+			r.code, r.msg = errInvalidPartitions, "Number of partitions must be larger than 0."
+		case spec.replicas > 1:
+			r.code, r.msg = errInvalidReplicationFactor, fmt.Sprintf("Replication factor: %d larger than available brokers: 1.", spec.replicas)
+		case !validateOnly:
+			b.createTopic(spec.name, partitions)
+			log.Printf("create topic %s with %d partitions\n", spec.name, partitions)
+		}
+		results = append(results, r)
+	}
+	b.mu.Unlock()
+
+	e := &encoder{}
+	if req.version >= 2 {
+		e.int32(0)
+	}
+	e.arrayLen(len(results))
+	for _, r := range results {
+		e.string(r.name)
+		e.int16(r.code)
+		if req.version >= 1 {
+			if r.code == errNone {
+				e.nullableString(nil)
+			} else {
+				e.nullableString(&r.msg)
+			}
+		}
+	}
+	return e
+}
+
+func (b *Broker) deleteTopics(req *request) *encoder {
+	d := req.body
+	n := d.arrayLen()
+	names := make([]string, 0, max(n, 0))
+	for i := 0; i < n && d.err == nil; i++ {
+		names = append(names, d.string())
+	}
+	d.int32()
+
+	e := &encoder{}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	e.arrayLen(len(names))
+	b.mu.Lock()
+	for _, name := range names {
+		e.string(name)
+		if _, ok := b.topics[name]; !ok {
+			e.int16(errUnknownTopicOrPartition)
+			continue
+		}
+		delete(b.topics, name)
+		b.groups.deleteOffsets(name)
+		log.Printf("delete topic %s\n", name)
+		e.int16(errNone)
+	}
+	b.mu.Unlock()
+	return e
+}
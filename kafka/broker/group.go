@@ -0,0 +1,662 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	minSessionTimeout = 6 * time.Second
+	maxSessionTimeout = 30 * time.Minute
+)
+
+type groupState int
+
+const (
+	groupEmpty groupState = iota
+	groupPreparingRebalance
+	groupCompletingRebalance
+	groupStable
+)
+
+// coordinator 管理所有的 consumer group group 在首次访问时创建
+type coordinator struct {
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+func newCoordinator() *coordinator {
+	return &coordinator{groups: make(map[string]*group)}
+}
+
+func (co *coordinator) group(id string) *group {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	g, ok := co.groups[id]
+	if !ok {
+		g = &group{
+			members: make(map[string]*member),
+			offsets: make(map[string]map[int32]committedOffset),
+		}
+		g.cond = sync.NewCond(&g.mu)
+		co.groups[id] = g
+	}
+	return g
+}
+
+// deleteOffsets 删除所有 group 中该 topic 已提交的 offset
+func (co *coordinator) deleteOffsets(name string) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	for _, g := range co.groups {
+		g.mu.Lock()
+		delete(g.offsets, name)
+		g.mu.Unlock()
+	}
+}
+
+// group 实现了简化的 group 状态机 JoinGroup 以及 SyncGroup 阻塞直到 rebalance 完成
+type group struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	state        groupState
+	generation   int32
+	protocolType string
+	protocol     string
+	leader       string
+	members      map[string]*member
+
+	// deadline 为当前 rebalance 等待成员重新加入的截止时间
+	deadline time.Time
+
+	offsets map[string]map[int32]committedOffset
+}
+
+type member struct {
+	id               string
+	sessionTimeout   time.Duration
+	rebalanceTimeout time.Duration
+	protocols        []groupProtocol
+	assignment       []byte
+	lastSeen         time.Time
+
+	// joined 表示成员已在当前 rebalance 中重新加入
+	joined bool
+}
+
+type groupProtocol struct {
+	name     string
+	metadata []byte
+}
+
+type committedOffset struct {
+	offset   int64
+	metadata string
+}
+
+func (m *member) metadata(name string) ([]byte, bool) {
+	for _, p := range m.protocols {
+		if p.name == name {
+			return p.metadata, true
+		}
+	}
+	return nil, false
+}
+
+func sameProtocols(a, b []groupProtocol) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].name != b[i].name || !bytes.Equal(a[i].metadata, b[i].metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// waitUntil 等待状态变化 最晚在 deadline 时被唤醒 调用方需持有锁
+func (g *group) waitUntil(deadline time.Time) {
+	t := time.AfterFunc(time.Until(deadline), func() {
+		g.mu.Lock()
+		g.cond.Broadcast()
+		g.mu.Unlock()
+	})
+	g.cond.Wait()
+	t.Stop()
+}
+
+// expire 移除会话超时的成员 rebalance 期间由 deadline 决定成员的去留
+func (g *group) expire() {
+	if g.state == groupPreparingRebalance {
+		return
+	}
+	now := time.Now()
+	var removed bool
+	for id, m := range g.members {
+		if now.Sub(m.lastSeen) > m.sessionTimeout {
+			delete(g.members, id)
+			removed = true
+		}
+	}
+	if removed {
+		g.rebalance()
+	}
+}
+
+// rebalance 在成员变化后开始新一轮 rebalance 没有成员时 group 变为 empty
+func (g *group) rebalance() {
+	if len(g.members) == 0 {
+		g.state = groupEmpty
+		g.leader = ""
+		g.protocol = ""
+		g.cond.Broadcast()
+		return
+	}
+	if g.state == groupPreparingRebalance {
+		g.cond.Broadcast()
+		return
+	}
+
+	var timeout time.Duration
+	for _, m := range g.members {
+		m.joined = false
+		timeout = max(timeout, m.rebalanceTimeout)
+	}
+	g.state = groupPreparingRebalance
+	g.deadline = time.Now().Add(timeout)
+	g.cond.Broadcast()
+}
+
+// tryComplete 在所有成员重新加入或者超过 deadline 后完成 rebalance 未加入的成员被移除
+func (g *group) tryComplete() {
+	if g.state != groupPreparingRebalance {
+		return
+	}
+	all := true
+	for _, m := range g.members {
+		all = all && m.joined
+	}
+	if !all && time.Now().Before(g.deadline) {
+		return
+	}
+
+	for id, m := range g.members {
+		if !m.joined {
+			delete(g.members, id)
+		}
+	}
+	g.generation++
+	if len(g.members) == 0 {
+		g.rebalance()
+		return
+	}
+	if _, ok := g.members[g.leader]; !ok {
+		for id := range g.members {
+			g.leader = id
+			break
+		}
+	}
+
+	// 按照 leader 的偏好选择所有成员均支持的第一个协议
+	g.protocol = ""
+	for _, p := range g.members[g.leader].protocols {
+		supported := true
+		for _, m := range g.members {
+			_, ok := m.metadata(p.name)
+			supported = supported && ok
+		}
+		if supported {
+			g.protocol = p.name
+			break
+		}
+	}
+	for _, m := range g.members {
+		m.assignment = nil
+	}
+	g.state = groupCompletingRebalance
+	g.cond.Broadcast()
+}
+
+// acceptable 判断新成员的协议是否与 group 中已有的成员兼容
+func (g *group) acceptable(id, protocolType string, protocols []groupProtocol) bool {
+	if len(protocols) == 0 {
+		return false
+	}
+	if len(g.members) == 0 || (len(g.members) == 1 && g.members[id] != nil) {
+		return true
+	}
+	if protocolType != g.protocolType {
+		return false
+	}
+	for _, p := range protocols {
+		supported := true
+		for mid, m := range g.members {
+			if mid == id {
+				continue
+			}
+			_, ok := m.metadata(p.name)
+			supported = supported && ok
+		}
+		if supported {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Broker) findCoordinator(c *conn, req *request) *encoder {
+	d := req.body
+	d.string()
+	if req.version >= 1 {
+		d.int8()
+	}
+
+	e := &encoder{}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	e.int16(errNone)
+	if req.version >= 1 {
+		e.nullableString(nil)
+	}
+	e.int32(nodeID)
+	e.string(c.host)
+	e.int32(c.port)
+	return e
+}
+
+type joinResult struct {
+	code       int16
+	generation int32
+	protocol   string
+	leader     string
+	memberID   string
+	members    []*member
+}
+
+func (b *Broker) joinGroup(req *request) *encoder {
+	d := req.body
+	groupID := d.string()
+	sessionTimeout := time.Duration(d.int32()) * time.Millisecond
+	rebalanceTimeout := sessionTimeout
+	if req.version >= 1 {
+		rebalanceTimeout = time.Duration(d.int32()) * time.Millisecond
+	}
+	memberID := d.string()
+	if req.version >= 5 {
+		d.string()
+	}
+	protocolType := d.string()
+	var protocols []groupProtocol
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		protocols = append(protocols, groupProtocol{name: d.string(), metadata: d.bytes()})
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	r := b.groups.group(groupID).join(req.clientID, memberID, protocolType, protocols, sessionTimeout, rebalanceTimeout)
+
+	e := &encoder{}
+	if req.version >= 2 {
+		e.int32(0)
+	}
+	e.int16(r.code)
+	e.int32(r.generation)
+	e.string(r.protocol)
+	e.string(r.leader)
+	e.string(r.memberID)
+	e.arrayLen(len(r.members))
+	for _, m := range r.members {
+		e.string(m.id)
+		if req.version >= 5 {
+			e.nullableString(nil)
+		}
+		metadata, _ := m.metadata(r.protocol)
+		e.bytes(metadata)
+	}
+	return e
+}
+
+func (g *group) join(clientID, memberID, protocolType string, protocols []groupProtocol, sessionTimeout, rebalanceTimeout time.Duration) joinResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	r := joinResult{generation: -1, memberID: memberID}
+	if sessionTimeout < minSessionTimeout || sessionTimeout > maxSessionTimeout {
+		r.code = errInvalidSessionTimeout
+		return r
+	}
+	g.expire()
+
+	m, ok := g.members[memberID]
+	if memberID != "" && !ok {
+		r.code = errUnknownMemberID
+		return r
+	}
+	if !g.acceptable(memberID, protocolType, protocols) {
+		r.code = errInconsistentGroupProto
+		return r
+	}
+
+	// 已知的非 leader 成员在 group 稳定且协议未变化时直接返回当前的状态
+	if ok && g.state == groupStable && memberID != g.leader && sameProtocols(m.protocols, protocols) {
+		m.lastSeen = time.Now()
+		r.generation, r.protocol, r.leader = g.generation, g.protocol, g.leader
+		return r
+	}
+
+	if !ok {
+		memberID = fmt.Sprintf("%s-%016x", clientID, rand.Uint64())
+		m = &member{id: memberID}
+		g.members[memberID] = m
+		r.memberID = memberID
+	}
+	m.sessionTimeout = sessionTimeout
+	m.rebalanceTimeout = rebalanceTimeout
+	m.protocols = protocols
+	m.lastSeen = time.Now()
+	g.protocolType = protocolType
+
+	g.rebalance()
+	m.joined = true
+	generation := g.generation
+	for g.state == groupPreparingRebalance && g.generation == generation {
+		g.tryComplete()
+		if g.state != groupPreparingRebalance || g.generation != generation {
+			break
+		}
+		g.waitUntil(g.deadline)
+	}
+
+	if g.members[memberID] != m {
+		r.code = errUnknownMemberID
+		return r
+	}
+	m.lastSeen = time.Now()
+	r.generation, r.protocol, r.leader = g.generation, g.protocol, g.leader
+	if memberID == g.leader {
+		for _, gm := range g.members {
+			r.members = append(r.members, gm)
+		}
+	}
+	return r
+}
+
+func (b *Broker) syncGroup(req *request) *encoder {
+	d := req.body
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if req.version >= 3 {
+		d.string()
+	}
+	assignments := make(map[string][]byte)
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		id := d.string()
+		assignments[id] = d.bytes()
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	code, assignment := b.groups.group(groupID).sync(memberID, generation, assignments)
+
+	e := &encoder{}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	e.int16(code)
+	if assignment == nil {
+		assignment = []byte{}
+	}
+	e.bytes(assignment)
+	return e
+}
+
+// sync 由 leader 下发分配结果 其余成员等待直到 leader 完成或者会话超时
+func (g *group) sync(memberID string, generation int32, assignments map[string][]byte) (int16, []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.expire()
+	m, ok := g.members[memberID]
+	if !ok {
+		return errUnknownMemberID, nil
+	}
+	if generation != g.generation {
+		return errIllegalGeneration, nil
+	}
+	m.lastSeen = time.Now()
+
+	if g.state == groupCompletingRebalance && memberID == g.leader {
+		for id, gm := range g.members {
+			gm.assignment = assignments[id]
+		}
+		g.state = groupStable
+		g.cond.Broadcast()
+	}
+	for g.state == groupCompletingRebalance && g.generation == generation {
+		g.waitUntil(m.lastSeen.Add(m.sessionTimeout))
+		g.expire()
+	}
+
+	if g.state != groupStable || g.generation != generation {
+		return errRebalanceInProgress, nil
+	}
+	if g.members[memberID] != m {
+		return errUnknownMemberID, nil
+	}
+	m.lastSeen = time.Now()
+	return errNone, m.assignment
+}
+
+func (b *Broker) heartbeat(req *request) *encoder {
+	d := req.body
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if req.version >= 3 {
+		d.string()
+	}
+
+	e := &encoder{}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	e.int16(b.groups.group(groupID).heartbeat(memberID, generation))
+	return e
+}
+
+func (g *group) heartbeat(memberID string, generation int32) int16 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.expire()
+	m, ok := g.members[memberID]
+	if !ok {
+		return errUnknownMemberID
+	}
+	if generation != g.generation {
+		return errIllegalGeneration
+	}
+	m.lastSeen = time.Now()
+	if g.state == groupPreparingRebalance {
+		return errRebalanceInProgress
+	}
+	return errNone
+}
+
+func (b *Broker) leaveGroup(req *request) *encoder {
+	d := req.body
+	groupID := d.string()
+	memberID := d.string()
+
+	e := &encoder{}
+	if req.version >= 1 {
+		e.int32(0)
+	}
+	e.int16(b.groups.group(groupID).leave(memberID))
+	return e
+}
+
+func (g *group) leave(memberID string) int16 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.members[memberID]; !ok {
+		return errUnknownMemberID
+	}
+	delete(g.members, memberID)
+	g.rebalance()
+	return errNone
+}
+
+// offsetCommit 保存提交的 offset generation 为 -1 时表示不属于任何成员的提交
+func (b *Broker) offsetCommit(req *request) *encoder {
+	type result struct {
+		index int32
+		code  int16
+	}
+	type topicResult struct {
+		name       string
+		partitions []result
+	}
+
+	d := req.body
+	g := b.groups.group(d.string())
+	generation := d.int32()
+	memberID := d.string()
+	if req.version >= 7 {
+		d.string()
+	}
+	if req.version <= 4 {
+		d.int64()
+	}
+
+	g.mu.Lock()
+	var code int16 = errNone
+	if generation != -1 {
+		g.expire()
+		if _, ok := g.members[memberID]; !ok {
+			code = errUnknownMemberID
+		} else if generation != g.generation {
+			code = errIllegalGeneration
+		} else if g.state == groupCompletingRebalance {
+			code = errRebalanceInProgress
+		}
+	}
+
+	var results []topicResult
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		tr := topicResult{name: d.string()}
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			r := result{index: d.int32(), code: code}
+			offset := d.int64()
+			if req.version >= 6 {
+				d.int32()
+			}
+			metadata := d.string()
+			if code == errNone && d.err == nil {
+				if g.offsets[tr.name] == nil {
+					g.offsets[tr.name] = make(map[int32]committedOffset)
+				}
+				g.offsets[tr.name][r.index] = committedOffset{offset: offset, metadata: metadata}
+			}
+			tr.partitions = append(tr.partitions, r)
+		}
+		results = append(results, tr)
+	}
+	g.mu.Unlock()
+
+	e := &encoder{}
+	if req.version >= 3 {
+		e.int32(0)
+	}
+	e.arrayLen(len(results))
+	for _, tr := range results {
+		e.string(tr.name)
+		e.arrayLen(len(tr.partitions))
+		for _, r := range tr.partitions {
+			e.int32(r.index)
+			e.int16(r.code)
+		}
+	}
+	return e
+}
+
+// offsetFetch 返回已提交的 offset 未提交的分区返回 -1 topics 为 null 时返回所有已提交的分区
+func (b *Broker) offsetFetch(req *request) *encoder {
+	type topicPartitions struct {
+		name       string
+		partitions []int32
+	}
+
+	d := req.body
+	g := b.groups.group(d.string())
+	n := d.arrayLen()
+	var topics []topicPartitions
+	for i := 0; i < n && d.err == nil; i++ {
+		topics = append(topics, topicPartitions{name: d.string(), partitions: d.int32s()})
+	}
+
+	g.mu.Lock()
+	if n < 0 {
+		for name, offsets := range g.offsets {
+			tp := topicPartitions{name: name}
+			for index := range offsets {
+				tp.partitions = append(tp.partitions, index)
+			}
+			topics = append(topics, tp)
+		}
+	}
+
+	e := &encoder{}
+	if req.version >= 3 {
+		e.int32(0)
+	}
+	e.arrayLen(len(topics))
+	for _, tp := range topics {
+		e.string(tp.name)
+		e.arrayLen(len(tp.partitions))
+		for _, index := range tp.partitions {
+			co, ok := g.offsets[tp.name][index]
+			if !ok {
+				co.offset = -1
+			}
+			e.int32(index)
+			e.int64(co.offset)
+			if req.version >= 5 {
+				e.int32(-1)
+			}
+			e.string(co.metadata)
+			e.int16(errNone)
+		}
+	}
+	g.mu.Unlock()
+
+	if req.version >= 2 {
+		e.int16(errNone)
+	}
+	return e
+}
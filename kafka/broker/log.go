@@ -0,0 +1,396 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+)
+
+// record batch (magic v2) 头部字段的偏移
+const (
+	batchLengthOffset     = 8
+	batchLeaderEpochStart = 12
+	batchMagicOffset      = 16
+	batchLastDeltaOffset  = 23
+	batchMaxTimeOffset    = 35
+	batchHeaderSize       = 61
+)
+
+const (
+	timestampLatest   = -1
+	timestampEarliest = -2
+)
+
+type topic struct {
+	name       string
+	partitions []*partition
+}
+
+// partition 为分区的日志 以生产者写入的 record batch 为单位保存 不解压也不校验 CRC
+type partition struct {
+	mu      sync.Mutex
+	batches []batch
+	size    int
+
+	// start 为 log start offset next 为 high watermark
+	start int64
+	next  int64
+}
+
+type batch struct {
+	base, last   int64
+	maxTimestamp int64
+	data         []byte
+}
+
+// createTopic 创建 topic 调用方需持有锁
+func (b *Broker) createTopic(name string, partitions int) *topic {
+	t := &topic{name: name, partitions: make([]*partition, partitions)}
+	for i := range t.partitions {
+		t.partitions[i] = &partition{}
+	}
+	b.topics[name] = t
+	return t
+}
+
+func (b *Broker) partition(name string, index int32) *partition {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	t, ok := b.topics[name]
+	if !ok || index < 0 || int(index) >= len(t.partitions) {
+		return nil
+	}
+	return t.partitions[index]
+}
+
+// notifyAppended 唤醒所有等待数据的 Fetch
+func (b *Broker) notifyAppended() {
+	b.appendMu.Lock()
+	close(b.appended)
+	b.appended = make(chan struct{})
+	b.appendMu.Unlock()
+}
+
+func (b *Broker) waitAppended() <-chan struct{} {
+	b.appendMu.Lock()
+	defer b.appendMu.Unlock()
+	return b.appended
+}
+
+// append 写入若干个 record batch 并分配 offset 返回第一个 batch 的 base offset
+func (p *partition) append(records []byte, retention int) (int64, int16) {
+	var batches []batch
+	for len(records) > 0 {
+		if len(records) < batchHeaderSize {
+			return 0, errCorruptMessage
+		}
+		n := int(binary.BigEndian.Uint32(records[batchLengthOffset:])) + 12
+		if n < batchHeaderSize || n > len(records) || records[batchMagicOffset] != 2 {
+			return 0, errCorruptMessage
+		}
+		data := records[:n]
+		records = records[n:]
+		batches = append(batches, batch{
+			last:         int64(binary.BigEndian.Uint32(data[batchLastDeltaOffset:])),
+			maxTimestamp: int64(binary.BigEndian.Uint64(data[batchMaxTimeOffset:])),
+			data:         data,
+		})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	base := p.next
+	for _, bt := range batches {
+		// base offset 以及 partition leader epoch 不在 CRC 的范围内 可以直接修改
+		bt.base = p.next
+		bt.last += p.next
+		binary.BigEndian.PutUint64(bt.data, uint64(bt.base))
+		binary.BigEndian.PutUint32(bt.data[batchLeaderEpochStart:], 0)
+		p.next = bt.last + 1
+		p.batches = append(p.batches, bt)
+		p.size += len(bt.data)
+	}
+
+	for len(p.batches) > 1 && p.size > retention {
+		p.size -= len(p.batches[0].data)
+		p.batches[0] = batch{}
+		p.batches = p.batches[1:]
+		p.start = p.batches[0].base
+	}
+	return base, errNone
+}
+
+// read 返回从 offset 开始的 record batch 至少返回一个 batch 总大小不超过 maxBytes
+// 第一个 batch 可能包含 offset 之前的消息 由客户端跳过
+func (p *partition) read(offset int64, maxBytes int) ([]byte, int16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if offset < p.start || offset > p.next {
+		return nil, errOffsetOutOfRange
+	}
+	i := sort.Search(len(p.batches), func(i int) bool {
+		return p.batches[i].last >= offset
+	})
+
+	var records []byte
+	for ; i < len(p.batches); i++ {
+		data := p.batches[i].data
+		if len(records) > 0 && len(records)+len(data) > maxBytes {
+			break
+		}
+		records = append(records, data...)
+	}
+	return records, errNone
+}
+
+func (p *partition) offsets() (int64, int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.start, p.next
+}
+
+// offsetForTime 返回第一个 max timestamp 不小于 ts 的 batch 的 base offset
+func (p *partition) offsetForTime(ts int64) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, bt := range p.batches {
+		if bt.maxTimestamp >= ts {
+			return bt.base
+		}
+	}
+	return p.next
+}
+
+func (b *Broker) produce(req *request) *encoder {
+	type result struct {
+		partition int32
+		code      int16
+		base      int64
+		start     int64
+	}
+	type topicResult struct {
+		name       string
+		partitions []result
+	}
+
+	d := req.body
+	d.string()
+	acks := d.int16()
+	d.int32()
+
+	var results []topicResult
+	var appended bool
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		tr := topicResult{name: d.string()}
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			r := result{partition: d.int32(), base: -1, start: -1}
+			records := d.bytes()
+			if d.err != nil {
+				break
+			}
+
+			p := b.partition(tr.name, r.partition)
+			if p == nil {
+				r.code = errUnknownTopicOrPartition
+			} else if r.base, r.code = p.append(records, b.retention); r.code == errNone {
+				r.start, _ = p.offsets()
+				appended = true
+			}
+			tr.partitions = append(tr.partitions, r)
+		}
+		results = append(results, tr)
+	}
+	if appended {
+		b.notifyAppended()
+	}
+	if acks == 0 {
+		return nil
+	}
+
+	e := &encoder{}
+	e.arrayLen(len(results))
+	for _, tr := range results {
+		e.string(tr.name)
+		e.arrayLen(len(tr.partitions))
+		for _, r := range tr.partitions {
+			e.int32(r.partition)
+			e.int16(r.code)
+			e.int64(r.base)
+			e.int64(-1)
+			if req.version >= 5 {
+				e.int64(r.start)
+			}
+		}
+	}
+	e.int32(0)
+	return e
+}
+
+// fetch 在数据不足 min_bytes 时等待新的写入 最多等待 max_wait_ms
+func (b *Broker) fetch(req *request) *encoder {
+	type fetchPartition struct {
+		index    int32
+		offset   int64
+		maxBytes int32
+	}
+	type fetchTopic struct {
+		name       string
+		partitions []fetchPartition
+	}
+
+	d := req.body
+	d.int32()
+	maxWait := time.Duration(d.int32()) * time.Millisecond
+	minBytes := int(d.int32())
+	maxBytes := int(d.int32())
+	d.int8()
+	if req.version >= 7 {
+		d.int32()
+		d.int32()
+	}
+	var topics []fetchTopic
+	for i, n := 0, d.arrayLen(); i < n && d.err == nil; i++ {
+		ft := fetchTopic{name: d.string()}
+		for j, m := 0, d.arrayLen(); j < m && d.err == nil; j++ {
+			fp := fetchPartition{index: d.int32()}
+			if req.version >= 9 {
+				d.int32()
+			}
+			fp.offset = d.int64()
+			if req.version >= 5 {
+				d.int64()
+			}
+			fp.maxBytes = d.int32()
+			ft.partitions = append(ft.partitions, fp)
+		}
+		topics = append(topics, ft)
+	}
+	if d.err != nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(maxWait)
+	for {
+		// 在读取之前获取通知 channel 避免错过读取期间的写入
+		wait := b.waitAppended()
+
+		e := &encoder{}
+		e.int32(0)
+		if req.version >= 7 {
+			e.int16(errNone)
+			e.int32(0)
+		}
+		e.arrayLen(len(topics))
+		total := 0
+		for _, ft := range topics {
+			e.string(ft.name)
+			e.arrayLen(len(ft.partitions))
+			for _, fp := range ft.partitions {
+				var records []byte
+				var code int16 = errUnknownTopicOrPartition
+				var start, next int64 = -1, -1
+				if p := b.partition(ft.name, fp.index); p != nil {
+					limit := min(int(fp.maxBytes), max(maxBytes-total, 0))
+					records, code = p.read(fp.offset, limit)
+					start, next = p.offsets()
+					total += len(records)
+				}
+
+				e.int32(fp.index)
+				e.int16(code)
+				e.int64(next)
+				e.int64(next)
+				if req.version >= 5 {
+					e.int64(start)
+				}
+				e.arrayLen(-1)
+				if req.version >= 11 {
+					e.int32(-1)
+				}
+				e.bytes(records)
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if total >= minBytes || remaining <= 0 {
+			return e
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-wait:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (b *Broker) listOffsets(req *request) *encoder {
+	d := req.body
+	d.int32()
+	if req.version >= 2 {
+		d.int8()
+	}
+
+	e := &encoder{}
+	if req.version >= 2 {
+		e.int32(0)
+	}
+	n := d.arrayLen()
+	e.arrayLen(max(n, 0))
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		e.string(name)
+
+		m := d.arrayLen()
+		e.arrayLen(max(m, 0))
+		for j := 0; j < m && d.err == nil; j++ {
+			index := d.int32()
+			if req.version >= 4 {
+				d.int32()
+			}
+			ts := d.int64()
+
+			code, offset := int16(errNone), int64(-1)
+			if p := b.partition(name, index); p == nil {
+				code = errUnknownTopicOrPartition
+			} else {
+				start, next := p.offsets()
+				switch ts {
+				case timestampLatest:
+					offset = next
+				case timestampEarliest:
+					offset = start
+				default:
+					offset = p.offsetForTime(ts)
+				}
+			}
+
+			e.int32(index)
+			e.int16(code)
+			e.int64(-1)
+			e.int64(offset)
+			if req.version >= 4 {
+				e.int32(0)
+			}
+		}
+	}
+	return e
+}
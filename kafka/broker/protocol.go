@@ -0,0 +1,252 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// API 类型
+const (
+	apiProduce         = 0
+	apiFetch           = 1
+	apiListOffsets     = 2
+	apiMetadata        = 3
+	apiOffsetCommit    = 8
+	apiOffsetFetch     = 9
+	apiFindCoordinator = 10
+	apiJoinGroup       = 11
+	apiHeartbeat       = 12
+	apiLeaveGroup      = 13
+	apiSyncGroup       = 14
+	apiApiVersions     = 18
+	apiCreateTopics    = 19
+	apiDeleteTopics    = 20
+)
+
+// 错误码
+const (
+	errNone                     = 0
+	errOffsetOutOfRange         = 1
+	errCorruptMessage           = 2
+	errUnknownTopicOrPartition  = 3
+	errInvalidTopic             = 17
+	errIllegalGeneration        = 22
+	errInconsistentGroupProto   = 23
+	errUnknownMemberID          = 25
+	errInvalidSessionTimeout    = 26
+	errRebalanceInProgress      = 27
+	errUnsupportedVersion       = 35
+	errTopicAlreadyExists       = 36
+	errInvalidPartitions        = 37
+	errInvalidReplicationFactor = 38
+	errInvalidRequest           = 42
+)
+
+// apiVersion 为支持的版本范围 仅支持非 flexible 的版本 因此请求头均为 v1 响应头均为 v0
+type apiVersion struct {
+	key      int16
+	min, max int16
+}
+
+// apiVersions 覆盖 kafka-go 协商后的版本以及 sarama 默认配置 (V2_1_0_0) 使用的固定版本
+var apiVersions = []apiVersion{
+	{apiProduce, 3, 7},
+	{apiFetch, 4, 11},
+	{apiListOffsets, 1, 5},
+	{apiMetadata, 0, 8},
+	{apiOffsetCommit, 2, 7},
+	{apiOffsetFetch, 1, 5},
+	{apiFindCoordinator, 0, 2},
+	{apiJoinGroup, 0, 5},
+	{apiHeartbeat, 0, 3},
+	{apiLeaveGroup, 0, 2},
+	{apiSyncGroup, 0, 3},
+	{apiApiVersions, 0, 2},
+	{apiCreateTopics, 0, 4},
+	{apiDeleteTopics, 0, 3},
+}
+
+func supported(key, version int16) bool {
+	for _, v := range apiVersions {
+		if v.key == key {
+			return version >= v.min && version <= v.max
+		}
+	}
+	return false
+}
+
+var errMalformed = errors.New("malformed request")
+
+// decoder 用于解析请求 出现越界时记录 errMalformed 并返回零值
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errMalformed
+	}
+	d.b = nil
+}
+
+func (d *decoder) next(n int) []byte {
+	if n < 0 || len(d.b) < n {
+		d.fail()
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (d *decoder) bool() bool {
+	return d.int8() != 0
+}
+
+func (d *decoder) int16() int16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (d *decoder) int32() int32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// string 返回 int16 长度前缀的字符串 null 返回空字符串
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// bytes 返回 int32 长度前缀的字节数组 null 返回 nil
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// arrayLen 返回数组的长度 null 返回 -1
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < -1 || int(n) > len(d.b) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) int32s() []int32 {
+	n := d.arrayLen()
+	if n < 0 {
+		return nil
+	}
+	vs := make([]int32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		vs = append(vs, d.int32())
+	}
+	return vs
+}
+
+// encoder 用于构造响应
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8) {
+	e.b = append(e.b, byte(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *encoder) int16(v int16) {
+	e.b = binary.BigEndian.AppendUint16(e.b, uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, uint64(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) arrayLen(n int) {
+	e.int32(int32(n))
+}
+
+func (e *encoder) int32s(vs []int32) {
+	e.arrayLen(len(vs))
+	for _, v := range vs {
+		e.int32(v)
+	}
+}
@@ -11,18 +11,21 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package consumer
 
 import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/IBM/sarama"
+
+	"github.com/packetd/packetd-benchmark/common"
 )
 
 type Config struct {
@@ -154,19 +157,32 @@ func (c *Client) Close() error {
 	return c.cli.Close()
 }
 
-func main() {
+// NewCommand 返回 `kafka consume` 子命令
+func NewCommand() *common.Command {
 	var c Config
-	flag.StringVar(&c.Brokers, "brokers", "localhost:9092", "kafka bootstrap brokers, as a comma separated list")
-	flag.StringVar(&c.Topic, "topic", "benchmark", "kafka topics to be consumed")
-	flag.IntVar(&c.Total, "total", 100, "require consume messages count")
-	flag.Parse()
+	return &common.Command{
+		Name:  "kafka consume",
+		Usage: "consume messages from a kafka topic with a sarama consumer group",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Brokers, "brokers", "localhost:9092", "kafka bootstrap brokers, as a comma separated list")
+			fs.StringVar(&c.Topic, "topic", "benchmark", "kafka topics to be consumed")
+			fs.IntVar(&c.Total, "total", 100, "require consume messages count")
+		},
+		Run: func(_ []string) error {
+			client, err := New(c)
+			if err != nil {
+				return fmt.Errorf("failed to create client: %w", err)
+			}
 
-	client, err := New(c)
-	if err != nil {
-		log.Fatalf("failed to create client: %v", err)
+			// 消费完成后会主动取消 context
+			if err := client.Run(); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			if err := client.Close(); err != nil {
+				return err
+			}
+			log.Printf("consumed %d messages in %s, size=%vMB\n", c.Total, client.consumer.cost, client.consumer.bytes/1024/1024)
+			return nil
+		},
 	}
-
-	client.Run()
-	client.Close()
-	log.Printf("consumed %d messages in %s, size=%vMB\n", c.Total, client.consumer.cost, client.consumer.bytes/1024/1024)
 }
@@ -11,7 +11,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package producer
 
 import (
 	"bytes"
@@ -25,6 +25,8 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+
+	"github.com/packetd/packetd-benchmark/common"
 )
 
 const (
@@ -153,19 +155,28 @@ func (c *Client) Run() error {
 	return nil
 }
 
-func main() {
+// NewCommand 返回 `kafka produce` 子命令
+func NewCommand() *common.Command {
 	var c Config
-	flag.StringVar(&c.Brokers, "brokers", "localhost:9092", "kafka bootstrap brokers, as a comma separated list")
-	flag.StringVar(&c.Topic, "topic", "benchmark", "kafka topics to be consumed")
-	flag.IntVar(&c.Total, "total", 100, "require produce messages count")
-	flag.IntVar(&c.MessageSize, "message_size", 10240, "kafka message size in bytes")
-	flag.Parse()
+	return &common.Command{
+		Name:  "kafka produce",
+		Usage: "produce messages to a kafka topic with kafka-go",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Brokers, "brokers", "localhost:9092", "kafka bootstrap brokers, as a comma separated list")
+			fs.StringVar(&c.Topic, "topic", "benchmark", "kafka topics to be consumed")
+			fs.IntVar(&c.Total, "total", 100, "require produce messages count")
+			fs.IntVar(&c.MessageSize, "message_size", 10240, "kafka message size in bytes")
+		},
+		Run: func(_ []string) error {
+			client, err := New(c)
+			if err != nil {
+				return err
+			}
 
-	client, err := New(c)
-	if err != nil {
-		log.Fatal(err)
+			if err := client.Run(); err != nil {
+				return err
+			}
+			return client.Close()
+		},
 	}
-
-	client.Run()
-	client.Close()
 }
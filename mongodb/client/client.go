@@ -0,0 +1,130 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	DSN        string
+	Workers    int
+	Total      int
+	Database   string
+	Collection string
+	Limit      int64
+	Interval   time.Duration
+}
+
+type Client struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	conf       Config
+	cli        *mongo.Client
+	collection *mongo.Collection
+}
+
+func New(conf Config) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Client{
+		ctx:    ctx,
+		cancel: cancel,
+		conf:   conf,
+	}
+}
+
+func (c *Client) Setup() error {
+	cli, err := mongo.Connect(c.ctx, options.Client().ApplyURI(c.conf.DSN))
+	if err != nil {
+		return err
+	}
+
+	options.Client().SetMaxConnecting(64)
+	options.Client().SetMinPoolSize(64)
+
+	c.cli = cli
+	c.collection = cli.Database(c.conf.Database).Collection(c.conf.Collection)
+	return nil
+}
+
+func (c *Client) Do(int) error {
+	opt := options.Find()
+	opt.Limit = &c.conf.Limit
+	r, err := c.collection.Find(c.ctx, bson.D{}, opt)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer r.Close(c.ctx)
+
+	for r.Next(c.ctx) {
+	}
+	return wrapError(r.Err())
+}
+
+// wrapError 将服务端返回的命令错误视为非预期的状态
+func wrapError(err error) error {
+	var serr mongo.ServerError
+	if errors.As(err, &serr) {
+		return common.NewStatusError("%s", serr.Error())
+	}
+	return err
+}
+
+func (c *Client) Teardown() error {
+	defer c.cancel()
+	return c.cli.Disconnect(context.Background())
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "limit", Value: c.conf.Limit},
+	}
+}
+
+// NewCommand 返回 `mongodb client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "mongodb client",
+		Usage: "benchmark a mongodb server with find commands",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.DSN, "dsn", "", "mongodb server dsn")
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.Database, "database", "", "database name")
+			fs.StringVar(&c.Collection, "collection", "", "collection name")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request")
+			fs.Int64Var(&c.Limit, "limit", 0, "records count")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "mongodb"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.Interval = c.Interval
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
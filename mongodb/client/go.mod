@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	github.com/golang/snappy v0.0.4 // indirect
+	github.com/jedib0t/go-pretty/v6 v6.6.7 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
@@ -16,12 +17,12 @@ require (
 	golang.org/x/sync v0.11.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace github.com/packetd/packetd-benchmark/common v0.0.0 => ./../../common
 
 require (
-	github.com/jedib0t/go-pretty/v6 v6.6.7
 	github.com/packetd/packetd-benchmark/common v0.0.0
 	go.mongodb.org/mongo-driver v1.17.3
 )
@@ -0,0 +1,559 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// defaultFirstBatch 与 mongod 一致 未指定 batchSize 时第一批最多返回 101 个文档
+	defaultFirstBatch = 101
+
+	maxWireVersion = 21
+)
+
+// emptyDoc 为空文档 `{}` 匹配所有文档
+var emptyDoc = bson.Raw{5, 0, 0, 0, 0}
+
+// commandError 为命令执行失败时回复的错误 code 以及 name 与 mongod 的错误码一致
+type commandError struct {
+	code int32
+	name string
+	msg  string
+}
+
+func (e *commandError) Error() string {
+	return e.msg
+}
+
+func badValue(format string, args ...any) *commandError {
+	return &commandError{code: 2, name: "BadValue", msg: fmt.Sprintf(format, args...)}
+}
+
+type handler func(s *Server, c *conn, req *request) (bson.D, error)
+
+var commands map[string]handler
+
+func init() {
+	commands = map[string]handler{
+		"hello":       (*Server).hello,
+		"isMaster":    (*Server).hello,
+		"ismaster":    (*Server).hello,
+		"ping":        okCommand,
+		"endSessions": okCommand,
+		"buildInfo":   (*Server).buildInfo,
+		"buildinfo":   (*Server).buildInfo,
+		"find":        (*Server).find,
+		"getMore":     (*Server).getMore,
+		"killCursors": (*Server).killCursors,
+		"insert":      (*Server).insert,
+		"update":      (*Server).update,
+		"delete":      (*Server).delete,
+		"aggregate":   (*Server).aggregate,
+	}
+}
+
+// okCommand 用于无需处理的命令 仅回复 ok
+func okCommand(*Server, *conn, *request) (bson.D, error) {
+	return bson.D{}, nil
+}
+
+// hello 回复握手 不返回 topologyVersion 因此驱动使用轮询而非流式的监控协议
+func (s *Server) hello(c *conn, req *request) (bson.D, error) {
+	primary := "isWritablePrimary"
+	if req.name() != "hello" {
+		primary = "ismaster"
+	}
+	reply := bson.D{
+		{Key: primary, Value: true},
+		{Key: "maxBsonObjectSize", Value: int32(maxBSONObjectSize)},
+		{Key: "maxMessageSizeBytes", Value: int32(maxMessageSize)},
+		{Key: "maxWriteBatchSize", Value: int32(100000)},
+		{Key: "localTime", Value: time.Now()},
+		{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		{Key: "connectionId", Value: int32(c.id)},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(maxWireVersion)},
+		{Key: "readOnly", Value: false},
+	}
+	if truthy(req.body.Lookup("helloOk")) {
+		reply = append(reply, bson.E{Key: "helloOk", Value: true})
+	}
+	return reply, nil
+}
+
+func (s *Server) buildInfo(*conn, *request) (bson.D, error) {
+	return bson.D{
+		{Key: "version", Value: "7.0.0"},
+		{Key: "versionArray", Value: bson.A{int32(7), int32(0), int32(0), int32(0)}},
+		{Key: "maxBsonObjectSize", Value: int32(maxBSONObjectSize)},
+	}, nil
+}
+
+// namespace 返回命令操作的集合 即命令名对应的字符串值
+func namespace(req *request) (string, error) {
+	coll, ok := req.body.Lookup(req.name()).StringValueOK()
+	if !ok || coll == "" {
+		return "", &commandError{code: 73, name: "InvalidNamespace", msg: fmt.Sprintf("collection name has invalid type %s", req.body.Lookup(req.name()).Type)}
+	}
+	return req.db + "." + coll, nil
+}
+
+// intOption 返回数值类型的选项 缺失时返回 def
+func intOption(doc bson.Raw, key string, def int) (int, error) {
+	v, err := doc.LookupErr(key)
+	if err != nil {
+		return def, nil
+	}
+	n, ok := v.AsInt64OK()
+	if !ok {
+		return 0, badValue("%s must be a number", key)
+	}
+	return int(n), nil
+}
+
+func documentOption(doc bson.Raw, key string) (bson.Raw, error) {
+	v, err := doc.LookupErr(key)
+	if err != nil {
+		return emptyDoc, nil
+	}
+	d, ok := v.DocumentOK()
+	if !ok {
+		return nil, badValue("%s must be an object", key)
+	}
+	return d, nil
+}
+
+// find 返回第一批文档 其余的文档保存在游标中 由 getMore 获取
+func (s *Server) find(c *conn, req *request) (bson.D, error) {
+	ns, err := namespace(req)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := documentOption(req.body, "filter")
+	if err != nil {
+		return nil, err
+	}
+	skip, err := intOption(req.body, "skip", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intOption(req.body, "limit", 0)
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intOption(req.body, "batchSize", defaultFirstBatch)
+	if err != nil {
+		return nil, err
+	}
+	if skip < 0 || batchSize < 0 {
+		return nil, badValue("skip and batchSize must not be negative")
+	}
+
+	// limit 为负数时表示仅返回一批
+	singleBatch := truthy(req.body.Lookup("singleBatch"))
+	if limit < 0 {
+		limit, singleBatch = -limit, true
+	}
+
+	s.store.mu.Lock()
+	docs, err := s.store.collection(ns).find(filter, skip, limit)
+	s.store.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.cursorReply(ns, "firstBatch", docs, batchSize, singleBatch), nil
+}
+
+// cursorReply 返回一批文档 剩余的文档保存至新的游标
+func (s *Server) cursorReply(ns, field string, docs []bson.Raw, batchSize int, singleBatch bool) bson.D {
+	batch, rest := nextBatch(docs, batchSize)
+	var id int64
+	if len(rest) > 0 && !singleBatch {
+		id = s.cursors.add(ns, rest)
+	}
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: field, Value: batch},
+		{Key: "id", Value: id},
+		{Key: "ns", Value: ns},
+	}}}
+}
+
+// nextBatch 返回最多 batchSize 个文档 batchSize 为 0 时不限制个数 单批的总大小不超过 16MB
+func nextBatch(docs []bson.Raw, batchSize int) (bson.A, []bson.Raw) {
+	var size int
+	batch := bson.A{}
+	for len(docs) > 0 && (batchSize <= 0 || len(batch) < batchSize) {
+		size += len(docs[0])
+		if size > maxBSONObjectSize && len(batch) > 0 {
+			break
+		}
+		batch = append(batch, docs[0])
+		docs = docs[1:]
+	}
+	return batch, docs
+}
+
+func (s *Server) getMore(c *conn, req *request) (bson.D, error) {
+	id, ok := req.body.Lookup("getMore").Int64OK()
+	if !ok {
+		return nil, badValue("cursor id must be a 64-bit integer")
+	}
+	batchSize, err := intOption(req.body, "batchSize", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, ok := s.cursors.take(id)
+	if !ok {
+		return nil, &commandError{code: 43, name: "CursorNotFound", msg: fmt.Sprintf("cursor id %d not found", id)}
+	}
+	batch, rest := nextBatch(cur.docs, batchSize)
+	if len(rest) > 0 {
+		cur.docs = rest
+		s.cursors.put(cur)
+	} else {
+		id = 0
+	}
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: "nextBatch", Value: batch},
+		{Key: "id", Value: id},
+		{Key: "ns", Value: cur.ns},
+	}}}, nil
+}
+
+func (s *Server) killCursors(c *conn, req *request) (bson.D, error) {
+	arr, ok := req.body.Lookup("cursors").ArrayOK()
+	if !ok {
+		return nil, badValue("cursors must be an array")
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	killed, notFound := bson.A{}, bson.A{}
+	for _, v := range values {
+		id, ok := v.Int64OK()
+		if !ok {
+			return nil, badValue("cursor id must be a 64-bit integer")
+		}
+		if _, ok := s.cursors.take(id); ok {
+			killed = append(killed, id)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return bson.D{
+		{Key: "cursorsKilled", Value: killed},
+		{Key: "cursorsNotFound", Value: notFound},
+		{Key: "cursorsAlive", Value: bson.A{}},
+		{Key: "cursorsUnknown", Value: bson.A{}},
+	}, nil
+}
+
+// writeError 为写操作中单个语句的错误 index 为语句的下标
+func writeError(index int, err error) bson.D {
+	code, msg := int32(2), err.Error()
+	if ce, ok := err.(*commandError); ok {
+		code = ce.code
+	}
+	return bson.D{{Key: "index", Value: int32(index)}, {Key: "code", Value: code}, {Key: "errmsg", Value: msg}}
+}
+
+// ordered 为 true 时遇到写错误即停止 未指定时默认为 true
+func ordered(req *request) bool {
+	v, err := req.body.LookupErr("ordered")
+	return err != nil || truthy(v)
+}
+
+// withWriteErrors 在存在写错误时追加 writeErrors 字段
+func withWriteErrors(reply bson.D, errs bson.A) bson.D {
+	if len(errs) > 0 {
+		reply = append(reply, bson.E{Key: "writeErrors", Value: errs})
+	}
+	return reply
+}
+
+func (s *Server) insert(c *conn, req *request) (bson.D, error) {
+	ns, err := namespace(req)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := req.documents("documents")
+	if err != nil {
+		return nil, badValue("%v", err)
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	coll := s.store.collection(ns)
+
+	var n int32
+	var errs bson.A
+	for i, doc := range docs {
+		if err := coll.insert(doc); err != nil {
+			errs = append(errs, writeError(i, err))
+			if ordered(req) {
+				break
+			}
+			continue
+		}
+		n++
+	}
+	return withWriteErrors(bson.D{{Key: "n", Value: n}}, errs), nil
+}
+
+// update 处理 updates 中的每个语句 {q: filter, u: update, multi: bool, upsert: bool}
+func (s *Server) update(c *conn, req *request) (bson.D, error) {
+	ns, err := namespace(req)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := req.documents("updates")
+	if err != nil {
+		return nil, badValue("%v", err)
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	coll := s.store.collection(ns)
+
+	var n, modified int32
+	var errs, upserted bson.A
+	for i, stmt := range updates {
+		matched, changed, id, err := updateOne(coll, stmt)
+		if err != nil {
+			errs = append(errs, writeError(i, err))
+			if ordered(req) {
+				break
+			}
+			continue
+		}
+		n += int32(matched)
+		modified += int32(changed)
+		if id.Type != 0 {
+			n++
+			upserted = append(upserted, bson.D{{Key: "index", Value: int32(i)}, {Key: "_id", Value: id}})
+		}
+	}
+
+	reply := bson.D{{Key: "n", Value: n}, {Key: "nModified", Value: modified}}
+	if len(upserted) > 0 {
+		reply = append(reply, bson.E{Key: "upserted", Value: upserted})
+	}
+	return withWriteErrors(reply, errs), nil
+}
+
+// updateOne 返回匹配以及修改的文档个数 发生 upsert 时返回新文档的 _id
+func updateOne(coll *collection, stmt bson.Raw) (int, int, bson.RawValue, error) {
+	filter, err := documentOption(stmt, "q")
+	if err != nil {
+		return 0, 0, bson.RawValue{}, err
+	}
+	u, ok := stmt.Lookup("u").DocumentOK()
+	if !ok {
+		return 0, 0, bson.RawValue{}, badValue("u must be an object, pipeline updates are not supported")
+	}
+
+	matched, modified, err := coll.update(filter, u, truthy(stmt.Lookup("multi")))
+	if err != nil || matched > 0 || !truthy(stmt.Lookup("upsert")) {
+		return matched, modified, bson.RawValue{}, err
+	}
+	id, err := coll.upsert(filter, u)
+	return 0, 0, id, err
+}
+
+// delete 处理 deletes 中的每个语句 {q: filter, limit: 0 或者 1}
+func (s *Server) delete(c *conn, req *request) (bson.D, error) {
+	ns, err := namespace(req)
+	if err != nil {
+		return nil, err
+	}
+	deletes, err := req.documents("deletes")
+	if err != nil {
+		return nil, badValue("%v", err)
+	}
+
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	coll := s.store.collection(ns)
+
+	var n int32
+	var errs bson.A
+	for i, stmt := range deletes {
+		filter, err := documentOption(stmt, "q")
+		if err == nil {
+			var limit, removed int
+			if limit, err = intOption(stmt, "limit", 0); err == nil {
+				removed, err = coll.remove(filter, limit)
+				n += int32(removed)
+			}
+		}
+		if err != nil {
+			errs = append(errs, writeError(i, err))
+			if ordered(req) {
+				break
+			}
+		}
+	}
+	return withWriteErrors(bson.D{{Key: "n", Value: n}}, errs), nil
+}
+
+// aggregate 支持 $match $skip $limit $count 以及 $group 阶段 结果通过游标返回
+func (s *Server) aggregate(c *conn, req *request) (bson.D, error) {
+	ns, err := namespace(req)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := req.body.Lookup("pipeline").ArrayOK()
+	if !ok {
+		return nil, badValue("'pipeline' option must be specified as an array")
+	}
+	stages, err := arr.Values()
+	if err != nil {
+		return nil, err
+	}
+	cursorOpt, err := documentOption(req.body, "cursor")
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intOption(cursorOpt, "batchSize", defaultFirstBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.mu.Lock()
+	docs, err := s.store.collection(ns).find(emptyDoc, 0, 0)
+	s.store.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range stages {
+		stage, ok := v.DocumentOK()
+		if !ok {
+			return nil, badValue("each element of the 'pipeline' array must be an object")
+		}
+		if docs, err = runStage(docs, stage); err != nil {
+			return nil, err
+		}
+	}
+	return s.cursorReply(ns, "firstBatch", docs, batchSize, false), nil
+}
+
+func runStage(docs []bson.Raw, stage bson.Raw) ([]bson.Raw, error) {
+	elem, err := stage.IndexErr(0)
+	if err != nil {
+		return nil, badValue("a pipeline stage specification object must contain exactly one field")
+	}
+
+	name, v := elem.Key(), elem.Value()
+	switch name {
+	case "$match":
+		filter, ok := v.DocumentOK()
+		if !ok {
+			return nil, badValue("the match filter must be an expression in an object")
+		}
+		var matched []bson.Raw
+		for _, doc := range docs {
+			ok, err := match(doc, filter)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched = append(matched, doc)
+			}
+		}
+		return matched, nil
+	case "$skip", "$limit":
+		n, ok := v.AsInt64OK()
+		if !ok || n < 0 || name == "$limit" && n == 0 {
+			return nil, badValue("invalid argument to %s stage", name)
+		}
+		if int(n) > len(docs) {
+			n = int64(len(docs))
+		}
+		if name == "$skip" {
+			return docs[n:], nil
+		}
+		return docs[:n], nil
+	case "$count":
+		field, ok := v.StringValueOK()
+		if !ok || field == "" || strings.HasPrefix(field, "$") {
+			return nil, badValue("the count field must be a non-empty string")
+		}
+		if len(docs) == 0 {
+			return nil, nil
+		}
+		doc, err := bson.Marshal(bson.D{{Key: field, Value: int32(len(docs))}})
+		return []bson.Raw{doc}, err
+	case "$group":
+		spec, ok := v.DocumentOK()
+		if !ok {
+			return nil, badValue("a group's fields must be specified in an object")
+		}
+		return group(docs, spec)
+	}
+	return nil, &commandError{code: 40324, name: "Location40324", msg: fmt.Sprintf("Unrecognized pipeline stage name: '%s'", name)}
+}
+
+// group 将所有文档归为一组 仅支持常量 _id 以及对常量求和的 $sum 如 CountDocuments 使用的
+// {$group: {_id: 1, n: {$sum: 1}}}
+func group(docs []bson.Raw, spec bson.Raw) ([]bson.Raw, error) {
+	elems, err := spec.Elements()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spec.LookupErr("_id"); err != nil {
+		return nil, &commandError{code: 15955, name: "Location15955", msg: "a group specification must include an _id"}
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var out bson.D
+	for _, elem := range elems {
+		key, v := elem.Key(), elem.Value()
+		if key == "_id" {
+			if s, ok := v.StringValueOK(); ok && strings.HasPrefix(s, "$") {
+				return nil, badValue("$group by field path is not supported")
+			}
+			out = append(out, bson.E{Key: key, Value: v})
+			continue
+		}
+
+		acc, ok := v.DocumentOK()
+		if !ok {
+			return nil, badValue("the field '%s' must be an accumulator object", key)
+		}
+		n, ok := number(acc.Lookup("$sum"))
+		if !ok {
+			return nil, badValue("only $sum of a constant is supported in $group")
+		}
+		sum := n * float64(len(docs))
+		if sum == float64(int64(sum)) {
+			out = append(out, bson.E{Key: key, Value: int64(sum)})
+		} else {
+			out = append(out, bson.E{Key: key, Value: sum})
+		}
+	}
+	doc, err := bson.Marshal(out)
+	return []bson.Raw{doc}, err
+}
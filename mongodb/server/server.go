@@ -0,0 +1,233 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	Addr string
+
+	// Documents 以及 DocumentSize 为每个集合在首次访问时写入的合成文档个数以及大小
+	Documents    int
+	DocumentSize string
+
+	// Latency 为每条命令回复前的等待时间
+	Latency time.Duration
+}
+
+// Server 为 mongodb 的替身 通过 OP_MSG 实现了压测所需的命令 数据仅保存在内存中
+type Server struct {
+	conf    Config
+	store   *store
+	cursors *cursors
+	ids     atomic.Int32
+}
+
+func New(conf Config) (*Server, error) {
+	size, err := common.ParseBytes(conf.DocumentSize)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Documents < 0 {
+		return nil, errors.New("documents must not be negative")
+	}
+	if size > maxBSONObjectSize {
+		return nil, fmt.Errorf("document size must not exceed %d bytes", maxBSONObjectSize)
+	}
+
+	return &Server{
+		conf: conf,
+		store: newStore(func() *collection {
+			return seedCollection(conf.Documents, size)
+		}),
+		cursors: &cursors{open: make(map[int64]*cursor)},
+	}, nil
+}
+
+// NewCommand 返回 `mongodb server` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:  "mongodb server",
+		Usage: "serve an in-memory mongodb stand-in over OP_MSG with synthetic documents",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:27017", "mongodb server address")
+			fs.IntVar(&c.Documents, "documents", 1000, "synthetic documents seeded into each collection on first access")
+			fs.StringVar(&c.DocumentSize, "document_size", "256B", "approximate size of each synthetic document")
+			fs.DurationVar(&c.Latency, "latency", 0, "delay before replying each command")
+		},
+		Run: func([]string) error {
+			s, err := New(c)
+			if err != nil {
+				return err
+			}
+			return s.Serve()
+		},
+	}
+}
+
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(c)
+	}
+}
+
+type conn struct {
+	id        int32
+	wc        wireConn
+	requestID int32
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+
+	c := &conn{
+		id: s.ids.Add(1),
+		wc: wireConn{br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)},
+	}
+	for {
+		h, body, err := c.wc.readMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+
+		var req *request
+		switch h.opCode {
+		case opMsg:
+			req, err = parseMsg(h, body)
+		case opQuery:
+			req, err = parseQuery(h, body)
+		default:
+			err = fmt.Errorf("unsupported opcode %d", h.opCode)
+		}
+		if err != nil {
+			log.Printf("request from %s failed: %v\n", nc.RemoteAddr(), err)
+			return
+		}
+
+		reply := s.exec(c, req)
+		if req.moreToCome {
+			continue
+		}
+		c.requestID++
+		if err := c.wc.writeReply(req, c.requestID, reply); err != nil {
+			return
+		}
+	}
+}
+
+// exec 执行命令并返回编码后的回复 命令失败时回复 {ok: 0, errmsg, code, codeName}
+func (s *Server) exec(c *conn, req *request) []byte {
+	if s.conf.Latency > 0 {
+		time.Sleep(s.conf.Latency)
+	}
+
+	reply, err := s.run(c, req, req.name())
+	if err == nil {
+		var b []byte
+		if b, err = bson.Marshal(append(reply, bson.E{Key: "ok", Value: 1.0})); err == nil {
+			return b
+		}
+	}
+
+	var ce *commandError
+	if !errors.As(err, &ce) {
+		ce = badValue("%v", err)
+	}
+	b, _ := bson.Marshal(bson.D{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: ce.msg},
+		{Key: "code", Value: ce.code},
+		{Key: "codeName", Value: ce.name},
+	})
+	return b
+}
+
+func (s *Server) run(c *conn, req *request, name string) (bson.D, error) {
+	fn, ok := commands[name]
+	if !ok {
+		return nil, &commandError{code: 59, name: "CommandNotFound", msg: fmt.Sprintf("no such command: '%s'", name)}
+	}
+	if req.db == "" {
+		return nil, badValue("OP_MSG requests require a $db argument")
+	}
+	return fn(s, c, req)
+}
+
+// cursor 保存查询剩余的文档 文档在查询时确定 不受后续写操作影响
+type cursor struct {
+	id   int64
+	ns   string
+	docs []bson.Raw
+}
+
+type cursors struct {
+	mu     sync.Mutex
+	open   map[int64]*cursor
+	nextID int64
+}
+
+func (cs *cursors) add(ns string, docs []bson.Raw) int64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.nextID++
+	cs.open[cs.nextID] = &cursor{id: cs.nextID, ns: ns, docs: docs}
+	return cs.nextID
+}
+
+// take 取出游标 getMore 期间其他请求无法访问该游标
+func (cs *cursors) take(id int64) (*cursor, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cur, ok := cs.open[id]
+	delete(cs.open, id)
+	return cur, ok
+}
+
+func (cs *cursors) put(cur *cursor) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.open[cur.id] = cur
+}
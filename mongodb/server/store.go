@@ -0,0 +1,482 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// store 保存所有集合 集合在首次访问时写入合成文档
+type store struct {
+	mu          sync.Mutex
+	collections map[string]*collection
+	seed        func() *collection
+}
+
+// collection 按写入顺序保存文档 ids 用于检查 _id 是否重复
+type collection struct {
+	docs []bson.Raw
+	ids  map[string]struct{}
+}
+
+func newStore(seed func() *collection) *store {
+	return &store{
+		collections: make(map[string]*collection),
+		seed:        seed,
+	}
+}
+
+// collection 返回 ns 对应的集合 调用方需持有锁
+func (s *store) collection(ns string) *collection {
+	coll, ok := s.collections[ns]
+	if !ok {
+		coll = s.seed()
+		s.collections[ns] = coll
+	}
+	return coll
+}
+
+// seedCollection 返回包含 n 个合成文档的集合 每个文档的大小约为 size 字节
+//
+//	{_id: 0, seq: 0, payload: "xxx..."}
+func seedCollection(n, size int) *collection {
+	empty, _ := bson.Marshal(bson.D{{Key: "_id", Value: int64(0)}, {Key: "seq", Value: int64(0)}, {Key: "payload", Value: ""}})
+	payload := strings.Repeat("x", max(size-len(empty), 0))
+
+	coll := &collection{
+		docs: make([]bson.Raw, 0, n),
+		ids:  make(map[string]struct{}, n),
+	}
+	for i := 0; i < n; i++ {
+		doc, _ := bson.Marshal(bson.D{{Key: "_id", Value: int64(i)}, {Key: "seq", Value: int64(i)}, {Key: "payload", Value: payload}})
+		coll.add(doc)
+	}
+	return coll
+}
+
+func idKey(v bson.RawValue) string {
+	return string(rune(v.Type)) + string(v.Value)
+}
+
+func (coll *collection) add(doc bson.Raw) {
+	coll.docs = append(coll.docs, doc)
+	coll.ids[idKey(doc.Lookup("_id"))] = struct{}{}
+}
+
+// insert 写入文档 缺少 _id 时生成 ObjectId
+func (coll *collection) insert(doc bson.Raw) error {
+	id, err := doc.LookupErr("_id")
+	if err != nil {
+		var d bson.D
+		if err := bson.Unmarshal(doc, &d); err != nil {
+			return err
+		}
+		oid := primitive.NewObjectID()
+		if doc, err = bson.Marshal(append(bson.D{{Key: "_id", Value: oid}}, d...)); err != nil {
+			return err
+		}
+		id = doc.Lookup("_id")
+	}
+	if _, ok := coll.ids[idKey(id)]; ok {
+		return &commandError{code: 11000, name: "DuplicateKey", msg: fmt.Sprintf("E11000 duplicate key error dup key: { _id: %s }", id)}
+	}
+	coll.add(doc)
+	return nil
+}
+
+// find 返回匹配 filter 的文档 跳过前 skip 个 limit 大于 0 时最多返回 limit 个
+func (coll *collection) find(filter bson.Raw, skip, limit int) ([]bson.Raw, error) {
+	var docs []bson.Raw
+	for _, doc := range coll.docs {
+		ok, err := match(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		docs = append(docs, doc)
+		if limit > 0 && len(docs) >= limit {
+			break
+		}
+	}
+	return docs, nil
+}
+
+// remove 删除匹配 filter 的文档 limit 大于 0 时最多删除 limit 个
+func (coll *collection) remove(filter bson.Raw, limit int) (int, error) {
+	var n int
+	docs := coll.docs[:0]
+	for _, doc := range coll.docs {
+		if limit <= 0 || n < limit {
+			ok, err := match(doc, filter)
+			if err != nil {
+				return n, err
+			}
+			if ok {
+				n++
+				delete(coll.ids, idKey(doc.Lookup("_id")))
+				continue
+			}
+		}
+		docs = append(docs, doc)
+	}
+	clear(coll.docs[len(docs):])
+	coll.docs = docs
+	return n, nil
+}
+
+// update 修改匹配 filter 的文档 返回匹配以及实际修改的个数
+func (coll *collection) update(filter, u bson.Raw, multi bool) (int, int, error) {
+	var matched, modified int
+	for i, doc := range coll.docs {
+		ok, err := match(doc, filter)
+		if err != nil {
+			return matched, modified, err
+		}
+		if !ok {
+			continue
+		}
+
+		matched++
+		updated, err := applyUpdate(doc, u)
+		if err != nil {
+			return matched, modified, err
+		}
+		if !updated.Lookup("_id").Equal(doc.Lookup("_id")) {
+			return matched, modified, &commandError{code: 66, name: "ImmutableField", msg: "Performing an update on the path '_id' would modify the immutable field '_id'"}
+		}
+		if string(updated) != string(doc) {
+			coll.docs[i] = updated
+			modified++
+		}
+		if !multi {
+			break
+		}
+	}
+	return matched, modified, nil
+}
+
+// upsert 在没有匹配的文档时以 filter 中的等值条件为基础写入新文档 返回新文档的 _id
+func (coll *collection) upsert(filter, u bson.Raw) (bson.RawValue, error) {
+	var base bson.D
+	elems, err := filter.Elements()
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	for _, elem := range elems {
+		key, v := elem.Key(), elem.Value()
+		if strings.HasPrefix(key, "$") || strings.Contains(key, ".") || isOperatorDoc(v) {
+			continue
+		}
+		base = append(base, bson.E{Key: key, Value: v})
+	}
+
+	doc, err := bson.Marshal(base)
+	if err != nil {
+		return bson.RawValue{}, err
+	}
+	if doc, err = applyUpdate(doc, u); err != nil {
+		return bson.RawValue{}, err
+	}
+	if err := coll.insert(doc); err != nil {
+		return bson.RawValue{}, err
+	}
+	return coll.docs[len(coll.docs)-1].Lookup("_id"), nil
+}
+
+// applyUpdate 返回修改后的文档 u 为替换文档或者由 $set $unset $inc 组成的更新
+func applyUpdate(doc, u bson.Raw) (bson.Raw, error) {
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	elems, err := u.Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(elems) == 0 || !strings.HasPrefix(elems[0].Key(), "$") {
+		// 替换文档保留原有的 _id
+		replaced := bson.D{}
+		for _, e := range d {
+			if e.Key == "_id" {
+				replaced = append(replaced, e)
+			}
+		}
+		for _, elem := range elems {
+			if elem.Key() != "_id" || len(replaced) == 0 {
+				replaced = append(replaced, bson.E{Key: elem.Key(), Value: elem.Value()})
+			}
+		}
+		return bson.Marshal(replaced)
+	}
+
+	for _, elem := range elems {
+		fields, ok := elem.Value().DocumentOK()
+		if !ok {
+			return nil, &commandError{code: 9, name: "FailedToParse", msg: fmt.Sprintf("Modifiers operate on fields but we found type %s instead", elem.Value().Type)}
+		}
+		values, err := fields.Elements()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range values {
+			key, v := f.Key(), f.Value()
+			switch elem.Key() {
+			case "$set":
+				d = setField(d, key, v)
+			case "$unset":
+				d = unsetField(d, key)
+			case "$inc":
+				cur, _ := doc.LookupErr(key)
+				sum, err := increment(cur, v)
+				if err != nil {
+					return nil, err
+				}
+				d = setField(d, key, sum)
+			default:
+				return nil, &commandError{code: 9, name: "FailedToParse", msg: fmt.Sprintf("Unknown modifier: %s", elem.Key())}
+			}
+		}
+	}
+	return bson.Marshal(d)
+}
+
+func setField(d bson.D, key string, v any) bson.D {
+	for i := range d {
+		if d[i].Key == key {
+			d[i].Value = v
+			return d
+		}
+	}
+	return append(d, bson.E{Key: key, Value: v})
+}
+
+func unsetField(d bson.D, key string) bson.D {
+	for i := range d {
+		if d[i].Key == key {
+			return append(d[:i], d[i+1:]...)
+		}
+	}
+	return d
+}
+
+// increment 返回 $inc 的结果 两个整数相加仍为整数 否则为浮点数
+func increment(cur, delta bson.RawValue) (any, error) {
+	if cur.Type == 0 {
+		cur = bson.RawValue{Type: bsontype.Int32, Value: []byte{0, 0, 0, 0}}
+	}
+	a, aok := cur.AsInt64OK()
+	b, bok := delta.AsInt64OK()
+	if aok && bok && cur.Type != bsontype.Double && delta.Type != bsontype.Double {
+		return a + b, nil
+	}
+
+	x, xok := number(cur)
+	y, yok := number(delta)
+	if !xok || !yok {
+		return nil, &commandError{code: 14, name: "TypeMismatch", msg: "Cannot apply $inc to a value of non-numeric type"}
+	}
+	return x + y, nil
+}
+
+func isOperatorDoc(v bson.RawValue) bool {
+	doc, ok := v.DocumentOK()
+	if !ok {
+		return false
+	}
+	elem, err := doc.IndexErr(0)
+	return err == nil && strings.HasPrefix(elem.Key(), "$")
+}
+
+// match 判断文档是否匹配 filter 支持等值条件 $and $or 以及常用的比较操作符
+func match(doc, filter bson.Raw) (bool, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return false, err
+	}
+	for _, elem := range elems {
+		key, want := elem.Key(), elem.Value()
+		var ok bool
+		switch key {
+		case "$and", "$or":
+			ok, err = matchLogical(doc, key, want)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, &commandError{code: 2, name: "BadValue", msg: "unknown top level operator: " + key}
+			}
+			got, _ := doc.LookupErr(strings.Split(key, ".")...)
+			if isOperatorDoc(want) {
+				ok, err = matchOperators(got, want.Document())
+			} else {
+				ok = equal(got, want)
+			}
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc bson.Raw, op string, v bson.RawValue) (bool, error) {
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return false, &commandError{code: 2, name: "BadValue", msg: op + " must be an array"}
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		sub, ok := v.DocumentOK()
+		if !ok {
+			return false, &commandError{code: 2, name: "BadValue", msg: op + " argument's entries must be objects"}
+		}
+		matched, err := match(doc, sub)
+		if err != nil {
+			return false, err
+		}
+		if op == "$or" && matched {
+			return true, nil
+		}
+		if op == "$and" && !matched {
+			return false, nil
+		}
+	}
+	return op == "$and", nil
+}
+
+func matchOperators(got bson.RawValue, ops bson.Raw) (bool, error) {
+	elems, err := ops.Elements()
+	if err != nil {
+		return false, err
+	}
+	for _, elem := range elems {
+		want := elem.Value()
+		var ok bool
+		switch op := elem.Key(); op {
+		case "$eq":
+			ok = equal(got, want)
+		case "$ne":
+			ok = !equal(got, want)
+		case "$gt", "$gte", "$lt", "$lte":
+			c, comparable := compare(got, want)
+			switch op {
+			case "$gt":
+				ok = comparable && c > 0
+			case "$gte":
+				ok = comparable && c >= 0
+			case "$lt":
+				ok = comparable && c < 0
+			case "$lte":
+				ok = comparable && c <= 0
+			}
+		case "$in", "$nin":
+			arr, isArray := want.ArrayOK()
+			if !isArray {
+				return false, &commandError{code: 2, name: "BadValue", msg: op + " needs an array"}
+			}
+			values, err := arr.Values()
+			if err != nil {
+				return false, err
+			}
+			for _, v := range values {
+				if equal(got, v) {
+					ok = true
+					break
+				}
+			}
+			if op == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = (got.Type != 0) == truthy(want)
+		default:
+			return false, &commandError{code: 2, name: "BadValue", msg: "unknown operator: " + op}
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// equal 判断两个值是否相等 不同类型的数值按大小比较 缺失的字段等于 null
+func equal(a, b bson.RawValue) bool {
+	if a.Type == 0 {
+		return b.Type == bsontype.Null
+	}
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return a.Equal(b)
+}
+
+// compare 比较数值或者字符串 其他类型仅在字节相同时视为相等
+func compare(a, b bson.RawValue) (int, bool) {
+	x, xok := number(a)
+	y, yok := number(b)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	s, sok := a.StringValueOK()
+	t, tok := b.StringValueOK()
+	if sok && tok {
+		return strings.Compare(s, t), true
+	}
+	if a.Type != 0 && a.Equal(b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// truthy 判断选项是否为真 布尔值以及非零的数值均视为真 缺失时为假
+func truthy(v bson.RawValue) bool {
+	if b, ok := v.BooleanOK(); ok {
+		return b
+	}
+	n, ok := number(v)
+	return ok && n != 0
+}
+
+func number(v bson.RawValue) (float64, bool) {
+	switch v.Type {
+	case bsontype.Int32:
+		return float64(v.Int32()), true
+	case bsontype.Int64:
+		return float64(v.Int64()), true
+	case bsontype.Double:
+		return v.Double(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,272 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// OP_MSG 的标志位
+const (
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+)
+
+const (
+	// maxBSONObjectSize 为单个文档的最大长度 同时也是单批次文档的最大总长度
+	maxBSONObjectSize = 16 * 1024 * 1024
+
+	// maxMessageSize 与 mongod 通告的 maxMessageSizeBytes 一致
+	maxMessageSize = 48000000
+)
+
+var errMalformed = errors.New("malformed message")
+
+// header 为消息头 所有字段均为小端序
+type header struct {
+	length     int32
+	requestID  int32
+	responseTo int32
+	opCode     int32
+}
+
+// request 为解析后的命令 kind 1 的文档序列合并至 body 中同名的数组字段
+type request struct {
+	header header
+	db     string
+	body   bson.Raw
+
+	// sequences 为 OP_MSG 中 kind 1 的文档序列 如 insert 的 documents
+	sequences map[string][]bson.Raw
+
+	// moreToCome 为 true 时客户端不等待回复 如 w:0 的写操作
+	moreToCome bool
+}
+
+// name 返回命令名 即 body 的第一个字段
+func (r *request) name() string {
+	elem, err := r.body.IndexErr(0)
+	if err != nil {
+		return ""
+	}
+	return elem.Key()
+}
+
+// documents 返回命令中的文档数组 优先使用文档序列
+func (r *request) documents(key string) ([]bson.Raw, error) {
+	if docs, ok := r.sequences[key]; ok {
+		return docs, nil
+	}
+	v, err := r.body.LookupErr(key)
+	if err != nil {
+		return nil, nil
+	}
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", key)
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]bson.Raw, 0, len(values))
+	for _, v := range values {
+		doc, ok := v.DocumentOK()
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of documents", key)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+type wireConn struct {
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+func (wc *wireConn) readMessage() (header, []byte, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(wc.br, b[:]); err != nil {
+		return header{}, nil, err
+	}
+	h := header{
+		length:     int32(binary.LittleEndian.Uint32(b[0:])),
+		requestID:  int32(binary.LittleEndian.Uint32(b[4:])),
+		responseTo: int32(binary.LittleEndian.Uint32(b[8:])),
+		opCode:     int32(binary.LittleEndian.Uint32(b[12:])),
+	}
+	if h.length < 16 || h.length > maxMessageSize {
+		return h, nil, fmt.Errorf("invalid message length %d", h.length)
+	}
+
+	body := make([]byte, h.length-16)
+	if _, err := io.ReadFull(wc.br, body); err != nil {
+		return h, nil, err
+	}
+	return h, body, nil
+}
+
+// parseMsg 解析 OP_MSG 包含一个 kind 0 的 body 以及任意个 kind 1 的文档序列
+func parseMsg(h header, b []byte) (*request, error) {
+	if len(b) < 4 {
+		return nil, errMalformed
+	}
+	flags := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	if flags&flagChecksumPresent != 0 {
+		if len(b) < 4 {
+			return nil, errMalformed
+		}
+		b = b[:len(b)-4]
+	}
+
+	req := &request{header: h, moreToCome: flags&flagMoreToCome != 0}
+	for len(b) > 0 {
+		kind := b[0]
+		b = b[1:]
+		switch kind {
+		case 0:
+			doc, rest, err := readDocument(b)
+			if err != nil {
+				return nil, err
+			}
+			req.body, b = doc, rest
+		case 1:
+			if len(b) < 4 {
+				return nil, errMalformed
+			}
+			size := int(binary.LittleEndian.Uint32(b))
+			if size < 4 || size > len(b) {
+				return nil, errMalformed
+			}
+			seq := b[4:size]
+			b = b[size:]
+
+			i := strings.IndexByte(string(seq), 0)
+			if i < 0 {
+				return nil, errMalformed
+			}
+			id := string(seq[:i])
+			seq = seq[i+1:]
+			if req.sequences == nil {
+				req.sequences = make(map[string][]bson.Raw)
+			}
+			for len(seq) > 0 {
+				doc, rest, err := readDocument(seq)
+				if err != nil {
+					return nil, err
+				}
+				req.sequences[id] = append(req.sequences[id], doc)
+				seq = rest
+			}
+		default:
+			return nil, fmt.Errorf("unknown section kind %d", kind)
+		}
+	}
+	if req.body == nil {
+		return nil, errors.New("OP_MSG without body section")
+	}
+	req.db = req.body.Lookup("$db").StringValue()
+	return req, nil
+}
+
+// parseQuery 解析 OP_QUERY 仅用于驱动首次握手时发送的 `db.$cmd` 命令
+func parseQuery(h header, b []byte) (*request, error) {
+	if len(b) < 4 {
+		return nil, errMalformed
+	}
+	b = b[4:]
+	i := strings.IndexByte(string(b), 0)
+	if i < 0 {
+		return nil, errMalformed
+	}
+	ns := string(b[:i])
+	b = b[i+1:]
+	if len(b) < 8 {
+		return nil, errMalformed
+	}
+	doc, _, err := readDocument(b[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	db, coll, _ := strings.Cut(ns, ".")
+	if coll != "$cmd" {
+		return nil, fmt.Errorf("OP_QUERY on %s is not supported", ns)
+	}
+	// 命令可能被包装为 {$query: {...}, $readPreference: {...}}
+	if q, ok := doc.Lookup("$query").DocumentOK(); ok {
+		doc = q
+	}
+	return &request{header: h, db: db, body: doc}, nil
+}
+
+func readDocument(b []byte) (bson.Raw, []byte, error) {
+	if len(b) < 5 {
+		return nil, nil, errMalformed
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size < 5 || size > len(b) {
+		return nil, nil, errMalformed
+	}
+	return bson.Raw(b[:size]), b[size:], nil
+}
+
+// writeReply 根据请求的类型使用 OP_MSG 或者 OP_REPLY 回复
+func (wc *wireConn) writeReply(req *request, requestID int32, doc []byte) error {
+	var b []byte
+	opCode := int32(opMsg)
+	if req.header.opCode == opQuery {
+		// responseFlags cursorID startingFrom numberReturned
+		opCode = opReply
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint32(b, 1)
+	} else {
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = append(b, 0)
+	}
+
+	var h [16]byte
+	binary.LittleEndian.PutUint32(h[0:], uint32(16+len(b)+len(doc)))
+	binary.LittleEndian.PutUint32(h[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(h[8:], uint32(req.header.requestID))
+	binary.LittleEndian.PutUint32(h[12:], uint32(opCode))
+	if _, err := wc.bw.Write(h[:]); err != nil {
+		return err
+	}
+	if _, err := wc.bw.Write(b); err != nil {
+		return err
+	}
+	if _, err := wc.bw.Write(doc); err != nil {
+		return err
+	}
+	return wc.bw.Flush()
+}
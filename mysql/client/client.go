@@ -0,0 +1,119 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	DSN      string
+	Workers  int
+	Total    int
+	SQL      string
+	Interval time.Duration
+}
+
+type Client struct {
+	conf Config
+	db   *sql.DB
+}
+
+func New(conf Config) *Client {
+	return &Client{
+		conf: conf,
+	}
+}
+
+func (c *Client) Setup() error {
+	db, err := sql.Open("mysql", c.conf.DSN)
+	if err != nil {
+		return err
+	}
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(c.conf.Workers)
+	db.SetMaxIdleConns(c.conf.Workers)
+
+	c.db = db
+	return nil
+}
+
+func (c *Client) Do(int) error {
+	r, err := c.db.Query(c.conf.SQL)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer r.Close()
+
+	for r.Next() {
+	}
+	return wrapError(r.Err())
+}
+
+// wrapError 将服务端返回的错误视为非预期的状态 数据包解析失败视为协议错误
+func wrapError(err error) error {
+	var merr *mysql.MySQLError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &merr):
+		return common.NewStatusError("%d", merr.Number)
+	case errors.Is(err, mysql.ErrMalformPkt), errors.Is(err, mysql.ErrPktSync), errors.Is(err, mysql.ErrPktSyncMul):
+		return common.NewProtocolError(err)
+	}
+	return err
+}
+
+func (c *Client) Teardown() error {
+	return c.db.Close()
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "sql", Value: c.conf.SQL},
+	}
+}
+
+// NewCommand 返回 `mysql client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "mysql client",
+		Usage: "benchmark a mysql server with a sql statement",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.DSN, "dsn", "", "mysql server dsn")
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.SQL, "sql", "", "sql statement")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "mysql"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.Interval = c.Interval
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
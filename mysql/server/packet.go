@@ -0,0 +1,171 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxPacketSize 为单个数据包的最大长度 超过时拆分为多个数据包
+const maxPacketSize = 1<<24 - 1
+
+const (
+	iOK  = 0x00
+	iEOF = 0xfe
+	iERR = 0xff
+)
+
+// 服务端状态
+const (
+	statusAutocommit = 0x0002
+)
+
+var errMalformed = errors.New("malformed packet")
+
+// packetConn 负责数据包的拆分以及序号 每个命令开始时序号重置为 0
+type packetConn struct {
+	br  *bufio.Reader
+	bw  *bufio.Writer
+	seq uint8
+}
+
+func (pc *packetConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(pc.br, header[:]); err != nil {
+			return nil, err
+		}
+		n := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		if header[3] != pc.seq {
+			return nil, fmt.Errorf("packets out of order, got %d, want %d", header[3], pc.seq)
+		}
+		pc.seq++
+
+		b := make([]byte, n)
+		if _, err := io.ReadFull(pc.br, b); err != nil {
+			return nil, err
+		}
+		payload = append(payload, b...)
+		if n < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+func (pc *packetConn) writePacket(data []byte) error {
+	for {
+		n := min(len(data), maxPacketSize)
+		header := [4]byte{byte(n), byte(n >> 8), byte(n >> 16), pc.seq}
+		pc.seq++
+		if _, err := pc.bw.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := pc.bw.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+
+		// 长度恰好为 maxPacketSize 时需要追加一个空的数据包
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+func (pc *packetConn) flush() error {
+	return pc.bw.Flush()
+}
+
+func okPacket(affectedRows uint64) []byte {
+	b := []byte{iOK}
+	b = appendLenEncInt(b, affectedRows)
+	b = appendLenEncInt(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, statusAutocommit)
+	return binary.LittleEndian.AppendUint16(b, 0)
+}
+
+func eofPacket() []byte {
+	b := []byte{iEOF, 0, 0}
+	return binary.LittleEndian.AppendUint16(b, statusAutocommit)
+}
+
+// errPacket 返回错误数据包 state 为 5 个字符的 SQLSTATE
+func errPacket(code uint16, state, msg string) []byte {
+	b := []byte{iERR}
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = append(b, '#')
+	b = append(b, state...)
+	return append(b, msg...)
+}
+
+func appendLenEncInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	b = append(b, 0xfe)
+	return binary.LittleEndian.AppendUint64(b, n)
+}
+
+func appendLenEncString(b []byte, s []byte) []byte {
+	b = appendLenEncInt(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readLenEncInt 返回长度编码的整数以及占用的字节数
+func readLenEncInt(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, errMalformed
+	}
+
+	var size int
+	switch b[0] {
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	default:
+		return uint64(b[0]), 1, nil
+	}
+	if len(b) < size {
+		return 0, 0, errMalformed
+	}
+
+	var n uint64
+	for i := size - 1; i >= 1; i-- {
+		n = n<<8 | uint64(b[i])
+	}
+	return n, size, nil
+}
+
+// readNulString 返回以 0x00 结尾的字符串以及占用的字节数
+func readNulString(b []byte) (string, int, error) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), i + 1, nil
+		}
+	}
+	return "", 0, errMalformed
+}
@@ -0,0 +1,314 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+const (
+	typeVarString = 0xfd
+
+	// tableName 为合成结果集的表名 列名为 c1 c2 ...
+	tableName = "bench"
+)
+
+// hintPattern 匹配查询语句中的 `key=value` 可以位于注释或者 WHERE 条件中
+var hintPattern = regexp.MustCompile(`(?i)\b(rows|columns|cell_size|error|latency)\s*=\s*'?([0-9a-z.]+)`)
+
+// resultSpec 描述查询语句对应的合成结果
+type resultSpec struct {
+	// resultSet 为 false 时回复 OK 数据包 affected rows 为 rows
+	resultSet bool
+	rows      int
+	columns   int
+	cellSize  int
+
+	// errCode 大于 0 时回复该错误码
+	errCode uint16
+	latency time.Duration
+}
+
+// parseQuery 根据查询语句生成合成结果的描述 未指定的部分使用命令行参数
+//
+//	SELECT /* rows=100 columns=4 cell_size=1KB */ * FROM bench
+//	SELECT * FROM bench WHERE rows=10 AND latency=5ms
+//	UPDATE bench SET v=1 WHERE rows=3
+//	SELECT /* error=1146 */ 1
+func (s *Server) parseQuery(q string) (resultSpec, error) {
+	spec := resultSpec{
+		resultSet: isResultSet(q),
+		rows:      s.conf.Rows,
+		columns:   s.conf.Columns,
+		cellSize:  s.cellSize,
+		latency:   s.conf.Latency,
+	}
+	if !strings.ContainsRune(q, '=') {
+		return spec, nil
+	}
+
+	for _, m := range hintPattern.FindAllStringSubmatch(q, -1) {
+		key, value := strings.ToLower(m[1]), m[2]
+		var err error
+		switch key {
+		case "rows":
+			spec.rows, err = strconv.Atoi(value)
+		case "columns":
+			spec.columns, err = strconv.Atoi(value)
+			if err == nil && spec.columns <= 0 {
+				err = fmt.Errorf("columns must be positive")
+			}
+		case "cell_size":
+			spec.cellSize, err = common.ParseBytes(value)
+		case "error":
+			var code uint64
+			code, err = strconv.ParseUint(value, 10, 16)
+			spec.errCode = uint16(code)
+		case "latency":
+			spec.latency, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return spec, fmt.Errorf("invalid %s=%s: %w", key, value, err)
+		}
+	}
+	return spec, nil
+}
+
+// firstKeyword 返回语句的第一个关键字 忽略开头的空白 注释以及括号
+func firstKeyword(q string) string {
+	for {
+		q = strings.TrimLeft(q, " \t\r\n(")
+		if !strings.HasPrefix(q, "/*") {
+			break
+		}
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return ""
+		}
+		q = q[end+2:]
+	}
+
+	if i := strings.IndexAny(q, " \t\r\n(;"); i >= 0 {
+		q = q[:i]
+	}
+	return strings.ToLower(q)
+}
+
+// isResultSet 判断语句是否返回结果集
+func isResultSet(q string) bool {
+	switch firstKeyword(q) {
+	case "select", "show", "describe", "desc", "explain", "with", "values", "table":
+		return true
+	}
+	return false
+}
+
+// isDML 判断语句是否修改数据 此时 affected rows 为 rows
+func isDML(q string) bool {
+	switch firstKeyword(q) {
+	case "insert", "update", "delete", "replace":
+		return true
+	}
+	return false
+}
+
+func (c *conn) query(q string) error {
+	spec, err := c.s.parseQuery(q)
+	if err != nil {
+		return c.pc.writePacket(errPacket(1064, "42000", err.Error()))
+	}
+	if spec.latency > 0 {
+		time.Sleep(spec.latency)
+	}
+	if spec.errCode > 0 {
+		return c.pc.writePacket(syntheticError(spec.errCode))
+	}
+	if !spec.resultSet {
+		return c.pc.writePacket(okPacket(affectedRows(q, spec)))
+	}
+	return c.writeResultSet(spec, false)
+}
+
+func syntheticError(code uint16) []byte {
+	return errPacket(code, "HY000", fmt.Sprintf("synthetic error %d", code))
+}
+
+func affectedRows(q string, spec resultSpec) uint64 {
+	if isDML(q) {
+		return uint64(spec.rows)
+	}
+	return 0
+}
+
+// writeResultSet 写入合成的结果集 binary 为 true 时使用 COM_STMT_EXECUTE 的二进制行格式
+func (c *conn) writeResultSet(spec resultSpec, binaryRow bool) error {
+	if err := c.pc.writePacket(appendLenEncInt(nil, uint64(spec.columns))); err != nil {
+		return err
+	}
+	if err := c.writeColumns(spec); err != nil {
+		return err
+	}
+
+	cell := bytes.Repeat([]byte{'x'}, spec.cellSize)
+	var row []byte
+	if binaryRow {
+		// 二进制行以 0x00 开头 NULL 位图的长度为 (columns + 7 + 2) / 8
+		row = append(row, iOK)
+		row = append(row, make([]byte, (spec.columns+7+2)/8)...)
+	}
+	for i := 0; i < spec.columns; i++ {
+		row = appendLenEncString(row, cell)
+	}
+	for i := 0; i < spec.rows; i++ {
+		if err := c.pc.writePacket(row); err != nil {
+			return err
+		}
+	}
+	return c.pc.writePacket(eofPacket())
+}
+
+func (c *conn) writeColumns(spec resultSpec) error {
+	for i := 0; i < spec.columns; i++ {
+		name := "c" + strconv.Itoa(i+1)
+		if err := c.pc.writePacket(columnDefinition(c.db, name, uint32(spec.cellSize))); err != nil {
+			return err
+		}
+	}
+	return c.pc.writePacket(eofPacket())
+}
+
+// columnDefinition 返回 ColumnDefinition41 所有列均为 VARCHAR
+func columnDefinition(schema, name string, length uint32) []byte {
+	b := appendLenEncString(nil, []byte("def"))
+	b = appendLenEncString(b, []byte(schema))
+	b = appendLenEncString(b, []byte(tableName))
+	b = appendLenEncString(b, []byte(tableName))
+	b = appendLenEncString(b, []byte(name))
+	b = appendLenEncString(b, []byte(name))
+	b = append(b, 0x0c)
+	b = binary.LittleEndian.AppendUint16(b, charsetUTF8MB4)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	b = append(b, typeVarString)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	b = append(b, 0, 0, 0)
+	return b
+}
+
+// stmt 为预处理语句 合成结果在 prepare 时确定
+type stmt struct {
+	query  string
+	params int
+	spec   resultSpec
+}
+
+func (c *conn) prepare(q string) error {
+	spec, err := c.s.parseQuery(q)
+	if err != nil {
+		return c.pc.writePacket(errPacket(1064, "42000", err.Error()))
+	}
+
+	c.stmtID++
+	st := &stmt{query: q, params: countParams(q), spec: spec}
+	c.stmts[c.stmtID] = st
+
+	columns := 0
+	if spec.resultSet {
+		columns = spec.columns
+	}
+	b := []byte{iOK}
+	b = binary.LittleEndian.AppendUint32(b, c.stmtID)
+	b = binary.LittleEndian.AppendUint16(b, uint16(columns))
+	b = binary.LittleEndian.AppendUint16(b, uint16(st.params))
+	b = append(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, 0)
+	if err := c.pc.writePacket(b); err != nil {
+		return err
+	}
+
+	if st.params > 0 {
+		for i := 0; i < st.params; i++ {
+			if err := c.pc.writePacket(columnDefinition("", "?", 0)); err != nil {
+				return err
+			}
+		}
+		if err := c.pc.writePacket(eofPacket()); err != nil {
+			return err
+		}
+	}
+	if columns > 0 {
+		return c.writeColumns(spec)
+	}
+	return nil
+}
+
+// execute 处理 COM_STMT_EXECUTE 合成结果与参数无关 因此不解析参数
+func (c *conn) execute(data []byte) error {
+	if len(data) < 4 {
+		return c.pc.writePacket(errPacket(1210, "HY000", "Incorrect arguments to mysqld_stmt_execute"))
+	}
+	id := binary.LittleEndian.Uint32(data)
+	st, ok := c.stmts[id]
+	if !ok {
+		msg := fmt.Sprintf("Unknown prepared statement handler (%d) given to mysqld_stmt_execute", id)
+		return c.pc.writePacket(errPacket(1243, "HY000", msg))
+	}
+
+	spec := st.spec
+	if spec.latency > 0 {
+		time.Sleep(spec.latency)
+	}
+	if spec.errCode > 0 {
+		return c.pc.writePacket(syntheticError(spec.errCode))
+	}
+	if !spec.resultSet {
+		return c.pc.writePacket(okPacket(affectedRows(st.query, spec)))
+	}
+	return c.writeResultSet(spec, true)
+}
+
+// countParams 返回语句中占位符 `?` 的个数 忽略引号以及注释中的 `?`
+func countParams(q string) int {
+	var n int
+	var quote byte
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '/' && i+1 < len(q) && q[i+1] == '*':
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return n
+			}
+			i += end + 3
+		case ch == '?':
+			n++
+		}
+	}
+	return n
+}
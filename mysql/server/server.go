@@ -0,0 +1,421 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+const (
+	serverVersion  = "8.0.36-packetd-bench"
+	nativePassword = "mysql_native_password"
+
+	// charsetUTF8MB4 为 utf8mb4_general_ci
+	charsetUTF8MB4 = 45
+)
+
+// 客户端以及服务端的能力标志
+const (
+	clientLongPassword               = 0x00000001
+	clientFoundRows                  = 0x00000002
+	clientLongFlag                   = 0x00000004
+	clientConnectWithDB              = 0x00000008
+	clientProtocol41                 = 0x00000200
+	clientSSL                        = 0x00000800
+	clientTransactions               = 0x00002000
+	clientSecureConnection           = 0x00008000
+	clientMultiStatements            = 0x00010000
+	clientMultiResults               = 0x00020000
+	clientPluginAuth                 = 0x00080000
+	clientPluginAuthLenEncClientData = 0x00200000
+
+	serverCapabilities = clientLongPassword | clientFoundRows | clientLongFlag | clientConnectWithDB |
+		clientProtocol41 | clientTransactions | clientSecureConnection | clientMultiStatements |
+		clientMultiResults | clientPluginAuth | clientPluginAuthLenEncClientData
+)
+
+// 命令类型
+const (
+	comQuit        = 0x01
+	comInitDB      = 0x02
+	comQuery       = 0x03
+	comPing        = 0x0e
+	comStmtPrepare = 0x16
+	comStmtExecute = 0x17
+	comStmtClose   = 0x19
+	comStmtReset   = 0x1a
+)
+
+type Config struct {
+	Addr string
+
+	// User 以及 Password 为空时接受任意用户 否则使用 mysql_native_password 校验
+	User     string
+	Password string
+
+	// Rows Columns 以及 CellSize 为结果集的默认大小 可以在查询语句中覆盖 详见 parseQuery
+	Rows     int
+	Columns  int
+	CellSize string
+
+	// Latency 为每条查询回复前的等待时间
+	Latency time.Duration
+}
+
+// Server 为 mysql 的替身 根据查询语句返回合成的结果集 不保存任何数据
+type Server struct {
+	conf     Config
+	cellSize int
+	ids      atomic.Uint32
+}
+
+func New(conf Config) (*Server, error) {
+	size, err := common.ParseBytes(conf.CellSize)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Rows < 0 || conf.Columns <= 0 {
+		return nil, errors.New("rows must not be negative and columns must be positive")
+	}
+	return &Server{conf: conf, cellSize: size}, nil
+}
+
+// NewCommand 返回 `mysql server` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:  "mysql server",
+		Usage: "serve the mysql wire protocol with synthetic result sets",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:3306", "mysql server address")
+			fs.StringVar(&c.User, "user", "", "accepted user, empty means any user")
+			fs.StringVar(&c.Password, "password", "", "password checked with mysql_native_password, empty means no check")
+			fs.IntVar(&c.Rows, "rows", 1, "default rows of result sets")
+			fs.IntVar(&c.Columns, "columns", 1, "default columns of result sets")
+			fs.StringVar(&c.CellSize, "cell_size", "16B", "default size of each cell in result sets")
+			fs.DurationVar(&c.Latency, "latency", 0, "delay before replying each query")
+		},
+		Run: func([]string) error {
+			s, err := New(c)
+			if err != nil {
+				return err
+			}
+			return s.Serve()
+		},
+	}
+}
+
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(c)
+	}
+}
+
+type conn struct {
+	s      *Server
+	pc     packetConn
+	id     uint32
+	db     string
+	stmts  map[uint32]*stmt
+	stmtID uint32
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+
+	c := &conn{
+		s:     s,
+		pc:    packetConn{br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)},
+		id:    s.ids.Add(1),
+		stmts: make(map[uint32]*stmt),
+	}
+	if err := c.handshake(nc.RemoteAddr()); err != nil {
+		if !errors.Is(err, io.EOF) {
+			log.Printf("handshake with %s failed: %v\n", nc.RemoteAddr(), err)
+		}
+		return
+	}
+
+	for {
+		c.pc.seq = 0
+		data, err := c.pc.readPacket()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(data) == 0 || data[0] == comQuit {
+			return
+		}
+		if err := c.dispatch(data[0], data[1:]); err != nil {
+			return
+		}
+		if err := c.pc.flush(); err != nil {
+			return
+		}
+	}
+}
+
+// handshake 发送 HandshakeV10 并校验客户端的 HandshakeResponse41
+func (c *conn) handshake(addr net.Addr) error {
+	scramble := newScramble()
+	b := []byte{10}
+	b = append(b, serverVersion...)
+	b = append(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, c.id)
+	b = append(b, scramble[:8]...)
+	b = append(b, 0)
+	b = binary.LittleEndian.AppendUint16(b, uint16(serverCapabilities&0xffff))
+	b = append(b, charsetUTF8MB4)
+	b = binary.LittleEndian.AppendUint16(b, statusAutocommit)
+	b = binary.LittleEndian.AppendUint16(b, uint16(serverCapabilities>>16))
+	b = append(b, byte(len(scramble)+1))
+	b = append(b, make([]byte, 10)...)
+	b = append(b, scramble[8:]...)
+	b = append(b, 0)
+	b = append(b, nativePassword...)
+	b = append(b, 0)
+	if err := c.pc.writePacket(b); err != nil {
+		return err
+	}
+	if err := c.pc.flush(); err != nil {
+		return err
+	}
+
+	data, err := c.pc.readPacket()
+	if err != nil {
+		return err
+	}
+	resp, err := parseHandshakeResponse(data)
+	if err != nil {
+		c.pc.writePacket(errPacket(1043, "08S01", "Bad handshake"))
+		c.pc.flush()
+		return err
+	}
+	if resp.flags&clientSSL != 0 {
+		c.pc.writePacket(errPacket(1043, "08S01", "SSL is not supported"))
+		c.pc.flush()
+		return errors.New("client requested ssl")
+	}
+
+	if err := c.authenticate(resp, scramble, addr); err != nil {
+		c.pc.flush()
+		return err
+	}
+	c.db = resp.db
+	if err := c.pc.writePacket(okPacket(0)); err != nil {
+		return err
+	}
+	return c.pc.flush()
+}
+
+// authenticate 校验用户名以及密码 客户端使用其他认证插件时切换为 mysql_native_password
+func (c *conn) authenticate(resp *handshakeResponse, scramble []byte, addr net.Addr) error {
+	conf := c.s.conf
+	if conf.User == "" && conf.Password == "" {
+		return nil
+	}
+
+	auth := resp.auth
+	if conf.Password != "" && resp.plugin != nativePassword {
+		b := []byte{iEOF}
+		b = append(b, nativePassword...)
+		b = append(b, 0)
+		b = append(b, scramble...)
+		b = append(b, 0)
+		if err := c.pc.writePacket(b); err != nil {
+			return err
+		}
+		if err := c.pc.flush(); err != nil {
+			return err
+		}
+		data, err := c.pc.readPacket()
+		if err != nil {
+			return err
+		}
+		auth = data
+	}
+
+	if (conf.User == "" || resp.user == conf.User) && bytes.Equal(auth, scramblePassword(scramble, conf.Password)) {
+		return nil
+	}
+
+	host, _, _ := net.SplitHostPort(addr.String())
+	using := "NO"
+	if len(auth) > 0 {
+		using = "YES"
+	}
+	msg := fmt.Sprintf("Access denied for user '%s'@'%s' (using password: %s)", resp.user, host, using)
+	c.pc.writePacket(errPacket(1045, "28000", msg))
+	return errors.New(msg)
+}
+
+type handshakeResponse struct {
+	flags  uint32
+	user   string
+	auth   []byte
+	db     string
+	plugin string
+}
+
+func parseHandshakeResponse(b []byte) (*handshakeResponse, error) {
+	// capability flags [4] max packet size [4] charset [1] reserved [23]
+	if len(b) < 32 {
+		return nil, errMalformed
+	}
+	resp := &handshakeResponse{flags: binary.LittleEndian.Uint32(b)}
+	if resp.flags&clientProtocol41 == 0 {
+		return nil, errors.New("client does not support protocol 41")
+	}
+	if resp.flags&clientSSL != 0 {
+		return resp, nil
+	}
+
+	pos := 32
+	user, n, err := readNulString(b[pos:])
+	if err != nil {
+		return nil, err
+	}
+	resp.user = user
+	pos += n
+
+	authLen := -1
+	switch {
+	case resp.flags&clientPluginAuthLenEncClientData != 0:
+		l, n, err := readLenEncInt(b[pos:])
+		if err != nil {
+			return nil, err
+		}
+		authLen = int(l)
+		pos += n
+	case resp.flags&clientSecureConnection != 0:
+		if pos >= len(b) {
+			return nil, errMalformed
+		}
+		authLen = int(b[pos])
+		pos++
+	}
+	if authLen < 0 {
+		auth, n, err := readNulString(b[pos:])
+		if err != nil {
+			return nil, err
+		}
+		resp.auth = []byte(auth)
+		pos += n
+	} else {
+		if pos+authLen > len(b) {
+			return nil, errMalformed
+		}
+		resp.auth = b[pos : pos+authLen]
+		pos += authLen
+	}
+
+	if resp.flags&clientConnectWithDB != 0 && pos < len(b) {
+		db, n, err := readNulString(b[pos:])
+		if err != nil {
+			return nil, err
+		}
+		resp.db = db
+		pos += n
+	}
+	if resp.flags&clientPluginAuth != 0 && pos < len(b) {
+		plugin, _, err := readNulString(b[pos:])
+		if err != nil {
+			return nil, err
+		}
+		resp.plugin = plugin
+	}
+	return resp, nil
+}
+
+// newScramble 返回 20 字节不包含 0x00 的随机数据
+func newScramble() []byte {
+	b := make([]byte, 20)
+	rand.Read(b)
+	for i := range b {
+		b[i] = b[i]%94 + 33
+	}
+	return b
+}
+
+// scramblePassword 计算 mysql_native_password 的认证数据
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+func scramblePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	token := h.Sum(nil)
+	for i := range token {
+		token[i] ^= stage1[i]
+	}
+	return token
+}
+
+func (c *conn) dispatch(cmd byte, data []byte) error {
+	switch cmd {
+	case comInitDB:
+		c.db = string(data)
+		return c.pc.writePacket(okPacket(0))
+	case comPing:
+		return c.pc.writePacket(okPacket(0))
+	case comQuery:
+		return c.query(string(data))
+	case comStmtPrepare:
+		return c.prepare(string(data))
+	case comStmtExecute:
+		return c.execute(data)
+	case comStmtClose:
+		if len(data) >= 4 {
+			delete(c.stmts, binary.LittleEndian.Uint32(data))
+		}
+		return nil
+	case comStmtReset:
+		if len(data) < 4 || c.stmts[binary.LittleEndian.Uint32(data)] == nil {
+			return c.pc.writePacket(errPacket(1243, "HY000", "Unknown prepared statement handler given to mysqld_stmt_reset"))
+		}
+		return c.pc.writePacket(okPacket(0))
+	}
+	return c.pc.writePacket(errPacket(1047, "08S01", "Unknown command"))
+}
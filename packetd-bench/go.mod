@@ -0,0 +1,101 @@
+module github.com/packetd/packetd-benchmark/packetd-bench
+
+go 1.24.4
+
+replace (
+	github.com/packetd/packetd-benchmark/amqp/broker v0.0.0 => ./../amqp/broker
+	github.com/packetd/packetd-benchmark/amqp/consumer v0.0.0 => ./../amqp/consumer
+	github.com/packetd/packetd-benchmark/amqp/producer v0.0.0 => ./../amqp/producer
+	github.com/packetd/packetd-benchmark/common v0.0.0 => ./../common
+	github.com/packetd/packetd-benchmark/compare v0.0.0 => ./../compare
+	github.com/packetd/packetd-benchmark/grpc/client v0.0.0 => ./../grpc/client
+	github.com/packetd/packetd-benchmark/grpc/pb v0.0.0-00010101000000-000000000000 => ./../grpc/pb
+	github.com/packetd/packetd-benchmark/grpc/server v0.0.0 => ./../grpc/server
+	github.com/packetd/packetd-benchmark/http/client v0.0.0 => ./../http/client
+	github.com/packetd/packetd-benchmark/http/server v0.0.0 => ./../http/server
+	github.com/packetd/packetd-benchmark/kafka/broker v0.0.0 => ./../kafka/broker
+	github.com/packetd/packetd-benchmark/kafka/consumer v0.0.0 => ./../kafka/consumer
+	github.com/packetd/packetd-benchmark/kafka/producer v0.0.0 => ./../kafka/producer
+	github.com/packetd/packetd-benchmark/mongodb/client v0.0.0 => ./../mongodb/client
+	github.com/packetd/packetd-benchmark/mongodb/server v0.0.0 => ./../mongodb/server
+	github.com/packetd/packetd-benchmark/mysql/client v0.0.0 => ./../mysql/client
+	github.com/packetd/packetd-benchmark/mysql/server v0.0.0 => ./../mysql/server
+	github.com/packetd/packetd-benchmark/postgresql/client v0.0.0 => ./../postgresql/client
+	github.com/packetd/packetd-benchmark/postgresql/server v0.0.0 => ./../postgresql/server
+	github.com/packetd/packetd-benchmark/redis/client v0.0.0 => ./../redis/client
+	github.com/packetd/packetd-benchmark/redis/server v0.0.0 => ./../redis/server
+)
+
+require (
+	github.com/packetd/packetd-benchmark/amqp/broker v0.0.0
+	github.com/packetd/packetd-benchmark/amqp/consumer v0.0.0
+	github.com/packetd/packetd-benchmark/amqp/producer v0.0.0
+	github.com/packetd/packetd-benchmark/common v0.0.0
+	github.com/packetd/packetd-benchmark/compare v0.0.0
+	github.com/packetd/packetd-benchmark/grpc/client v0.0.0
+	github.com/packetd/packetd-benchmark/grpc/server v0.0.0
+	github.com/packetd/packetd-benchmark/http/client v0.0.0
+	github.com/packetd/packetd-benchmark/http/server v0.0.0
+	github.com/packetd/packetd-benchmark/kafka/broker v0.0.0
+	github.com/packetd/packetd-benchmark/kafka/consumer v0.0.0
+	github.com/packetd/packetd-benchmark/kafka/producer v0.0.0
+	github.com/packetd/packetd-benchmark/mongodb/client v0.0.0
+	github.com/packetd/packetd-benchmark/mongodb/server v0.0.0
+	github.com/packetd/packetd-benchmark/mysql/client v0.0.0
+	github.com/packetd/packetd-benchmark/mysql/server v0.0.0
+	github.com/packetd/packetd-benchmark/postgresql/client v0.0.0
+	github.com/packetd/packetd-benchmark/postgresql/server v0.0.0
+	github.com/packetd/packetd-benchmark/redis/client v0.0.0
+	github.com/packetd/packetd-benchmark/redis/server v0.0.0
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/IBM/sarama v1.45.2 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/eapache/go-resiliency v1.7.0 // indirect
+	github.com/eapache/go-xerial-snappy v0.0.0-20230731223053-c322873962e3 // indirect
+	github.com/eapache/queue v1.1.0 // indirect
+	github.com/go-sql-driver/mysql v1.9.1 // indirect
+	github.com/golang/snappy v0.0.4 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/hashicorp/go-uuid v1.0.3 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.7.4 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/jcmturner/aescts/v2 v2.0.0 // indirect
+	github.com/jcmturner/dnsutils/v2 v2.0.0 // indirect
+	github.com/jcmturner/gofork v1.7.6 // indirect
+	github.com/jcmturner/gokrb5/v8 v8.4.4 // indirect
+	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
+	github.com/jedib0t/go-pretty/v6 v6.6.7 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/montanaflynn/stats v0.7.1 // indirect
+	github.com/packetd/packetd-benchmark/grpc/pb v0.0.0-00010101000000-000000000000 // indirect
+	github.com/pierrec/lz4/v4 v4.1.22 // indirect
+	github.com/rabbitmq/amqp091-go v1.10.0 // indirect
+	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
+	github.com/redis/go-redis/v9 v9.7.1 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	github.com/segmentio/kafka-go v0.4.48 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.1.2 // indirect
+	github.com/xdg-go/stringprep v1.0.4 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
+	go.mongodb.org/mongo-driver v1.17.3 // indirect
+	golang.org/x/crypto v0.38.0 // indirect
+	golang.org/x/net v0.40.0 // indirect
+	golang.org/x/sync v0.14.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/grpc v1.71.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
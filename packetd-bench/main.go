@@ -0,0 +1,67 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+
+	amqpbroker "github.com/packetd/packetd-benchmark/amqp/broker"
+	amqpconsumer "github.com/packetd/packetd-benchmark/amqp/consumer"
+	amqpproducer "github.com/packetd/packetd-benchmark/amqp/producer"
+	"github.com/packetd/packetd-benchmark/common"
+	"github.com/packetd/packetd-benchmark/compare"
+	grpcclient "github.com/packetd/packetd-benchmark/grpc/client"
+	grpcserver "github.com/packetd/packetd-benchmark/grpc/server"
+	httpclient "github.com/packetd/packetd-benchmark/http/client"
+	httpserver "github.com/packetd/packetd-benchmark/http/server"
+	kafkabroker "github.com/packetd/packetd-benchmark/kafka/broker"
+	kafkaconsumer "github.com/packetd/packetd-benchmark/kafka/consumer"
+	kafkaproducer "github.com/packetd/packetd-benchmark/kafka/producer"
+	mongodbclient "github.com/packetd/packetd-benchmark/mongodb/client"
+	mongodbserver "github.com/packetd/packetd-benchmark/mongodb/server"
+	mysqlclient "github.com/packetd/packetd-benchmark/mysql/client"
+	mysqlserver "github.com/packetd/packetd-benchmark/mysql/server"
+	postgresqlclient "github.com/packetd/packetd-benchmark/postgresql/client"
+	postgresqlserver "github.com/packetd/packetd-benchmark/postgresql/server"
+	redisclient "github.com/packetd/packetd-benchmark/redis/client"
+	redisserver "github.com/packetd/packetd-benchmark/redis/server"
+)
+
+const prog = "packetd-bench"
+
+func main() {
+	commands := common.Commands{
+		httpclient.NewCommand(),
+		httpserver.NewCommand(),
+		grpcclient.NewCommand(),
+		grpcserver.NewCommand(),
+		redisclient.NewCommand(),
+		redisserver.NewCommand(),
+		mysqlclient.NewCommand(),
+		mysqlserver.NewCommand(),
+		postgresqlclient.NewCommand(),
+		postgresqlserver.NewCommand(),
+		mongodbclient.NewCommand(),
+		mongodbserver.NewCommand(),
+		kafkaproducer.NewCommand(),
+		kafkaconsumer.NewCommand(),
+		kafkabroker.NewCommand(),
+		amqpproducer.NewCommand(),
+		amqpconsumer.NewCommand(),
+		amqpbroker.NewCommand(),
+		compare.NewCommand(),
+	}
+	commands = append(commands, common.NewScenarioCommand(prog, commands))
+	common.Main(prog, commands, os.Args[1:])
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	DSN      string
+	Workers  int
+	Total    int
+	SQL      string
+	Interval time.Duration
+}
+
+type Client struct {
+	conf Config
+	conn *pgxpool.Pool
+}
+
+func New(conf Config) *Client {
+	return &Client{
+		conf: conf,
+	}
+}
+
+func (c *Client) Setup() error {
+	config, err := pgxpool.ParseConfig(c.conf.DSN)
+	if err != nil {
+		return fmt.Errorf("unable to parse config: %w", err)
+	}
+
+	config.MaxConns = int32(c.conf.Workers)
+	conn, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	return nil
+}
+
+func (c *Client) Do(int) error {
+	r, err := c.conn.Query(context.Background(), c.conf.SQL)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer r.Close()
+
+	for r.Next() {
+	}
+	return wrapError(r.Err())
+}
+
+// wrapError 将服务端返回的 ErrorResponse 视为非预期的状态
+func wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return common.NewStatusError("%s", pgErr.Code)
+	}
+	return err
+}
+
+func (c *Client) Teardown() error {
+	c.conn.Close()
+	return nil
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "sql", Value: c.conf.SQL},
+	}
+}
+
+// NewCommand 返回 `postgresql client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "postgresql client",
+		Usage: "benchmark a postgresql server with a sql statement",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.DSN, "dsn", "", "postgresql server dsn")
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.SQL, "sql", "", "sql statement")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "postgresql"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.Interval = c.Interval
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxMessageSize 为单条消息的最大长度 超过时视为协议错误
+const maxMessageSize = 1 << 30
+
+// 前端消息类型
+const (
+	msgBind     = 'B'
+	msgClose    = 'C'
+	msgDescribe = 'D'
+	msgExecute  = 'E'
+	msgFlush    = 'H'
+	msgParse    = 'P'
+	msgPassword = 'p'
+	msgQuery    = 'Q'
+	msgSync     = 'S'
+	msgTerm     = 'X'
+)
+
+// 后端消息类型
+const (
+	msgAuthentication       = 'R'
+	msgBackendKeyData       = 'K'
+	msgBindComplete         = '2'
+	msgCloseComplete        = '3'
+	msgCommandComplete      = 'C'
+	msgDataRow              = 'D'
+	msgEmptyQueryResponse   = 'I'
+	msgErrorResponse        = 'E'
+	msgNoData               = 'n'
+	msgParameterDescription = 't'
+	msgParameterStatus      = 'S'
+	msgParseComplete        = '1'
+	msgPortalSuspended      = 's'
+	msgReadyForQuery        = 'Z'
+	msgRowDescription       = 'T'
+)
+
+// 启动阶段的请求码
+const (
+	protocolVersion3 = 196608
+	cancelRequest    = 80877102
+	sslRequest       = 80877103
+	gssEncRequest    = 80877104
+)
+
+// 认证请求类型
+const (
+	authOK           = 0
+	authSASL         = 10
+	authSASLContinue = 11
+	authSASLFinal    = 12
+)
+
+// oidText 为 text 类型 所有列均为 text 因此文本以及二进制格式的编码相同
+const oidText = 25
+
+var errMalformed = errors.New("malformed message")
+
+// msgConn 负责消息的读写 除启动消息外 每条消息均以 1 字节类型以及 4 字节长度开头
+type msgConn struct {
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+// readStartup 读取不带类型的启动消息
+func (mc *msgConn) readStartup() ([]byte, error) {
+	return mc.readBody()
+}
+
+func (mc *msgConn) readMessage() (byte, []byte, error) {
+	typ, err := mc.br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	b, err := mc.readBody()
+	return typ, b, err
+}
+
+func (mc *msgConn) readBody() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(mc.br, header[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(header[:]))
+	if n < 4 || n > maxMessageSize {
+		return nil, fmt.Errorf("invalid message length %d", n)
+	}
+
+	b := make([]byte, n-4)
+	if _, err := io.ReadFull(mc.br, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (mc *msgConn) writeMessage(typ byte, payload []byte) error {
+	var header [5]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)+4))
+	if _, err := mc.bw.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := mc.bw.Write(payload)
+	return err
+}
+
+func (mc *msgConn) flush() error {
+	return mc.bw.Flush()
+}
+
+func authMessage(code uint32, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, code)
+	return append(b, data...)
+}
+
+func parameterStatus(name, value string) []byte {
+	b := appendString(nil, name)
+	return appendString(b, value)
+}
+
+// errorResponse 返回 ErrorResponse 的字段 code 为 5 个字符的 SQLSTATE
+func errorResponse(severity, code, msg string) []byte {
+	b := append([]byte{'S'}, severity...)
+	b = append(b, 0, 'V')
+	b = append(b, severity...)
+	b = append(b, 0, 'C')
+	b = append(b, code...)
+	b = append(b, 0, 'M')
+	b = append(b, msg...)
+	return append(b, 0, 0)
+}
+
+// rowDescription 返回所有列均为 text 的 RowDescription formats 为各列的格式码
+func rowDescription(columns int, formats []int16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(columns))
+	for i := 0; i < columns; i++ {
+		b = appendString(b, fmt.Sprintf("c%d", i+1))
+		b = binary.BigEndian.AppendUint32(b, 0)
+		b = binary.BigEndian.AppendUint16(b, 0)
+		b = binary.BigEndian.AppendUint32(b, oidText)
+		b = binary.BigEndian.AppendUint16(b, 0xffff)
+		b = binary.BigEndian.AppendUint32(b, 0xffffffff)
+		b = binary.BigEndian.AppendUint16(b, uint16(formatCode(formats, i)))
+	}
+	return b
+}
+
+// formatCode 返回第 i 列的格式码 未指定时为文本格式 仅指定一个时适用于所有列
+func formatCode(formats []int16, i int) int16 {
+	switch {
+	case len(formats) == 0:
+		return 0
+	case len(formats) == 1:
+		return formats[0]
+	case i < len(formats):
+		return formats[i]
+	}
+	return 0
+}
+
+func dataRow(columns int, cell []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(columns))
+	for i := 0; i < columns; i++ {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(cell)))
+		b = append(b, cell...)
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// reader 用于解析消息内容 出现越界时记录 errMalformed 并返回零值
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errMalformed
+	}
+	r.b = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.b) < 1 {
+		r.fail()
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *reader) int16() int16 {
+	if len(r.b) < 2 {
+		r.fail()
+		return 0
+	}
+	n := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return int16(n)
+}
+
+func (r *reader) int32() int32 {
+	if len(r.b) < 4 {
+		r.fail()
+		return 0
+	}
+	n := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return int32(n)
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || len(r.b) < n {
+		r.fail()
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// string 返回以 0x00 结尾的字符串
+func (r *reader) string() string {
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	r.fail()
+	return ""
+}
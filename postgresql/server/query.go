@@ -0,0 +1,453 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+// hintPattern 匹配查询语句中的 `key=value` 可以位于注释或者 WHERE 条件中
+var hintPattern = regexp.MustCompile(`(?i)\b(rows|columns|cell_size|error|latency)\s*=\s*'?([0-9a-z.]+)`)
+
+// resultSpec 描述查询语句对应的合成结果
+type resultSpec struct {
+	// resultSet 为 false 时仅回复 CommandComplete
+	resultSet bool
+	rows      int
+	columns   int
+	cellSize  int
+
+	// errCode 不为空时回复该 SQLSTATE 的错误
+	errCode string
+	latency time.Duration
+}
+
+// parseQuery 根据查询语句生成合成结果的描述 未指定的部分使用命令行参数
+//
+//	SELECT /* rows=100 columns=4 cell_size=1KB */ * FROM bench
+//	SELECT * FROM bench WHERE rows=10 AND latency=5ms
+//	UPDATE bench SET v=1 WHERE rows=3
+//	SELECT /* error=42P01 */ 1
+func (s *Server) parseQuery(q string) (resultSpec, error) {
+	spec := resultSpec{
+		resultSet: isResultSet(q),
+		rows:      s.conf.Rows,
+		columns:   s.conf.Columns,
+		cellSize:  s.cellSize,
+		latency:   s.conf.Latency,
+	}
+	if !strings.ContainsRune(q, '=') {
+		return spec, nil
+	}
+
+	for _, m := range hintPattern.FindAllStringSubmatch(q, -1) {
+		key, value := strings.ToLower(m[1]), m[2]
+		var err error
+		switch key {
+		case "rows":
+			spec.rows, err = strconv.Atoi(value)
+		case "columns":
+			spec.columns, err = strconv.Atoi(value)
+			if err == nil && spec.columns <= 0 {
+				err = fmt.Errorf("columns must be positive")
+			}
+		case "cell_size":
+			spec.cellSize, err = common.ParseBytes(value)
+		case "error":
+			if len(value) != 5 {
+				err = fmt.Errorf("SQLSTATE must be 5 characters")
+			}
+			spec.errCode = strings.ToUpper(value)
+		case "latency":
+			spec.latency, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return spec, fmt.Errorf("invalid %s=%s: %w", key, value, err)
+		}
+	}
+	return spec, nil
+}
+
+// firstKeyword 返回语句的第一个关键字 忽略开头的空白 注释以及括号
+func firstKeyword(q string) string {
+	for {
+		q = strings.TrimLeft(q, " \t\r\n(")
+		if strings.HasPrefix(q, "--") {
+			end := strings.IndexByte(q, '\n')
+			if end < 0 {
+				return ""
+			}
+			q = q[end+1:]
+			continue
+		}
+		if !strings.HasPrefix(q, "/*") {
+			break
+		}
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return ""
+		}
+		q = q[end+2:]
+	}
+
+	if i := strings.IndexAny(q, " \t\r\n(;"); i >= 0 {
+		q = q[:i]
+	}
+	return strings.ToLower(q)
+}
+
+// isResultSet 判断语句是否返回结果集
+func isResultSet(q string) bool {
+	switch firstKeyword(q) {
+	case "select", "show", "explain", "with", "values", "table", "fetch":
+		return true
+	}
+	return false
+}
+
+// commandTag 返回 CommandComplete 的命令标签 修改数据的语句以 rows 作为影响的行数
+func commandTag(q string, spec resultSpec) string {
+	kw := firstKeyword(q)
+	switch kw {
+	case "show", "explain":
+		return strings.ToUpper(kw)
+	case "insert":
+		return "INSERT 0 " + strconv.Itoa(spec.rows)
+	case "update", "delete", "merge", "fetch":
+		return strings.ToUpper(kw) + " " + strconv.Itoa(spec.rows)
+	}
+	if spec.resultSet {
+		return "SELECT " + strconv.Itoa(spec.rows)
+	}
+	return strings.ToUpper(kw)
+}
+
+// simpleQuery 处理 Query 消息 多条语句依次回复 出现错误时忽略剩余的语句
+func (c *conn) simpleQuery(data []byte) error {
+	r := &reader{b: data}
+	q := r.string()
+	if r.err != nil {
+		return r.err
+	}
+
+	statements := splitStatements(q)
+	if len(statements) == 0 {
+		if err := c.mc.writeMessage(msgEmptyQueryResponse, nil); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range statements {
+		spec, err := c.s.parseQuery(stmt)
+		if err != nil {
+			if err := c.error("42601", err.Error()); err != nil {
+				return err
+			}
+			break
+		}
+		if spec.latency > 0 {
+			time.Sleep(spec.latency)
+		}
+		if spec.errCode != "" {
+			if err := c.error(spec.errCode, syntheticError(spec.errCode)); err != nil {
+				return err
+			}
+			break
+		}
+		if spec.resultSet {
+			if err := c.mc.writeMessage(msgRowDescription, rowDescription(spec.columns, nil)); err != nil {
+				return err
+			}
+		}
+		if _, err := c.writeRows(stmt, spec, 0, 0); err != nil {
+			return err
+		}
+	}
+
+	c.failed = false
+	return c.readyForQuery()
+}
+
+func syntheticError(code string) string {
+	return "synthetic error " + code
+}
+
+// writeRows 从第 offset 行开始写入合成的 DataRow maxRows 大于 0 时最多写入 maxRows 行
+// 全部写完时回复 CommandComplete 否则回复 PortalSuspended 并返回已写入的行数
+func (c *conn) writeRows(q string, spec resultSpec, offset, maxRows int) (int, error) {
+	if !spec.resultSet {
+		return 0, c.mc.writeMessage(msgCommandComplete, appendString(nil, commandTag(q, spec)))
+	}
+
+	n := spec.rows - offset
+	if maxRows > 0 && maxRows < n {
+		n = maxRows
+	}
+	row := dataRow(spec.columns, bytes.Repeat([]byte{'x'}, spec.cellSize))
+	for i := 0; i < n; i++ {
+		if err := c.mc.writeMessage(msgDataRow, row); err != nil {
+			return n, err
+		}
+	}
+	if offset+n < spec.rows {
+		return n, c.mc.writeMessage(msgPortalSuspended, nil)
+	}
+	return n, c.mc.writeMessage(msgCommandComplete, appendString(nil, commandTag(q, spec)))
+}
+
+// splitStatements 以分号拆分多条语句 忽略引号以及注释中的分号 空语句将被丢弃
+func splitStatements(q string) []string {
+	var statements []string
+	var quote byte
+	start := 0
+	add := func(end int) {
+		if firstKeyword(q[start:end]) != "" {
+			statements = append(statements, q[start:end])
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '-' && strings.HasPrefix(q[i:], "--"):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += end
+			}
+		case ch == '/' && strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += end + 3
+			}
+		case ch == ';':
+			add(i)
+		}
+	}
+	if start < len(q) {
+		add(len(q))
+	}
+	return statements
+}
+
+// stmt 为 Parse 创建的预处理语句 合成结果在 Parse 时确定
+type stmt struct {
+	query  string
+	params []uint32
+	spec   resultSpec
+}
+
+// portal 为 Bind 创建的门户 sent 为已经返回的行数
+type portal struct {
+	stmt    *stmt
+	formats []int16
+	sent    int
+}
+
+func (c *conn) parse(data []byte) error {
+	r := &reader{b: data}
+	name := r.string()
+	q := r.string()
+	params := make([]uint32, r.int16())
+	for i := range params {
+		params[i] = uint32(r.int32())
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	if _, ok := c.stmts[name]; ok && name != "" {
+		return c.error("42P05", fmt.Sprintf("prepared statement %q already exists", name))
+	}
+	spec, err := c.s.parseQuery(q)
+	if err != nil {
+		return c.error("42601", err.Error())
+	}
+
+	// 未指定类型的参数均视为 text
+	if n := countParams(q); n > len(params) {
+		params = append(params, make([]uint32, n-len(params))...)
+	}
+	for i := range params {
+		if params[i] == 0 {
+			params[i] = oidText
+		}
+	}
+	c.stmts[name] = &stmt{query: q, params: params, spec: spec}
+	return c.mc.writeMessage(msgParseComplete, nil)
+}
+
+// bind 创建门户 合成结果与参数无关 因此不解析参数
+func (c *conn) bind(data []byte) error {
+	r := &reader{b: data}
+	name := r.string()
+	stmtName := r.string()
+	r.bytes(2 * int(r.int16()))
+	for n := r.int16(); n > 0 && r.err == nil; n-- {
+		if size := r.int32(); size > 0 {
+			r.bytes(int(size))
+		}
+	}
+	formats := make([]int16, r.int16())
+	for i := range formats {
+		formats[i] = r.int16()
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	st, ok := c.stmts[stmtName]
+	if !ok {
+		return c.error("26000", fmt.Sprintf("prepared statement %q does not exist", stmtName))
+	}
+	c.portals[name] = &portal{stmt: st, formats: formats}
+	return c.mc.writeMessage(msgBindComplete, nil)
+}
+
+func (c *conn) describe(data []byte) error {
+	r := &reader{b: data}
+	kind := r.byte()
+	name := r.string()
+	if r.err != nil {
+		return r.err
+	}
+
+	var spec resultSpec
+	var formats []int16
+	switch kind {
+	case 'S':
+		st, ok := c.stmts[name]
+		if !ok {
+			return c.error("26000", fmt.Sprintf("prepared statement %q does not exist", name))
+		}
+		b := binary.BigEndian.AppendUint16(nil, uint16(len(st.params)))
+		for _, oid := range st.params {
+			b = binary.BigEndian.AppendUint32(b, oid)
+		}
+		if err := c.mc.writeMessage(msgParameterDescription, b); err != nil {
+			return err
+		}
+		spec = st.spec
+	case 'P':
+		p, ok := c.portals[name]
+		if !ok {
+			return c.error("34000", fmt.Sprintf("portal %q does not exist", name))
+		}
+		spec, formats = p.stmt.spec, p.formats
+	default:
+		return c.error("08P01", fmt.Sprintf("invalid DESCRIBE message subtype %d", kind))
+	}
+
+	if !spec.resultSet {
+		return c.mc.writeMessage(msgNoData, nil)
+	}
+	return c.mc.writeMessage(msgRowDescription, rowDescription(spec.columns, formats))
+}
+
+func (c *conn) execute(data []byte) error {
+	r := &reader{b: data}
+	name := r.string()
+	maxRows := int(r.int32())
+	if r.err != nil {
+		return r.err
+	}
+
+	p, ok := c.portals[name]
+	if !ok {
+		return c.error("34000", fmt.Sprintf("portal %q does not exist", name))
+	}
+	st := p.stmt
+	if firstKeyword(st.query) == "" {
+		return c.mc.writeMessage(msgEmptyQueryResponse, nil)
+	}
+
+	spec := st.spec
+	if spec.latency > 0 {
+		time.Sleep(spec.latency)
+	}
+	if spec.errCode != "" {
+		return c.error(spec.errCode, syntheticError(spec.errCode))
+	}
+	n, err := c.writeRows(st.query, spec, p.sent, maxRows)
+	p.sent += n
+	return err
+}
+
+func (c *conn) close(data []byte) error {
+	r := &reader{b: data}
+	kind := r.byte()
+	name := r.string()
+	if r.err != nil {
+		return r.err
+	}
+
+	switch kind {
+	case 'S':
+		delete(c.stmts, name)
+	case 'P':
+		delete(c.portals, name)
+	default:
+		return c.error("08P01", fmt.Sprintf("invalid CLOSE message subtype %d", kind))
+	}
+	return c.mc.writeMessage(msgCloseComplete, nil)
+}
+
+// countParams 返回语句中占位符 `$n` 的最大序号 忽略引号以及注释中的占位符
+func countParams(q string) int {
+	var n int
+	var quote byte
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '/' && strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return n
+			}
+			i += end + 3
+		case ch == '$':
+			j := i + 1
+			for j < len(q) && q[j] >= '0' && q[j] <= '9' {
+				j++
+			}
+			if v, err := strconv.Atoi(q[i+1 : j]); err == nil && v > n {
+				n = v
+			}
+			i = j - 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	scramSHA256 = "SCRAM-SHA-256"
+
+	// scramIterations 与 PostgreSQL 的默认值 scram_iterations 一致
+	scramIterations = 4096
+)
+
+var errAuthFailed = errors.New("password authentication failed")
+
+// scramSecret 为密码派生的密钥 与 pg_authid 中保存的内容一致 启动时计算一次
+type scramSecret struct {
+	salt      []byte
+	storedKey []byte
+	serverKey []byte
+}
+
+func newScramSecret(password string) (*scramSecret, error) {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	salted, err := pbkdf2.Key(sha256.New, password, salt, scramIterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	clientKey := hmacSum(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	return &scramSecret{
+		salt:      salt,
+		storedKey: storedKey[:],
+		serverKey: hmacSum(salted, "Server Key"),
+	}, nil
+}
+
+func hmacSum(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// scramConv 为一次 SCRAM-SHA-256 交互 不支持通道绑定
+type scramConv struct {
+	secret *scramSecret
+
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	nonce           string
+}
+
+// first 处理 client-first-message 并返回 server-first-message
+func (sc *scramConv) first(msg string) (string, error) {
+	// gs2-header 为 `n,,` 或者 `y,,` 用户名由启动消息指定 此处忽略
+	parts := strings.SplitN(msg, ",", 3)
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "y") {
+		return "", errors.New("unsupported SCRAM channel binding")
+	}
+	sc.gs2Header = parts[0] + "," + parts[1] + ","
+	sc.clientFirstBare = parts[2]
+
+	clientNonce := scramAttr(sc.clientFirstBare, 'r')
+	if clientNonce == "" {
+		return "", errMalformed
+	}
+	b := make([]byte, 18)
+	rand.Read(b)
+	sc.nonce = clientNonce + base64.StdEncoding.EncodeToString(b)
+
+	sc.serverFirst = "r=" + sc.nonce +
+		",s=" + base64.StdEncoding.EncodeToString(sc.secret.salt) +
+		",i=" + strconv.Itoa(scramIterations)
+	return sc.serverFirst, nil
+}
+
+// final 校验 client-final-message 中的 proof 并返回 server-final-message
+func (sc *scramConv) final(msg string) (string, error) {
+	i := strings.LastIndex(msg, ",p=")
+	if i < 0 {
+		return "", errMalformed
+	}
+	withoutProof := msg[:i]
+	if scramAttr(withoutProof, 'c') != base64.StdEncoding.EncodeToString([]byte(sc.gs2Header)) {
+		return "", errors.New("SCRAM channel binding check failed")
+	}
+	if scramAttr(withoutProof, 'r') != sc.nonce {
+		return "", errors.New("SCRAM nonce mismatch")
+	}
+	proof, err := base64.StdEncoding.DecodeString(msg[i+3:])
+	if err != nil || len(proof) != sha256.Size {
+		return "", errMalformed
+	}
+
+	// ClientKey = ClientProof XOR HMAC(StoredKey, AuthMessage) 且 H(ClientKey) 须等于 StoredKey
+	authMsg := sc.clientFirstBare + "," + sc.serverFirst + "," + withoutProof
+	clientKey := hmacSum(sc.secret.storedKey, authMsg)
+	for i := range clientKey {
+		clientKey[i] ^= proof[i]
+	}
+	storedKey := sha256.Sum256(clientKey)
+	if subtle.ConstantTimeCompare(storedKey[:], sc.secret.storedKey) != 1 {
+		return "", errAuthFailed
+	}
+
+	signature := hmacSum(sc.secret.serverKey, authMsg)
+	return "v=" + base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// scramAttr 返回逗号分隔的属性中 key 对应的值
+func scramAttr(msg string, key byte) string {
+	for _, attr := range strings.Split(msg, ",") {
+		if len(attr) >= 2 && attr[0] == key && attr[1] == '=' {
+			return attr[2:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,376 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+const serverVersion = "16.4 (packetd-bench)"
+
+type Config struct {
+	Addr string
+
+	// User 为空时接受任意用户 Password 为空时使用 trust 认证 否则使用 SCRAM-SHA-256
+	User     string
+	Password string
+
+	// Rows Columns 以及 CellSize 为结果集的默认大小 可以在查询语句中覆盖 详见 parseQuery
+	Rows     int
+	Columns  int
+	CellSize string
+
+	// Latency 为每条语句回复前的等待时间
+	Latency time.Duration
+}
+
+// Server 为 postgresql 的替身 根据查询语句返回合成的结果集 不保存任何数据
+type Server struct {
+	conf     Config
+	cellSize int
+	secret   *scramSecret
+	ids      atomic.Uint32
+}
+
+func New(conf Config) (*Server, error) {
+	size, err := common.ParseBytes(conf.CellSize)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Rows < 0 || conf.Columns <= 0 {
+		return nil, errors.New("rows must not be negative and columns must be positive")
+	}
+
+	s := &Server{conf: conf, cellSize: size}
+	if conf.Password != "" {
+		if s.secret, err = newScramSecret(conf.Password); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewCommand 返回 `postgresql server` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:  "postgresql server",
+		Usage: "serve the postgresql wire protocol with synthetic result sets",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:5432", "postgresql server address")
+			fs.StringVar(&c.User, "user", "", "accepted user, empty means any user")
+			fs.StringVar(&c.Password, "password", "", "password checked with SCRAM-SHA-256, empty means trust authentication")
+			fs.IntVar(&c.Rows, "rows", 1, "default rows of result sets")
+			fs.IntVar(&c.Columns, "columns", 1, "default columns of result sets")
+			fs.StringVar(&c.CellSize, "cell_size", "16B", "default size of each cell in result sets")
+			fs.DurationVar(&c.Latency, "latency", 0, "delay before replying each statement")
+		},
+		Run: func([]string) error {
+			s, err := New(c)
+			if err != nil {
+				return err
+			}
+			return s.Serve()
+		},
+	}
+}
+
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(c)
+	}
+}
+
+type conn struct {
+	s       *Server
+	mc      msgConn
+	id      uint32
+	stmts   map[string]*stmt
+	portals map[string]*portal
+
+	// failed 为 true 时忽略 Sync 之前的扩展查询消息
+	failed bool
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+
+	c := &conn{
+		s:       s,
+		mc:      msgConn{br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)},
+		id:      s.ids.Add(1),
+		stmts:   make(map[string]*stmt),
+		portals: make(map[string]*portal),
+	}
+	if err := c.startup(); err != nil {
+		if !errors.Is(err, io.EOF) {
+			log.Printf("startup with %s failed: %v\n", nc.RemoteAddr(), err)
+		}
+		return
+	}
+
+	for {
+		typ, data, err := c.mc.readMessage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+		if typ == msgTerm {
+			return
+		}
+		if err := c.dispatch(typ, data); err != nil {
+			return
+		}
+	}
+}
+
+// startup 处理启动消息 拒绝 SSL 以及 GSSAPI 加密请求后等待客户端重新发送启动消息
+func (c *conn) startup() error {
+	for {
+		data, err := c.mc.readStartup()
+		if err != nil {
+			return err
+		}
+		r := &reader{b: data}
+		code := r.int32()
+		if r.err != nil {
+			return r.err
+		}
+
+		switch code {
+		case sslRequest, gssEncRequest:
+			if err := c.mc.bw.WriteByte('N'); err != nil {
+				return err
+			}
+			if err := c.mc.flush(); err != nil {
+				return err
+			}
+			continue
+		case cancelRequest:
+			return io.EOF
+		case protocolVersion3:
+		default:
+			c.fatal("0A000", fmt.Sprintf("unsupported frontend protocol %d.%d", code>>16, code&0xffff))
+			return fmt.Errorf("unsupported protocol %d", code)
+		}
+
+		params := make(map[string]string)
+		for r.err == nil && len(r.b) > 1 {
+			name := r.string()
+			params[name] = r.string()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if err := c.authenticate(params["user"]); err != nil {
+			return err
+		}
+		return c.ready(params)
+	}
+}
+
+// fatal 回复 FATAL 级别的错误 随后连接将被关闭
+func (c *conn) fatal(code, msg string) {
+	c.mc.writeMessage(msgErrorResponse, errorResponse("FATAL", code, msg))
+	c.mc.flush()
+}
+
+func (c *conn) authenticate(user string) error {
+	conf := c.s.conf
+	if conf.User != "" && user != conf.User {
+		msg := fmt.Sprintf("role %q does not exist", user)
+		c.fatal("28000", msg)
+		return errors.New(msg)
+	}
+	if c.s.secret == nil {
+		return nil
+	}
+
+	if err := c.scram(); err != nil {
+		if !errors.Is(err, io.EOF) {
+			c.fatal("28P01", fmt.Sprintf("password authentication failed for user %q", user))
+		}
+		return err
+	}
+	return nil
+}
+
+// scram 完成 SCRAM-SHA-256 认证 AuthenticationSASL -> SASLInitialResponse
+// -> AuthenticationSASLContinue -> SASLResponse -> AuthenticationSASLFinal
+func (c *conn) scram() error {
+	mechanisms := appendString(appendString(nil, scramSHA256), "")
+	if err := c.mc.writeMessage(msgAuthentication, authMessage(authSASL, mechanisms)); err != nil {
+		return err
+	}
+	if err := c.mc.flush(); err != nil {
+		return err
+	}
+
+	data, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+	r := &reader{b: data}
+	mechanism := r.string()
+	clientFirst := r.bytes(int(r.int32()))
+	if r.err != nil {
+		return r.err
+	}
+	if mechanism != scramSHA256 {
+		return fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+	}
+
+	sc := &scramConv{secret: c.s.secret}
+	serverFirst, err := sc.first(string(clientFirst))
+	if err != nil {
+		return err
+	}
+	if err := c.mc.writeMessage(msgAuthentication, authMessage(authSASLContinue, []byte(serverFirst))); err != nil {
+		return err
+	}
+	if err := c.mc.flush(); err != nil {
+		return err
+	}
+
+	data, err = c.readPassword()
+	if err != nil {
+		return err
+	}
+	serverFinal, err := sc.final(string(data))
+	if err != nil {
+		return err
+	}
+	return c.mc.writeMessage(msgAuthentication, authMessage(authSASLFinal, []byte(serverFinal)))
+}
+
+func (c *conn) readPassword() ([]byte, error) {
+	typ, data, err := c.mc.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	if typ != msgPassword {
+		return nil, fmt.Errorf("expected password message, got %q", typ)
+	}
+	return data, nil
+}
+
+// ready 发送 AuthenticationOk 以及服务端参数 此后连接可以接受查询
+func (c *conn) ready(params map[string]string) error {
+	if err := c.mc.writeMessage(msgAuthentication, authMessage(authOK, nil)); err != nil {
+		return err
+	}
+
+	status := [][2]string{
+		{"server_version", serverVersion},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"IntervalStyle", "postgres"},
+		{"TimeZone", "UTC"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+		{"is_superuser", "on"},
+		{"session_authorization", params["user"]},
+		{"application_name", params["application_name"]},
+	}
+	for _, kv := range status {
+		if err := c.mc.writeMessage(msgParameterStatus, parameterStatus(kv[0], kv[1])); err != nil {
+			return err
+		}
+	}
+
+	key := binary.BigEndian.AppendUint32(nil, c.id)
+	key = binary.BigEndian.AppendUint32(key, newSecretKey())
+	if err := c.mc.writeMessage(msgBackendKeyData, key); err != nil {
+		return err
+	}
+	return c.readyForQuery()
+}
+
+func newSecretKey() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// readyForQuery 发送 ReadyForQuery 并写回缓冲的消息 事务状态始终为空闲
+func (c *conn) readyForQuery() error {
+	if err := c.mc.writeMessage(msgReadyForQuery, []byte{'I'}); err != nil {
+		return err
+	}
+	return c.mc.flush()
+}
+
+func (c *conn) dispatch(typ byte, data []byte) error {
+	if typ == msgQuery {
+		return c.simpleQuery(data)
+	}
+	if typ == msgSync {
+		c.failed = false
+		return c.readyForQuery()
+	}
+	if typ == msgFlush {
+		return c.mc.flush()
+	}
+	if c.failed {
+		return nil
+	}
+
+	var err error
+	switch typ {
+	case msgParse:
+		err = c.parse(data)
+	case msgBind:
+		err = c.bind(data)
+	case msgDescribe:
+		err = c.describe(data)
+	case msgExecute:
+		err = c.execute(data)
+	case msgClose:
+		err = c.close(data)
+	default:
+		err = c.error("08P01", fmt.Sprintf("unsupported frontend message type %q", typ))
+	}
+	return err
+}
+
+// error 回复 ERROR 级别的错误 扩展查询中随后的消息将被忽略直到 Sync
+func (c *conn) error(code, msg string) error {
+	c.failed = true
+	return c.mc.writeMessage(msgErrorResponse, errorResponse("ERROR", code, msg))
+}
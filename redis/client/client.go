@@ -0,0 +1,143 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	Addr     string
+	Workers  int
+	Total    int
+	BodySize string
+	Cmd      string
+	Interval time.Duration
+}
+
+func (c Config) GetBodySize() int {
+	i, err := common.ParseBytes(c.BodySize)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+type Client struct {
+	conf Config
+	cli  *redis.Client
+}
+
+func New(conf Config) *Client {
+	return &Client{
+		conf: conf,
+	}
+}
+
+func (c *Client) Setup() error {
+	c.cli = redis.NewClient(&redis.Options{
+		Addr:         c.conf.Addr,
+		DialTimeout:  time.Second,
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+		PoolSize:     c.conf.Workers,
+	})
+	return nil
+}
+
+func (c *Client) Do(int) error {
+	var err error
+	switch c.conf.Cmd {
+	case "ping":
+		err = c.cmdPing()
+	case "set":
+		err = c.cmdSet()
+	case "get":
+		err = c.cmdGet()
+	}
+	return wrapError(err)
+}
+
+// wrapError 将 redis 的错误回复视为非预期的状态 key 不存在时不视为错误
+func wrapError(err error) error {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	var rerr redis.Error
+	if errors.As(err, &rerr) {
+		return common.NewStatusError("%s", rerr.Error())
+	}
+	return err
+}
+
+func (c *Client) Teardown() error {
+	return c.cli.Close()
+}
+
+func (c *Client) Columns() []common.Column {
+	return []common.Column{
+		{Name: "bodySize", Value: c.conf.BodySize},
+		{Name: "command", Value: c.conf.Cmd},
+	}
+}
+
+func (c *Client) cmdPing() error {
+	return c.cli.Ping(context.Background()).Err()
+}
+
+func (c *Client) cmdSet() error {
+	return c.cli.Set(context.Background(), "hello", bytes.Repeat([]byte{'x'}, c.conf.GetBodySize()), 0).Err()
+}
+
+func (c *Client) cmdGet() error {
+	return c.cli.Get(context.Background(), "hello").Err()
+}
+
+// NewCommand 返回 `redis client` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	var rc common.RunConfig
+	return &common.Command{
+		Name:  "redis client",
+		Usage: "benchmark a redis server with ping/set/get commands",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:6379", "redis server address")
+			fs.IntVar(&c.Workers, "workers", 1, "concurrency workers")
+			fs.IntVar(&c.Total, "total", 1, "requests total")
+			fs.StringVar(&c.BodySize, "body_size", "1KB", "request body size")
+			fs.StringVar(&c.Cmd, "cmd", "ping", "redis command, options: ping/set/get")
+			fs.DurationVar(&c.Interval, "interval", 0, "interval per request in each worker")
+			rc.RegisterFlags(fs)
+		},
+		Runner: func() *common.Runner {
+			rc.Proto = "redis"
+			rc.Workers = c.Workers
+			rc.Total = c.Total
+			rc.Interval = c.Interval
+			rc.PaceWorkers = true
+			rc.BodySize = c.GetBodySize()
+
+			return common.NewRunner(rc, New(c))
+		},
+	}
+}
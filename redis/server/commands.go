@@ -0,0 +1,519 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	errSyntax    = "ERR syntax error"
+	errNotInt    = "ERR value is not an integer or out of range"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindHash
+	kindList
+)
+
+// entry 为单个 key 的数据 list 按照写入顺序保存 最后一个元素为表头
+type entry struct {
+	kind     kind
+	str      []byte
+	hash     map[string][]byte
+	list     [][]byte
+	expireAt time.Time
+}
+
+type store struct {
+	mu   sync.Mutex
+	data map[string]*entry
+}
+
+func newStore() *store {
+	return &store{data: make(map[string]*entry)}
+}
+
+// lookup 返回未过期的 key 调用方需要持有锁
+func (st *store) lookup(key string) *entry {
+	e, ok := st.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		delete(st.data, key)
+		return nil
+	}
+	return e
+}
+
+type command struct {
+	// arity 与 redis COMMAND 的定义一致 包含命令名称 负数表示最少的参数个数
+	arity int
+
+	// data 表示读写数据的命令 仅这类命令会按照 -error_rate 回复错误
+	data bool
+
+	fn func(s *Server, c *conn, args [][]byte)
+}
+
+var commands = map[string]command{
+	"ping":     {arity: -1, fn: cmdPing},
+	"echo":     {arity: 2, fn: cmdEcho},
+	"hello":    {arity: -1, fn: cmdHello},
+	"client":   {arity: -2, fn: cmdClient},
+	"select":   {arity: 2, fn: cmdSelect},
+	"command":  {arity: -1, fn: cmdCommand},
+	"quit":     {arity: 1, fn: cmdQuit},
+	"flushall": {arity: -1, fn: cmdFlush},
+	"flushdb":  {arity: -1, fn: cmdFlush},
+	"get":      {arity: 2, data: true, fn: cmdGet},
+	"set":      {arity: -3, data: true, fn: cmdSet},
+	"mget":     {arity: -2, data: true, fn: cmdMGet},
+	"hset":     {arity: -4, data: true, fn: cmdHSet},
+	"lpush":    {arity: -3, data: true, fn: cmdLPush},
+	"incr":     {arity: 2, data: true, fn: cmdIncr},
+	"del":      {arity: -2, data: true, fn: cmdDel},
+	"expire":   {arity: -3, data: true, fn: cmdExpire},
+}
+
+func cmdPing(_ *Server, c *conn, args [][]byte) {
+	switch len(args) {
+	case 1:
+		c.w.simple("PONG")
+	case 2:
+		c.w.bulk(args[1])
+	default:
+		c.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(_ *Server, c *conn, args [][]byte) {
+	c.w.bulk(args[1])
+}
+
+// cmdHello 切换连接的协议版本 格式为 `HELLO [protover [AUTH username password] [SETNAME clientname]]`
+func cmdHello(_ *Server, c *conn, args [][]byte) {
+	proto := c.w.proto
+	if len(args) > 1 {
+		v, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			c.w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != 2 && v != 3 {
+			c.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = v
+	}
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "auth" && i+2 < len(args):
+			i += 2
+		case opt == "setname" && i+1 < len(args):
+			i++
+		default:
+			c.w.error("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+			return
+		}
+	}
+
+	c.w.proto = proto
+	c.w.mapHeader(7)
+	c.w.bulkString("server")
+	c.w.bulkString("redis")
+	c.w.bulkString("version")
+	c.w.bulkString("7.2.0")
+	c.w.bulkString("proto")
+	c.w.integer(int64(proto))
+	c.w.bulkString("id")
+	c.w.integer(c.id)
+	c.w.bulkString("mode")
+	c.w.bulkString("standalone")
+	c.w.bulkString("role")
+	c.w.bulkString("master")
+	c.w.bulkString("modules")
+	c.w.array(0)
+}
+
+func cmdClient(_ *Server, c *conn, args [][]byte) {
+	switch strings.ToLower(string(args[1])) {
+	case "id":
+		c.w.integer(c.id)
+	case "getname":
+		c.w.null()
+	case "setname", "setinfo":
+		c.w.simple("OK")
+	default:
+		c.w.error("ERR unknown subcommand '" + string(args[1]) + "'")
+	}
+}
+
+func cmdSelect(_ *Server, c *conn, args [][]byte) {
+	db, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		c.w.error(errNotInt)
+		return
+	}
+	if db < 0 || db >= 16 {
+		c.w.error("ERR DB index is out of range")
+		return
+	}
+	c.w.simple("OK")
+}
+
+func cmdCommand(_ *Server, c *conn, _ [][]byte) {
+	c.w.array(0)
+}
+
+func cmdQuit(_ *Server, c *conn, _ [][]byte) {
+	c.w.simple("OK")
+	c.quit = true
+}
+
+func cmdFlush(s *Server, c *conn, _ [][]byte) {
+	s.store.mu.Lock()
+	s.store.data = make(map[string]*entry)
+	s.store.mu.Unlock()
+	c.w.simple("OK")
+}
+
+// 以下命令仅在持有锁期间读写数据 回复在释放锁之后写入
+// 回复超过 bufio 缓冲区时写入会阻塞在连接上 持有锁写入会导致单个慢客户端阻塞所有连接
+//
+// 写入回复的值需要在持有锁期间取出 INCR 等命令会替换 entry 中的字段
+
+func cmdGet(s *Server, c *conn, args [][]byte) {
+	s.store.mu.Lock()
+	e := s.store.lookup(string(args[1]))
+	found := e != nil
+	wrongType := found && e.kind != kindString
+	var str []byte
+	if found {
+		str = e.str
+	}
+	s.store.mu.Unlock()
+
+	switch {
+	case !found:
+		s.missing(c)
+	case wrongType:
+		c.w.error(errWrongType)
+	default:
+		c.w.bulk(str)
+	}
+}
+
+// missing 回复不存在的 key 设置了 -value_size 时返回固定大小的数据
+func (s *Server) missing(c *conn) {
+	if len(s.value) > 0 {
+		c.w.bulk(s.value)
+		return
+	}
+	c.w.null()
+}
+
+// cmdSet 支持 EX PX NX XX KEEPTTL 以及 GET 选项
+func cmdSet(s *Server, c *conn, args [][]byte) {
+	var ttl time.Duration
+	var nx, xx, keepTTL, get bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "keepttl":
+			keepTTL = true
+		case "get":
+			get = true
+		case "ex", "px":
+			if i+1 >= len(args) || ttl > 0 {
+				c.w.error(errSyntax)
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				c.w.error(errNotInt)
+				return
+			}
+			if n <= 0 {
+				c.w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "px" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			c.w.error(errSyntax)
+			return
+		}
+	}
+	if nx && xx || keepTTL && ttl > 0 {
+		c.w.error(errSyntax)
+		return
+	}
+
+	s.store.mu.Lock()
+	key := string(args[1])
+	old := s.store.lookup(key)
+	found := old != nil
+	wrongType := found && old.kind != kindString
+	var oldStr []byte
+	if found {
+		oldStr = old.str
+	}
+	written := !wrongType && !(nx && found || xx && !found)
+	if written {
+		e := &entry{kind: kindString, str: args[2]}
+		switch {
+		case ttl > 0:
+			e.expireAt = time.Now().Add(ttl)
+		case keepTTL && found:
+			e.expireAt = old.expireAt
+		}
+		s.store.data[key] = e
+	}
+	s.store.mu.Unlock()
+
+	switch {
+	case get && wrongType:
+		c.w.error(errWrongType)
+	case !get && !written:
+		c.w.null()
+	case !get:
+		c.w.simple("OK")
+	case !found:
+		c.w.null()
+	default:
+		c.w.bulk(oldStr)
+	}
+}
+
+func cmdMGet(s *Server, c *conn, args [][]byte) {
+	type value struct {
+		str       []byte
+		found     bool
+		wrongType bool
+	}
+	values := make([]value, 0, len(args)-1)
+
+	s.store.mu.Lock()
+	for _, key := range args[1:] {
+		var v value
+		if e := s.store.lookup(string(key)); e != nil {
+			v = value{str: e.str, found: true, wrongType: e.kind != kindString}
+		}
+		values = append(values, v)
+	}
+	s.store.mu.Unlock()
+
+	c.w.array(len(values))
+	for _, v := range values {
+		switch {
+		case !v.found:
+			s.missing(c)
+		case v.wrongType:
+			c.w.null()
+		default:
+			c.w.bulk(v.str)
+		}
+	}
+}
+
+func cmdHSet(s *Server, c *conn, args [][]byte) {
+	if len(args)%2 != 0 {
+		c.w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+	n, errMsg := s.store.hset(string(args[1]), args[2:])
+	if errMsg != "" {
+		c.w.error(errMsg)
+		return
+	}
+	c.w.integer(n)
+}
+
+func (st *store) hset(key string, pairs [][]byte) (int64, string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e := st.lookup(key)
+	if e == nil {
+		e = &entry{kind: kindHash, hash: make(map[string][]byte)}
+		st.data[key] = e
+	}
+	if e.kind != kindHash {
+		return 0, errWrongType
+	}
+
+	var added int64
+	for i := 0; i < len(pairs); i += 2 {
+		field := string(pairs[i])
+		if _, ok := e.hash[field]; !ok {
+			added++
+		}
+		e.hash[field] = pairs[i+1]
+	}
+	return added, ""
+}
+
+func cmdLPush(s *Server, c *conn, args [][]byte) {
+	n, errMsg := s.store.lpush(string(args[1]), args[2:])
+	if errMsg != "" {
+		c.w.error(errMsg)
+		return
+	}
+	c.w.integer(n)
+}
+
+func (st *store) lpush(key string, values [][]byte) (int64, string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e := st.lookup(key)
+	if e == nil {
+		e = &entry{kind: kindList}
+		st.data[key] = e
+	}
+	if e.kind != kindList {
+		return 0, errWrongType
+	}
+	e.list = append(e.list, values...)
+	return int64(len(e.list)), ""
+}
+
+func cmdIncr(s *Server, c *conn, args [][]byte) {
+	n, errMsg := s.store.incr(string(args[1]))
+	if errMsg != "" {
+		c.w.error(errMsg)
+		return
+	}
+	c.w.integer(n)
+}
+
+func (st *store) incr(key string) (int64, string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e := st.lookup(key)
+	if e == nil {
+		e = &entry{kind: kindString, str: []byte("0")}
+		st.data[key] = e
+	}
+	if e.kind != kindString {
+		return 0, errWrongType
+	}
+
+	n, err := strconv.ParseInt(string(e.str), 10, 64)
+	if err != nil {
+		return 0, errNotInt
+	}
+	if n == math.MaxInt64 {
+		return 0, "ERR increment or decrement would overflow"
+	}
+	n++
+	e.str = strconv.AppendInt(nil, n, 10)
+	return n, ""
+}
+
+func cmdDel(s *Server, c *conn, args [][]byte) {
+	c.w.integer(s.store.del(args[1:]))
+}
+
+func (st *store) del(keys [][]byte) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var deleted int64
+	for _, key := range keys {
+		if st.lookup(string(key)) != nil {
+			delete(st.data, string(key))
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// cmdExpire 支持 NX XX GT 以及 LT 选项 没有过期时间的 key 视为永不过期
+func cmdExpire(s *Server, c *conn, args [][]byte) {
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		c.w.error(errNotInt)
+		return
+	}
+	if len(args) > 4 {
+		c.w.error(errSyntax)
+		return
+	}
+	var opt string
+	if len(args) == 4 {
+		opt = strings.ToLower(string(args[3]))
+		switch opt {
+		case "nx", "xx", "gt", "lt":
+		default:
+			c.w.error("ERR Unsupported option " + string(args[3]))
+			return
+		}
+	}
+	c.w.integer(s.store.expire(string(args[1]), seconds, opt))
+}
+
+func (st *store) expire(key string, seconds int64, opt string) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	e := st.lookup(key)
+	if e == nil {
+		return 0
+	}
+
+	expireAt := time.Now().Add(time.Duration(seconds) * time.Second)
+	persistent := e.expireAt.IsZero()
+	var ok bool
+	switch opt {
+	case "nx":
+		ok = persistent
+	case "xx":
+		ok = !persistent
+	case "gt":
+		ok = !persistent && expireAt.After(e.expireAt)
+	case "lt":
+		ok = persistent || expireAt.Before(e.expireAt)
+	default:
+		ok = true
+	}
+	if !ok {
+		return 0
+	}
+
+	if seconds <= 0 {
+		delete(st.data, key)
+	} else {
+		e.expireAt = expireAt
+	}
+	return 1
+}
@@ -0,0 +1,193 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxBulkLen 与 redis 的 proto-max-bulk-len 默认值一致
+	maxBulkLen = 512 * 1024 * 1024
+
+	// maxArgs 为单条命令的最大参数个数
+	maxArgs = 1024 * 1024
+
+	// maxInlineLen 为 inline 命令的最大长度
+	maxInlineLen = 64 * 1024
+)
+
+// protocolError 表示客户端发送的数据不符合 RESP 规范 回复错误后需要关闭连接
+type protocolError struct {
+	msg string
+}
+
+func (e *protocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+// reader 解析客户端发送的命令 支持 multibulk 以及 inline 两种格式
+type reader struct {
+	br *bufio.Reader
+}
+
+// readCommand 读取一条命令 空行返回长度为 0 的命令
+func (r *reader) readCommand() ([][]byte, error) {
+	b, err := r.br.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != '*' {
+		line, err := r.readLine(maxInlineLen)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+
+	line, err := r.readLine(maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, &protocolError{msg: "invalid multibulk length"}
+	}
+
+	args := make([][]byte, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (r *reader) readBulk() ([]byte, error) {
+	line, err := r.readLine(maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return nil, &protocolError{msg: fmt.Sprintf("expected '$', got '%s'", firstByte(line))}
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > maxBulkLen {
+		return nil, &protocolError{msg: "invalid bulk length"}
+	}
+
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(r.br, b); err != nil {
+		return nil, err
+	}
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return nil, &protocolError{msg: "bulk string not terminated by CRLF"}
+	}
+	return b[:n], nil
+}
+
+// readLine 读取以 `\r\n` 结尾的一行 返回的数据不包含换行符
+func (r *reader) readLine(limit int) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := r.br.ReadSlice('\n')
+		line = append(line, b...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if len(line) > limit {
+			return nil, &protocolError{msg: "too big inline request"}
+		}
+	}
+	return bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'}), nil
+}
+
+func firstByte(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return string(b[:1])
+}
+
+// writer 按照连接协商的协议版本写入回复 RESP2 中不存在的类型退化为等价的 RESP2 类型
+type writer struct {
+	bw    *bufio.Writer
+	proto int
+}
+
+func (w *writer) simple(s string) {
+	w.bw.WriteByte('+')
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.bw.WriteByte('-')
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.bw.WriteByte(':')
+	w.bw.WriteString(strconv.FormatInt(n, 10))
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.bw.WriteByte('$')
+	w.bw.WriteString(strconv.Itoa(len(b)))
+	w.bw.WriteString("\r\n")
+	w.bw.Write(b)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *writer) bulkString(s string) {
+	w.bulk([]byte(s))
+}
+
+// null 在 RESP2 中为 `$-1` 在 RESP3 中为 `_`
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.bw.WriteString("_\r\n")
+		return
+	}
+	w.bw.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.bw.WriteByte('*')
+	w.bw.WriteString(strconv.Itoa(n))
+	w.bw.WriteString("\r\n")
+}
+
+// mapHeader 写入包含 n 个键值对的 map RESP2 中为长度为 2n 的数组
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		w.bw.WriteByte('%')
+		w.bw.WriteString(strconv.Itoa(n))
+		w.bw.WriteString("\r\n")
+		return
+	}
+	w.array(n * 2)
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 The packetd Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/packetd/packetd-benchmark/common"
+)
+
+type Config struct {
+	Addr string
+
+	// Latency 为每条命令回复前的等待时间
+	Latency time.Duration
+
+	// ValueSize 大于 0 时 GET 以及 MGET 不存在的 key 返回该大小的数据 无需提前写入
+	ValueSize string
+
+	// ErrorRate 为回复错误的命令比例 用于压测错误回复
+	ErrorRate float64
+}
+
+// Server 为 redis 的替身 实现了压测所需的 RESP2/RESP3 命令 数据仅保存在内存中
+type Server struct {
+	conf  Config
+	value []byte
+	store *store
+	ids   atomic.Int64
+}
+
+func New(conf Config) (*Server, error) {
+	size, err := common.ParseBytes(conf.ValueSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		conf:  conf,
+		value: bytes.Repeat([]byte{'x'}, size),
+		store: newStore(),
+	}, nil
+}
+
+// NewCommand 返回 `redis server` 子命令
+func NewCommand() *common.Command {
+	var c Config
+	return &common.Command{
+		Name:  "redis server",
+		Usage: "serve a RESP2/RESP3 redis stand-in with configurable latency and value size",
+		RegisterFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&c.Addr, "addr", "localhost:6379", "redis server address")
+			fs.DurationVar(&c.Latency, "latency", 0, "delay before replying each command")
+			fs.StringVar(&c.ValueSize, "value_size", "0B", "reply values of this size for GET/MGET on missing keys, 0B means nil")
+			fs.Float64Var(&c.ErrorRate, "error_rate", 0, "fraction of data commands answered with an error reply")
+		},
+		Run: func([]string) error {
+			s, err := New(c)
+			if err != nil {
+				return err
+			}
+			return s.Serve()
+		},
+	}
+}
+
+func (s *Server) Serve() error {
+	lis, err := net.Listen("tcp", s.conf.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("server listening on %s\n", lis.Addr())
+	for {
+		c, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(c)
+	}
+}
+
+// conn 为单个客户端连接的状态 通过 HELLO 协商的协议版本保存在 w.proto 中
+type conn struct {
+	id   int64
+	r    reader
+	w    writer
+	quit bool
+}
+
+// handle 依次处理连接上的命令 仅在读缓冲区为空时写回 以支持 pipeline
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+
+	c := &conn{
+		id: s.ids.Add(1),
+		r:  reader{br: bufio.NewReader(nc)},
+		w:  writer{bw: bufio.NewWriter(nc), proto: 2},
+	}
+	for !c.quit {
+		args, err := c.r.readCommand()
+		if err != nil {
+			var pe *protocolError
+			if errors.As(err, &pe) {
+				c.w.error("ERR " + pe.Error())
+				c.w.bw.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read from %s failed: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.exec(c, args)
+		if c.r.br.Buffered() > 0 {
+			continue
+		}
+		if err := c.w.bw.Flush(); err != nil {
+			return
+		}
+	}
+	c.w.bw.Flush()
+}
+
+func (s *Server) exec(c *conn, args [][]byte) {
+	if s.conf.Latency > 0 {
+		time.Sleep(s.conf.Latency)
+	}
+
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.error(unknownCommand(args))
+		return
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		c.w.error("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	if cmd.data && s.conf.ErrorRate > 0 && rand.Float64() < s.conf.ErrorRate {
+		c.w.error("ERR injected error")
+		return
+	}
+	cmd.fn(s, c, args)
+}
+
+func unknownCommand(args [][]byte) string {
+	var b strings.Builder
+	b.WriteString("ERR unknown command '")
+	b.Write(args[0])
+	b.WriteString("', with args beginning with: ")
+	for _, arg := range args[1:] {
+		b.WriteByte('\'')
+		b.Write(arg)
+		b.WriteString("' ")
+	}
+	return b.String()
+}